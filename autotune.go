@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// Autotune tuning rules
+const (
+	TuningRuleZieglerNichols = "ziegler_nichols" // Classic PID: fast, ~25% overshoot
+	TuningRuleTyreusLuyben   = "tyreus_luyben"   // Conservative: less overshoot, suited to slow thermal plants
+)
+
+// RelayAutotuner runs an Åström–Hägglund relay experiment: fans are switched
+// between two duties around the target so the disk temperature oscillates, and
+// the ultimate gain and period are read off the resulting limit cycle
+type RelayAutotuner struct {
+	Target         float64       // Temperature the relay switches around (°C)
+	Hysteresis     float64       // Switching band around the target (°C)
+	HighDuty       int           // Duty applied while above target (%)
+	LowDuty        int           // Duty applied while below target (%)
+	Cycles         int           // Oscillation cycles to measure (after one settling cycle)
+	MaxTemp        float64       // Abort if the temperature exceeds this (°C)
+	SampleInterval time.Duration // Time between temperature samples
+	MaxDuration    time.Duration // Abort the experiment after this long
+
+	// Plant I/O (injected so the experiment can run against a simulation)
+	readTemp func() (float64, error)
+	setDuty  func(int) error
+	sleep    func(context.Context, time.Duration) error
+}
+
+// AutotuneResult contains the measured limit cycle and suggested gains
+type AutotuneResult struct {
+	UltimateGain   float64       // Ku: relay gain that sustains the oscillation (duty % per °C)
+	UltimatePeriod time.Duration // Pu: oscillation period
+	Amplitude      float64       // Temperature oscillation amplitude (°C)
+	Rule           string        // Tuning rule used for the gains
+	Kp             float64       // Suggested proportional gain
+	Ki             float64       // Suggested integral gain (standard form, per second)
+	Kd             float64       // Suggested derivative gain (seconds)
+}
+
+// NewRelayAutotuner creates an autotuner from config driving the given plant I/O
+// sleep must return early with ctx.Err() once ctx is cancelled, like Clock.Sleep
+func NewRelayAutotuner(config *Config, readTemp func() (float64, error), setDuty func(int) error,
+	sleep func(context.Context, time.Duration) error) *RelayAutotuner {
+	return &RelayAutotuner{
+		Target:         config.Temperature.TargetHDD,
+		Hysteresis:     config.Autotune.Hysteresis,
+		HighDuty:       config.Autotune.HighDuty,
+		LowDuty:        config.Autotune.LowDuty,
+		Cycles:         config.Autotune.Cycles,
+		MaxTemp:        config.Temperature.MaxHDD,
+		SampleInterval: config.Temperature.PollInterval,
+		MaxDuration:    config.Autotune.MaxDuration,
+		readTemp:       readTemp,
+		setDuty:        setDuty,
+		sleep:          sleep,
+	}
+}
+
+// Run executes the relay experiment and returns the measured ultimate gain and period
+// Gains are not filled in; use AutotuneResult.ApplyRule. Cancelling ctx stops the experiment
+func (a *RelayAutotuner) Run(ctx context.Context) (*AutotuneResult, error) {
+	if a.HighDuty <= a.LowDuty {
+		return nil, fmt.Errorf("high duty (%d) must be greater than low duty (%d)", a.HighDuty, a.LowDuty)
+	}
+
+	temp, err := a.readTemp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read initial temperature: %w", err)
+	}
+
+	// Start on the side that drives the temperature towards the target
+	high := temp > a.Target
+	if err := a.applyRelay(high); err != nil {
+		return nil, err
+	}
+
+	var (
+		elapsed      time.Duration
+		lastRise     time.Duration
+		haveRise     bool
+		cycleMin     = math.Inf(1)
+		cycleMax     = math.Inf(-1)
+		periods      []time.Duration
+		amplitudes   []float64
+		neededCycles = a.Cycles + 1 // First cycle is discarded as transient
+	)
+
+	for elapsed < a.MaxDuration {
+		if err := a.sleep(ctx, a.SampleInterval); err != nil {
+			return nil, fmt.Errorf("autotune interrupted: %w", err)
+		}
+		elapsed += a.SampleInterval
+
+		temp, err = a.readTemp()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read temperature: %w", err)
+		}
+		if temp > a.MaxTemp {
+			return nil, fmt.Errorf("temperature %.1f°C exceeded max %.1f°C during autotune", temp, a.MaxTemp)
+		}
+
+		cycleMin = math.Min(cycleMin, temp)
+		cycleMax = math.Max(cycleMax, temp)
+
+		switch {
+		case !high && temp > a.Target+a.Hysteresis:
+			// Rising crossing: one full cycle completes here
+			high = true
+			if err := a.applyRelay(high); err != nil {
+				return nil, err
+			}
+			if haveRise {
+				periods = append(periods, elapsed-lastRise)
+				amplitudes = append(amplitudes, (cycleMax-cycleMin)/2)
+				cycleMin, cycleMax = temp, temp
+			}
+			lastRise = elapsed
+			haveRise = true
+		case high && temp < a.Target-a.Hysteresis:
+			high = false
+			if err := a.applyRelay(high); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(periods) >= neededCycles {
+			break
+		}
+	}
+
+	if len(periods) < neededCycles {
+		return nil, fmt.Errorf("no sustained oscillation after %v (%d of %d cycles)", a.MaxDuration, len(periods), neededCycles)
+	}
+
+	// Average the settled cycles
+	periods, amplitudes = periods[1:], amplitudes[1:]
+	var periodSum time.Duration
+	var amplitudeSum float64
+	for i := range periods {
+		periodSum += periods[i]
+		amplitudeSum += amplitudes[i]
+	}
+	period := periodSum / time.Duration(len(periods))
+	amplitude := amplitudeSum / float64(len(amplitudes))
+
+	if amplitude <= a.Hysteresis {
+		return nil, fmt.Errorf("oscillation amplitude %.2f°C not above hysteresis %.2f°C", amplitude, a.Hysteresis)
+	}
+
+	// Describing function of a relay with hysteresis: Ku = 4d / (π·sqrt(a² - ε²))
+	relayAmplitude := float64(a.HighDuty-a.LowDuty) / 2
+	ultimateGain := 4 * relayAmplitude / (math.Pi * math.Sqrt(amplitude*amplitude-a.Hysteresis*a.Hysteresis))
+
+	return &AutotuneResult{
+		UltimateGain:   ultimateGain,
+		UltimatePeriod: period,
+		Amplitude:      amplitude,
+	}, nil
+}
+
+// applyRelay sets the fans to the high or low relay duty
+func (a *RelayAutotuner) applyRelay(high bool) error {
+	duty := a.LowDuty
+	if high {
+		duty = a.HighDuty
+	}
+	if err := a.setDuty(duty); err != nil {
+		return fmt.Errorf("failed to set relay duty %d%%: %w", duty, err)
+	}
+	return nil
+}
+
+// ApplyRule computes suggested PID gains from Ku and Pu using the named rule
+func (r *AutotuneResult) ApplyRule(rule string) error {
+	kp, ki, kd, err := TuningRuleGains(rule, r.UltimateGain, r.UltimatePeriod.Seconds())
+	if err != nil {
+		return err
+	}
+	r.Rule = rule
+	r.Kp, r.Ki, r.Kd = kp, ki, kd
+	return nil
+}
+
+// TuningRuleGains converts ultimate gain and period (seconds) into standard-form PID gains
+func TuningRuleGains(rule string, ku, pu float64) (kp, ki, kd float64, err error) {
+	var ti, td float64
+	switch rule {
+	case TuningRuleZieglerNichols:
+		kp, ti, td = 0.6*ku, pu/2, pu/8
+	case TuningRuleTyreusLuyben:
+		kp, ti, td = ku/2.2, 2.2*pu, pu/6.3
+	default:
+		return 0, 0, 0, fmt.Errorf("unknown tuning rule %q", rule)
+	}
+	return kp, kp / ti, kp * td, nil
+}
+
+// ConfigSnippet renders the suggested gains as a pid: config block
+func (r *AutotuneResult) ConfigSnippet() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Relay autotune (%s): Ku=%.3f, Pu=%v, amplitude=%.2f°C\n",
+		r.Rule, r.UltimateGain, r.UltimatePeriod, r.Amplitude)
+	b.WriteString("pid:\n")
+	b.WriteString("  form: standard\n")
+	fmt.Fprintf(&b, "  kp: %.4f\n", r.Kp)
+	fmt.Fprintf(&b, "  ki: %.6f\n", r.Ki)
+	fmt.Fprintf(&b, "  kd: %.4f\n", r.Kd)
+	return b.String()
+}
+
+// LogResult logs the measured limit cycle and suggested gains
+func (r *AutotuneResult) LogResult() {
+	slog.Info("Autotune complete", "ku", r.UltimateGain, "pu", r.UltimatePeriod, "amplitude", r.Amplitude)
+	slog.Info("Suggested gains", "rule", r.Rule, "kp", r.Kp, "ki", r.Ki, "kd", r.Kd)
+}
+
+// runRelayExperiment runs the tuner and always leaves the fans at 100% afterwards,
+// even if ctx was cancelled by a signal mid-experiment. The reset gets its own
+// timeout so a hung ipmitool cannot hang autotune
+func runRelayExperiment(ctx context.Context, tuner *RelayAutotuner, fans FanActuator, timeout time.Duration) (*AutotuneResult, error) {
+	result, err := tuner.Run(ctx)
+
+	resetCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if setErr := fans.SetAllFans(resetCtx, 100); setErr != nil {
+		slog.Error("Failed to reset fans to 100% after autotune", "timeout", timeout, "error", setErr)
+		if err == nil {
+			return nil, fmt.Errorf("failed to reset fans to 100%% after autotune: %w", setErr)
+		}
+	}
+	return result, err
+}
+
+// runAutotune runs the relay experiment against the real hardware and writes the suggested config
+// This is used by the --autotune CLI flag
+func runAutotune(ctx context.Context, config *Config) error {
+	if *dryRun {
+		return fmt.Errorf("autotune needs to drive the fans and cannot run with --dry-run")
+	}
+
+	aggregator := NewAggregator(config)
+	filter := NewPlausibilityFilter(config.Plausibility)
+	readTemp := func() (float64, error) {
+		readings, err := readAllTemperatures(ctx, config)
+		if err != nil {
+			return 0, err
+		}
+		now := time.Now()
+		if readings, err = filter.Filter(readings, now); err != nil {
+			return 0, err
+		}
+		avgTemp, limitTemp := diskControlInputs(aggregator, readings, now)
+		if reason := checkEmergencyConditions(newEmergencyInputs(readings, limitTemp), config); reason != "" {
+			return 0, fmt.Errorf("emergency condition %s during autotune", reason)
+		}
+		return avgTemp, nil
+	}
+
+	var fans FanActuator = ipmiFanActuator{bmc: config.Fans.BMC}
+	setDuty := func(duty int) error {
+		return fans.SetAllFans(ctx, duty)
+	}
+
+	tuner := NewRelayAutotuner(config, readTemp, setDuty, systemClock{}.Sleep)
+	slog.Info("Starting relay autotune", "target", tuner.Target, "low_duty", tuner.LowDuty, "high_duty", tuner.HighDuty,
+		"hysteresis", tuner.Hysteresis, "cycles", tuner.Cycles, "max_duration", tuner.MaxDuration)
+
+	result, err := runRelayExperiment(ctx, tuner, fans, config.Server.ShutdownTimeout)
+	if err != nil {
+		return err
+	}
+
+	if err := result.ApplyRule(config.Autotune.Rule); err != nil {
+		return err
+	}
+	result.LogResult()
+
+	snippet := result.ConfigSnippet()
+	if config.Autotune.Output == "" {
+		fmt.Print(snippet)
+		return nil
+	}
+	if err := os.WriteFile(config.Autotune.Output, []byte(snippet), 0644); err != nil {
+		return fmt.Errorf("failed to write autotune result to %s: %w", config.Autotune.Output, err)
+	}
+	slog.Info("Suggested config written", "path", config.Autotune.Output)
+	return nil
+}
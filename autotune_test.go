@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// relayTestPlant is a two-stage thermal model: chassis air responds to fan duty,
+// and the disk follows the air temperature with its own lag
+type relayTestPlant struct {
+	air  float64
+	disk float64
+	duty int
+}
+
+// step advances the plant by dt
+func (p *relayTestPlant) step(dt time.Duration) {
+	const (
+		ambient = 25.0
+		heat    = 25.0 // Temperature rise over ambient with fans stopped
+		cooling = 0.2  // °C removed per % duty at equilibrium
+		airTau  = 120.0
+		diskTau = 600.0
+	)
+	seconds := dt.Seconds()
+	airEquilibrium := ambient + heat - cooling*float64(p.duty)
+	p.air += (airEquilibrium - p.air) * seconds / airTau
+	p.disk += (p.air - p.disk) * seconds / diskTau
+}
+
+// newRelayTestTuner wires a RelayAutotuner to the plant with simulated time
+func newRelayTestTuner(plant *relayTestPlant) *RelayAutotuner {
+	config := &Config{}
+	setDefaults(config)
+	config.Temperature.TargetHDD = 38.0
+	config.Temperature.MaxHDD = 45.0
+	config.Temperature.PollInterval = 10 * time.Second
+
+	return NewRelayAutotuner(config,
+		func() (float64, error) { return plant.disk, nil },
+		func(duty int) error { plant.duty = duty; return nil },
+		func(ctx context.Context, d time.Duration) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			for elapsed := time.Duration(0); elapsed < d; elapsed += time.Second {
+				plant.step(time.Second)
+			}
+			return nil
+		},
+	)
+}
+
+// TestRelayAutotuner_Run_SimulatedPlant tests the relay experiment against a plant
+func TestRelayAutotuner_Run_SimulatedPlant(t *testing.T) {
+	// Arrange
+	plant := &relayTestPlant{air: 38.0, disk: 38.0}
+	tuner := newRelayTestTuner(plant)
+
+	// Act
+	result, err := tuner.Run(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	assert.Greater(t, result.UltimateGain, 0.0)
+	assert.Greater(t, result.UltimatePeriod, time.Minute)
+	assert.Less(t, result.UltimatePeriod, 2*time.Hour)
+	assert.Greater(t, result.Amplitude, tuner.Hysteresis)
+}
+
+// TestRelayAutotuner_TunedGains_Settle tests that suggested gains control the plant
+func TestRelayAutotuner_TunedGains_Settle(t *testing.T) {
+	for _, rule := range []string{TuningRuleZieglerNichols, TuningRuleTyreusLuyben} {
+		t.Run(rule, func(t *testing.T) {
+			// Arrange
+			plant := &relayTestPlant{air: 38.0, disk: 38.0}
+			result, err := newRelayTestTuner(plant).Run(context.Background())
+			require.NoError(t, err)
+			require.NoError(t, result.ApplyRule(rule))
+
+			pid := NewPIDController(result.Kp, result.Ki, result.Kd, 36.0, 0, 100, 100)
+			pid.SetForm(PIDFormStandard) // Tuned gains are for the standard form
+			pid.now = steppingClock(10 * time.Second)
+
+			// Act - run the closed loop for 12 simulated hours at a new setpoint
+			for i := 0; i < 12*360; i++ {
+				output, _ := pid.Calculate(plant.disk)
+				plant.duty = int(output)
+				for s := 0; s < 10; s++ {
+					plant.step(time.Second)
+				}
+			}
+
+			// Assert
+			assert.InDelta(t, 36.0, plant.disk, 0.5)
+		})
+	}
+}
+
+// TestRelayAutotuner_Run_AbortsOverMaxTemp tests the safety abort
+func TestRelayAutotuner_Run_AbortsOverMaxTemp(t *testing.T) {
+	// Arrange - plant can't cool below 46°C even at full duty
+	plant := &relayTestPlant{air: 50.0, disk: 50.0}
+	tuner := newRelayTestTuner(plant)
+	tuner.readTemp = func() (float64, error) { return plant.disk + 20, nil }
+
+	// Act
+	_, err := tuner.Run(context.Background())
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "exceeded max")
+}
+
+// TestRelayAutotuner_Run_NoOscillation tests timeout when the relay can't drive a cycle
+func TestRelayAutotuner_Run_NoOscillation(t *testing.T) {
+	// Arrange - temperature never crosses the target
+	plant := &relayTestPlant{}
+	tuner := newRelayTestTuner(plant)
+	tuner.readTemp = func() (float64, error) { return 30.0, nil }
+	tuner.MaxDuration = time.Hour
+
+	// Act
+	_, err := tuner.Run(context.Background())
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no sustained oscillation")
+}
+
+// TestRunRelayExperiment_Cancelled tests that a signal mid-experiment still leaves the fans at 100%
+func TestRunRelayExperiment_Cancelled(t *testing.T) {
+	// Arrange
+	silenceLogs(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	plant := &relayTestPlant{air: 38.0, disk: 38.0}
+	fans := &fakeFanActuator{}
+	tuner := newRelayTestTuner(plant)
+	tuner.setDuty = func(duty int) error { return fans.SetAllFans(ctx, duty) }
+	samples := 0
+	tuner.readTemp = func() (float64, error) {
+		samples++
+		if samples == 3 {
+			cancel() // SIGINT arrives during the experiment
+		}
+		return plant.disk, nil
+	}
+
+	// Act
+	_, err := runRelayExperiment(ctx, tuner, fans, time.Second)
+
+	// Assert
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, err.Error(), "autotune interrupted")
+	assert.Equal(t, 100, fans.lastDuty())
+}
+
+// hangingFanActuator never finishes a fan command before its context ends, like a hung ipmitool
+type hangingFanActuator struct {
+	fakeFanActuator
+}
+
+// SetAllFans records the duty and blocks until ctx is done
+func (f *hangingFanActuator) SetAllFans(ctx context.Context, dutyPercent int) error {
+	f.duties = append(f.duties, dutyPercent)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// TestRunRelayExperiment_ResetTimeout tests that a hung reset to 100% is bounded and reported
+func TestRunRelayExperiment_ResetTimeout(t *testing.T) {
+	// Arrange
+	silenceLogs(t)
+	plant := &relayTestPlant{air: 38.0, disk: 38.0}
+	fans := &hangingFanActuator{}
+	tuner := newRelayTestTuner(plant)
+
+	// Act
+	result, err := runRelayExperiment(context.Background(), tuner, fans, 50*time.Millisecond)
+
+	// Assert
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "failed to reset fans")
+	assert.Nil(t, result)
+	assert.Equal(t, []int{100}, fans.duties)
+}
+
+// TestTuningRuleGains tests Ziegler-Nichols and Tyreus-Luyben formulas
+func TestTuningRuleGains(t *testing.T) {
+	tests := []struct {
+		rule           string
+		kp, ki, kd     float64
+		expectErrorMsg string
+	}{
+		{rule: TuningRuleZieglerNichols, kp: 6.0, ki: 6.0 / 300, kd: 6.0 * 75},
+		{rule: TuningRuleTyreusLuyben, kp: 10.0 / 2.2, ki: (10.0 / 2.2) / 1320, kd: (10.0 / 2.2) * 600 / 6.3},
+		{rule: "cohen_coon", expectErrorMsg: "unknown tuning rule"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			// Act - Ku = 10, Pu = 600s
+			kp, ki, kd, err := TuningRuleGains(tt.rule, 10.0, 600.0)
+
+			// Assert
+			if tt.expectErrorMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectErrorMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.InDelta(t, tt.kp, kp, 1e-9)
+			assert.InDelta(t, tt.ki, ki, 1e-9)
+			assert.InDelta(t, tt.kd, kd, 1e-9)
+		})
+	}
+}
+
+// TestAutotuneResult_ConfigSnippet tests the snippet parses as config
+func TestAutotuneResult_ConfigSnippet(t *testing.T) {
+	// Arrange
+	result := &AutotuneResult{UltimateGain: 10, UltimatePeriod: 10 * time.Minute, Amplitude: 1.2}
+	require.NoError(t, result.ApplyRule(TuningRuleTyreusLuyben))
+
+	// Act
+	var config Config
+	err := yaml.Unmarshal([]byte(result.ConfigSnippet()), &config)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, PIDFormStandard, config.PID.Form)
+	assert.InDelta(t, result.Kp, config.PID.Kp, 1e-4)
+	assert.InDelta(t, result.Ki, config.PID.Ki, 1e-6)
+	assert.InDelta(t, result.Kd, config.PID.Kd, 1e-4)
+}
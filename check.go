@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Startup behaviour when validateEnvironment fails
+const (
+	StartupFailureExit     = "exit"     // Log a fatal error and exit non-zero
+	StartupFailureDegraded = "degraded" // Set fans to 100% and keep retrying in the control loop
+)
+
+// DiskCheck is one block device in the preflight report
+type DiskCheck struct {
+	Device   string
+	Status   string // DiskMonitored, DiskFlash, DiskExcluded, DiskNotSpinning, DiskRemovable or DiskNotIncluded
+	Group    string // Matching disks.groups entry, for monitored disks
+	Source   string // How the temperature is read, for monitored disks
+	Identity DiskIdentity
+	Temp     int
+	Err      error
+}
+
+// CheckReport is the result of the `check` subcommand. It is read-only: fans are never changed
+type CheckReport struct {
+	ConfigPath string
+
+	Disks   []DiskCheck
+	DiskErr error
+
+	CPUPath string
+	CPUTemp float64
+	CPUErr  error
+
+	FanSpeeds map[string]int
+	FanErr    error
+
+	Enclosures   *EnclosureReadings // Nil unless enclosures.enabled
+	EnclosureErr error
+
+	Tools   map[string]string // Tool name -> resolved path, empty if missing
+	BMCInfo map[string]string // From `ipmitool mc info`
+	BMCErr  error
+	BMCType string
+
+	Lint []string
+}
+
+// runCheck gathers the preflight report from the host
+func runCheck(ctx context.Context, config *Config, configPath string) *CheckReport {
+	report := &CheckReport{ConfigPath: configPath, BMCType: config.Fans.BMC, Tools: make(map[string]string)}
+
+	// Disks, their identities and temperatures
+	report.Disks, report.DiskErr = discoverDisks(sysBlockPath, config.Disks)
+	byID, _ := findByIDNames(diskByIDPath)
+	for i := range report.Disks {
+		disk := &report.Disks[i]
+		if (disk.Status != DiskMonitored && disk.Status != DiskFlash) || disk.Err != nil {
+			continue
+		}
+		identity, err := ResolveDiskIdentity(ctx, disk.Device, byID)
+		if err != nil {
+			identity = DiskIdentity{Device: disk.Device}
+		}
+		if status := selectDisk(identity, config.Disks); status != DiskMonitored {
+			disk.Status, disk.Source = status, ""
+			continue
+		}
+		disk.Identity = identity
+		if disk.Status == DiskMonitored {
+			disk.Group = ResolveDiskPolicy(config, identity).Group
+		}
+		disk.Temp, disk.Err = GetDiskTemperature(ctx, disk.Device)
+	}
+
+	// CPU sensor
+	if hwmonPath, err := findK10TempPath(); err != nil {
+		report.CPUErr = err
+	} else {
+		report.CPUPath = filepath.Join(hwmonPath, "temp1_input")
+		report.CPUTemp, report.CPUErr = readCPUTempFromPath(hwmonPath)
+	}
+
+	// SES enclosure sensors
+	tools := []string{"ipmitool", "smartctl"}
+	if config.Enclosures.Enabled {
+		enclosures, err := GetEnclosureTemperatures(ctx, config.Enclosures)
+		report.Enclosures, report.EnclosureErr = &enclosures, err
+		tools = append(tools, "sg_ses")
+	}
+
+	// Backend tools and BMC
+	for _, tool := range tools {
+		path, _ := exec.LookPath(tool)
+		report.Tools[tool] = path
+	}
+	report.FanSpeeds, report.FanErr = GetFanSpeeds(ctx)
+	if output, err := exec.CommandContext(ctx, "ipmitool", "mc", "info").CombinedOutput(); err != nil {
+		report.BMCErr = fmt.Errorf("ipmitool mc info failed: %v, output: %s", err, strings.TrimSpace(string(output)))
+	} else {
+		report.BMCInfo = parseIPMIMcInfo(string(output))
+	}
+
+	report.Lint = lintConfig(config, report.MonitoredDisks())
+	return report
+}
+
+// discoverDisks classifies every block device under sysBlock
+func discoverDisks(sysBlock string, diskConfig DiskConfig) ([]DiskCheck, error) {
+	entries, err := os.ReadDir(sysBlock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", sysBlock, err)
+	}
+
+	disks := make([]DiskCheck, 0, len(entries))
+	for _, entry := range entries {
+		disk := DiskCheck{Device: entry.Name()}
+		disk.Status, disk.Err = classifyDisk(sysBlock, disk.Device, diskConfig.ExcludePatterns)
+		disk.Status = classifyFlash(sysBlock, disk.Device, disk.Status, diskConfig.Flash)
+		if disk.Status == DiskMonitored || disk.Status == DiskFlash {
+			disk.Source = fmt.Sprintf("smartctl -A /dev/%s", disk.Device)
+		}
+		disks = append(disks, disk)
+	}
+	return disks, nil
+}
+
+// parseIPMIMcInfo parses "Key : Value" lines from `ipmitool mc info`
+func parseIPMIMcInfo(output string) map[string]string {
+	info := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		key = strings.TrimSpace(key)
+		if !found || key == "" || strings.HasPrefix(scanner.Text(), " ") {
+			continue // Continuation lines of multi-line values
+		}
+		info[key] = strings.TrimSpace(value)
+	}
+	return info
+}
+
+// lintConfig returns warnings for settings that are valid but probably wrong
+func lintConfig(config *Config, monitoredDisks int) []string {
+	var warnings []string
+
+	if config.PID.Form == PIDFormLegacy {
+		warnings = append(warnings, "pid.form is legacy: ki has no effect; set pid.form: standard and retune ki")
+	}
+	if headroom := config.Temperature.MaxHDD - config.Temperature.TargetHDD; headroom < 3 {
+		warnings = append(warnings, fmt.Sprintf("target_hdd is only %.1f°C below max_hdd: expect frequent emergency mode", headroom))
+	}
+	if config.Fans.MinDuty < 30 {
+		warnings = append(warnings, fmt.Sprintf("min_duty %d%% is low: disks may heat up to max_hdd and oscillate with emergency mode", config.Fans.MinDuty))
+	}
+	if config.Fans.StartupDuty < config.Fans.MinDuty {
+		warnings = append(warnings, fmt.Sprintf("startup_duty %d%% is below min_duty %d%%", config.Fans.StartupDuty, config.Fans.MinDuty))
+	}
+	if config.Temperature.PollInterval > 5*time.Minute {
+		warnings = append(warnings, fmt.Sprintf("poll_interval %v is long: the loop reacts slowly to temperature changes", config.Temperature.PollInterval))
+	}
+	if monitoredDisks > 0 && config.Temperature.WarmestDisks > monitoredDisks {
+		warnings = append(warnings, fmt.Sprintf("warmest_disks %d is more than the %d monitored disks", config.Temperature.WarmestDisks, monitoredDisks))
+	}
+	if config.Fans.OnExit == OnExitHold {
+		warnings = append(warnings, "fans.on_exit is hold: fans keep the last duty after the controller stops")
+	}
+	return warnings
+}
+
+// MonitoredDisks returns how many spinning disks the control loop will use
+func (r *CheckReport) MonitoredDisks() int {
+	count := 0
+	for _, disk := range r.Disks {
+		if disk.Status == DiskMonitored {
+			count++
+		}
+	}
+	return count
+}
+
+// Problems returns the failures that would stop the controller from working
+func (r *CheckReport) Problems() []string {
+	var problems []string
+
+	if r.DiskErr != nil {
+		problems = append(problems, r.DiskErr.Error())
+	}
+	readable := 0
+	for _, disk := range r.Disks {
+		if disk.Status == DiskMonitored && disk.Err == nil {
+			readable++
+		}
+	}
+	if r.DiskErr == nil && readable == 0 {
+		problems = append(problems, "no monitored disk temperature is readable")
+	}
+	if r.CPUErr != nil {
+		problems = append(problems, fmt.Sprintf("CPU sensor: %v", r.CPUErr))
+	}
+	if r.EnclosureErr != nil {
+		problems = append(problems, fmt.Sprintf("enclosures: %v", r.EnclosureErr))
+	}
+	for _, tool := range sortedKeys(r.Tools) {
+		if r.Tools[tool] == "" {
+			problems = append(problems, fmt.Sprintf("%s not found in PATH", tool))
+		}
+	}
+	if r.FanErr != nil {
+		problems = append(problems, fmt.Sprintf("fan speeds: %v", r.FanErr))
+	}
+	return problems
+}
+
+// Write prints the human-readable report
+func (r *CheckReport) Write(w io.Writer) {
+	fmt.Fprintf(w, "Fan controller preflight check (config: %s)\n", r.ConfigPath)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "\nDisks (%s):\n", sysBlockPath)
+	if r.DiskErr != nil {
+		fmt.Fprintf(tw, "  error: %v\n", r.DiskErr)
+	}
+	for _, disk := range r.Disks {
+		identity := "-"
+		if disk.Identity.Serial != "" {
+			identity = fmt.Sprintf("%s %s", disk.Identity.Model, disk.Identity.Serial)
+		}
+		if disk.Group != "" {
+			identity += fmt.Sprintf(" [%s]", disk.Group)
+		}
+		switch {
+		case disk.Err != nil:
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\terror: %v\n", disk.Device, disk.Status, identity, disk.Source, disk.Err)
+		case disk.Status == DiskMonitored || disk.Status == DiskFlash:
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%d°C\n", disk.Device, disk.Status, identity, disk.Source, disk.Temp)
+		default:
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t-\t-\n", disk.Device, disk.Status, identity)
+		}
+	}
+
+	fmt.Fprintf(tw, "\nCPU sensor:\n")
+	if r.CPUErr != nil {
+		fmt.Fprintf(tw, "  k10temp\terror: %v\n", r.CPUErr)
+	} else {
+		fmt.Fprintf(tw, "  k10temp\t%s\t%.1f°C\n", r.CPUPath, r.CPUTemp)
+	}
+
+	if r.Enclosures != nil {
+		fmt.Fprintf(tw, "\nEnclosures (%s):\n", enclosureClassPath)
+		if r.EnclosureErr != nil {
+			fmt.Fprintf(tw, "  error: %v\n", r.EnclosureErr)
+		}
+		for _, sensor := range sortedKeys(r.Enclosures.Temperatures) {
+			fmt.Fprintf(tw, "  %s\t%d°C\n", sensor, r.Enclosures.Temperatures[sensor])
+		}
+		for _, fan := range sortedKeys(r.Enclosures.Fans) {
+			fmt.Fprintf(tw, "  %s\t%d RPM\n", fan, r.Enclosures.Fans[fan])
+		}
+	}
+
+	fmt.Fprintf(tw, "\nFans (ipmitool sensor):\n")
+	if r.FanErr != nil {
+		fmt.Fprintf(tw, "  error: %v\n", r.FanErr)
+	}
+	for _, fan := range sortedKeys(r.FanSpeeds) {
+		fmt.Fprintf(tw, "  %s\t%d RPM\n", fan, r.FanSpeeds[fan])
+	}
+
+	fmt.Fprintf(tw, "\nBackend:\n")
+	for _, tool := range sortedKeys(r.Tools) {
+		path := r.Tools[tool]
+		if path == "" {
+			path = "not found"
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", tool, path)
+	}
+	if r.BMCErr != nil {
+		fmt.Fprintf(tw, "  BMC\terror: %v\n", r.BMCErr)
+	} else {
+		fmt.Fprintf(tw, "  BMC\t%s %s (firmware %s)\n",
+			r.BMCInfo["Manufacturer Name"], r.BMCInfo["Product Name"], r.BMCInfo["Firmware Revision"])
+	}
+	fmt.Fprintf(tw, "  duty command\tipmitool raw 0x3a 0xd6 (ASRock X570D4U)\n")
+	fmt.Fprintf(tw, "  bmc_auto\t%s\n", r.BMCType)
+	tw.Flush()
+
+	fmt.Fprintf(w, "\nConfig lint:\n")
+	if len(r.Lint) == 0 {
+		fmt.Fprintf(w, "  no warnings\n")
+	}
+	for _, warning := range r.Lint {
+		fmt.Fprintf(w, "  warning: %s\n", warning)
+	}
+
+	problems := r.Problems()
+	if len(problems) == 0 {
+		fmt.Fprintf(w, "\nResult: OK (%d monitored disks)\n", r.MonitoredDisks())
+		return
+	}
+	fmt.Fprintf(w, "\nResult: FAILED\n")
+	for _, problem := range problems {
+		fmt.Fprintf(w, "  - %s\n", problem)
+	}
+}
+
+// sortedKeys returns the keys of m in order, for stable report output
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSysBlockDevice creates the queue/rotational and removable files for a fake block device
+func writeSysBlockDevice(t *testing.T, sysBlock, device, rotational, removable string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Join(sysBlock, device, "queue"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(sysBlock, device, "queue", "rotational"), []byte(rotational+"\n"), 0o644))
+	if removable != "" {
+		require.NoError(t, os.WriteFile(filepath.Join(sysBlock, device, "removable"), []byte(removable+"\n"), 0o644))
+	}
+}
+
+// TestDiscoverDisks_ClassifiesDevices tests every discovery outcome
+func TestDiscoverDisks_ClassifiesDevices(t *testing.T) {
+	// Arrange
+	sysBlock := t.TempDir()
+	writeSysBlockDevice(t, sysBlock, "sda", "1", "0")
+	writeSysBlockDevice(t, sysBlock, "sdb", "1", "1")
+	writeSysBlockDevice(t, sysBlock, "nvme0n1", "0", "0")
+	writeSysBlockDevice(t, sysBlock, "loop0", "1", "0")
+	writeSysBlockDevice(t, sysBlock, "sdc", "1", "")
+
+	// Act
+	disks, err := discoverDisks(sysBlock, DiskConfig{ExcludePatterns: []string{"^loop"}})
+
+	// Assert
+	require.NoError(t, err)
+	statuses := make(map[string]string)
+	for _, disk := range disks {
+		statuses[disk.Device] = disk.Status
+		if disk.Device == "sdc" {
+			assert.Error(t, disk.Err, "missing removable file should be reported")
+		}
+	}
+	assert.Equal(t, DiskMonitored, statuses["sda"])
+	assert.Equal(t, DiskRemovable, statuses["sdb"])
+	assert.Equal(t, DiskNotSpinning, statuses["nvme0n1"])
+	assert.Equal(t, DiskExcluded, statuses["loop0"])
+}
+
+// TestDiscoverDisks_Flash tests that fixed flash drives are reported when disks.flash is enabled
+func TestDiscoverDisks_Flash(t *testing.T) {
+	// Arrange
+	sysBlock := t.TempDir()
+	writeSysBlockDevice(t, sysBlock, "sda", "1", "0")
+	writeSysBlockDevice(t, sysBlock, "nvme0n1", "0", "0")
+	require.NoError(t, os.MkdirAll(filepath.Join(sysBlock, "nvme0n1", "device"), 0o755))
+
+	// Act
+	disks, err := discoverDisks(sysBlock, DiskConfig{Flash: FlashConfig{Enabled: true}})
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, disks, 2)
+	report := &CheckReport{Disks: disks}
+	for _, disk := range disks {
+		if disk.Device == "nvme0n1" {
+			assert.Equal(t, DiskFlash, disk.Status)
+			assert.Equal(t, "smartctl -A /dev/nvme0n1", disk.Source)
+		}
+	}
+	assert.Equal(t, 1, report.MonitoredDisks(), "flash drives are not counted as control disks")
+}
+
+// TestDiscoverDisks_MissingSysBlock_Error tests a missing block device root
+func TestDiscoverDisks_MissingSysBlock_Error(t *testing.T) {
+	// Act
+	_, err := discoverDisks(filepath.Join(t.TempDir(), "missing"), DiskConfig{})
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to read")
+}
+
+// TestParseIPMIMcInfo tests parsing of `ipmitool mc info` output
+func TestParseIPMIMcInfo(t *testing.T) {
+	// Arrange
+	output := `Device ID                 : 32
+Firmware Revision         : 1.20
+Manufacturer Name         : ASRockRack
+Product Name              : X570D4U
+Additional Device Support :
+    Sensor Device
+    SDR Repository Device
+`
+
+	// Act
+	info := parseIPMIMcInfo(output)
+
+	// Assert
+	assert.Equal(t, "1.20", info["Firmware Revision"])
+	assert.Equal(t, "ASRockRack", info["Manufacturer Name"])
+	assert.Equal(t, "X570D4U", info["Product Name"])
+	assert.NotContains(t, info, "Sensor Device")
+}
+
+// lintCleanConfig returns a config with no lint warnings
+func lintCleanConfig() *Config {
+	config := &Config{
+		Temperature: TemperatureConfig{TargetHDD: 38, MaxHDD: 45},
+		Fans:        FanConfig{MinDuty: 60, StartupDuty: 60},
+		PID:         PIDConfig{Form: PIDFormStandard},
+	}
+	setDefaults(config)
+	return config
+}
+
+// TestLintConfig tests warnings for valid but suspicious settings
+func TestLintConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(*Config)
+		disks    int
+		expected string
+	}{
+		{"legacy pid form", func(c *Config) { c.PID.Form = PIDFormLegacy }, 4, "pid.form is legacy"},
+		{"small headroom", func(c *Config) { c.Temperature.MaxHDD = c.Temperature.TargetHDD + 2 }, 4, "below max_hdd"},
+		{"low min duty", func(c *Config) { c.Fans.MinDuty = 20 }, 4, "min_duty 20% is low"},
+		{"warmest disks above monitored", func(c *Config) { c.Temperature.WarmestDisks = 6 }, 4, "warmest_disks 6 is more than the 4 monitored disks"},
+		{"hold on exit", func(c *Config) { c.Fans.OnExit = OnExitHold }, 4, "fans.on_exit is hold"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := lintCleanConfig()
+			tt.modify(config)
+
+			// Act
+			warnings := lintConfig(config, tt.disks)
+
+			// Assert
+			require.Len(t, warnings, 1)
+			assert.Contains(t, warnings[0], tt.expected)
+		})
+	}
+}
+
+// TestLintConfig_Clean tests that sensible settings have no warnings
+func TestLintConfig_Clean(t *testing.T) {
+	// Act
+	warnings := lintConfig(lintCleanConfig(), 4)
+
+	// Assert
+	assert.Empty(t, warnings)
+}
+
+// TestCheckReport_Write tests the OK and FAILED results
+func TestCheckReport_Write(t *testing.T) {
+	healthy := func() *CheckReport {
+		return &CheckReport{
+			ConfigPath: "/config/config.yaml",
+			Disks: []DiskCheck{
+				{Device: "sda", Status: DiskMonitored, Source: "smartctl -A /dev/sda", Temp: 38},
+				{Device: "nvme0n1", Status: DiskNotSpinning},
+			},
+			CPUPath:   "/sys/class/hwmon/hwmon2/temp1_input",
+			CPUTemp:   45.5,
+			FanSpeeds: map[string]int{"FAN1": 900},
+			Tools:     map[string]string{"ipmitool": "/usr/bin/ipmitool", "smartctl": "/usr/sbin/smartctl"},
+			BMCInfo:   map[string]string{"Manufacturer Name": "ASRockRack"},
+			BMCType:   BMCASRock,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(*CheckReport)
+		expected []string
+	}{
+		{"healthy", func(r *CheckReport) {}, []string{"sda", "38°C", "45.5°C", "FAN1", "900 RPM", "Result: OK (1 monitored disks)"}},
+		{"no readable disk", func(r *CheckReport) { r.Disks[0].Err = errors.New("smartctl failed") }, []string{"error: smartctl failed", "Result: FAILED", "no monitored disk temperature is readable"}},
+		{"missing tool", func(r *CheckReport) { r.Tools["smartctl"] = "" }, []string{"not found", "Result: FAILED", "smartctl not found in PATH"}},
+		{"ipmi failure", func(r *CheckReport) { r.FanErr = errors.New("ipmitool sensor failed") }, []string{"Result: FAILED", "fan speeds: ipmitool sensor failed"}},
+		{"lint warning only", func(r *CheckReport) { r.Lint = []string{"min_duty 20% is low"} }, []string{"warning: min_duty 20% is low", "Result: OK"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			report := healthy()
+			tt.modify(report)
+			var buf bytes.Buffer
+
+			// Act
+			report.Write(&buf)
+
+			// Assert
+			for _, expected := range tt.expected {
+				assert.Contains(t, buf.String(), expected)
+			}
+		})
+	}
+}
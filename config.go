@@ -2,7 +2,11 @@ package main
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -10,33 +14,49 @@ import (
 
 // Config represents the complete configuration structure
 type Config struct {
-	Server      ServerConfig      `yaml:"server"`
-	Temperature TemperatureConfig `yaml:"temperature"`
-	Fans        FanConfig         `yaml:"fans"`
-	PID         PIDConfig         `yaml:"pid"`
-	Disks       DiskConfig        `yaml:"disks"`
+	Server        ServerConfig        `yaml:"server"`
+	Temperature   TemperatureConfig   `yaml:"temperature"`
+	Fans          FanConfig           `yaml:"fans"`
+	PID           PIDConfig           `yaml:"pid"`
+	Disks         DiskConfig          `yaml:"disks"`
+	Enclosures    EnclosureConfig     `yaml:"enclosures"`
+	Autotune      AutotuneConfig      `yaml:"autotune"`
+	Watchdog      WatchdogConfig      `yaml:"watchdog"`
+	SensorFailure SensorFailureConfig `yaml:"sensor_failure"`
+	Plausibility  PlausibilityConfig  `yaml:"plausibility"`
+	Notify        NotifyConfig        `yaml:"notify"`
+	MQTT          MQTTConfig          `yaml:"mqtt"`
 }
 
 // ServerConfig contains server-related settings
 type ServerConfig struct {
-	MetricsPort int    `yaml:"metrics_port"`
-	LogLevel    string `yaml:"log_level"`
+	MetricsPort      int           `yaml:"metrics_port"`
+	LogLevel         string        `yaml:"log_level"`
+	LogFormat        string        `yaml:"log_format"`         // Log output: text or json
+	ShutdownTimeout  time.Duration `yaml:"shutdown_timeout"`   // Max wait for the loop to stop, and for the shutdown fan command
+	Journald         bool          `yaml:"journald"`           // Log to journald with structured status fields
+	OnStartupFailure string        `yaml:"on_startup_failure"` // When sensors or IPMI are unavailable at startup: exit or degraded
 }
 
 // TemperatureConfig contains temperature thresholds and polling settings
 type TemperatureConfig struct {
-	TargetHDD      float64       `yaml:"target_hdd"`      // Target temp for warmest N disks (°C)
-	MaxHDD         float64       `yaml:"max_hdd"`          // Emergency override temp (°C)
-	MaxCPU         float64       `yaml:"max_cpu"`          // CPU emergency temp (°C)
-	PollInterval   time.Duration `yaml:"poll_interval"`    // How often to check temps and adjust fans
-	WarmestDisks   int           `yaml:"warmest_disks"`    // Average temp of this many warmest disks
+	TargetHDD        float64       `yaml:"target_hdd"`         // Target temp for warmest N disks (°C)
+	MaxHDD           float64       `yaml:"max_hdd"`            // Emergency override temp (°C)
+	MaxCPU           float64       `yaml:"max_cpu"`            // CPU emergency temp (°C)
+	PollInterval     time.Duration `yaml:"poll_interval"`      // How often to check temps and adjust fans
+	WarmestDisks     int           `yaml:"warmest_disks"`      // Average temp of this many warmest disks
+	Aggregate        string        `yaml:"aggregate"`          // Control input: top_n_mean, max, percentile, ewma or margin
+	Percentile       float64       `yaml:"percentile"`         // Percentile of disk temps for aggregate: percentile (0-100)
+	EWMATimeConstant time.Duration `yaml:"ewma_time_constant"` // Smoothing time constant for aggregate: ewma
 }
 
 // FanConfig contains fan control settings
 type FanConfig struct {
-	MinDuty     int `yaml:"min_duty"`     // Minimum fan duty cycle (%)
-	MaxDuty     int `yaml:"max_duty"`     // Maximum fan duty cycle (%)
-	StartupDuty int `yaml:"startup_duty"` // Initial fan duty on startup (%)
+	MinDuty     int    `yaml:"min_duty"`     // Minimum fan duty cycle (%)
+	MaxDuty     int    `yaml:"max_duty"`     // Maximum fan duty cycle (%)
+	StartupDuty int    `yaml:"startup_duty"` // Initial fan duty on startup (%)
+	OnExit      string `yaml:"on_exit"`      // Fan action on shutdown or crash: full, bmc_auto or hold
+	BMC         string `yaml:"bmc"`          // Board BMC for bmc_auto: asrock or supermicro
 }
 
 // PIDConfig contains PID controller gains and limits
@@ -44,12 +64,163 @@ type PIDConfig struct {
 	Kp          float64 `yaml:"kp"`           // Proportional gain
 	Ki          float64 `yaml:"ki"`           // Integral gain
 	Kd          float64 `yaml:"kd"`           // Derivative gain
-	IntegralMax float64 `yaml:"integral_max"` // Anti-windup limit for integral term
+	IntegralMax float64 `yaml:"integral_max"` // Anti-windup limit for integral term (duty %)
+	Form        string  `yaml:"form"`         // Integral form: legacy (ki ignored) or standard (ki applied)
+
+	AntiWindup   string  `yaml:"anti_windup"`   // Anti-windup strategy: clamp, conditional, back_calculation
+	TrackingGain float64 `yaml:"tracking_gain"` // Back-calculation tracking gain (1/s)
+
+	DerivativeOnMeasurement bool          `yaml:"derivative_on_measurement"` // Differentiate temperature, not error (no setpoint kick)
+	DerivativeFilter        time.Duration `yaml:"derivative_filter"`         // Low-pass time constant for D term (0 = disabled)
+
+	FeedForward FeedForwardConfig `yaml:"feed_forward"` // Load-based duty bias added to PID output
+}
+
+// FeedForwardConfig contains the optional CPU load / disk I/O duty bias settings
+type FeedForwardConfig struct {
+	Enabled bool     `yaml:"enabled"`  // Add a duty bias from CPU load and disk I/O
+	CPUGain *float64 `yaml:"cpu_gain"` // Duty % added per % of CPU utilisation; 0 turns the CPU input off
+	IOGain  *float64 `yaml:"io_gain"`  // Duty % added per MB/s of disk throughput; 0 turns the I/O input off
+	MaxBias float64  `yaml:"max_bias"` // Maximum feed-forward bias (%)
 }
 
 // DiskConfig contains disk discovery and filtering settings
 type DiskConfig struct {
-	ExcludePatterns []string `yaml:"exclude_patterns"` // Regex patterns for disks to ignore
+	ExcludePatterns []string      `yaml:"exclude_patterns"` // Regex patterns for disks to ignore (kernel name, serial or model)
+	IncludePatterns []string      `yaml:"include_patterns"` // If set, only disks matching one of these are monitored (kernel name, serial, model or by-id)
+	Groups          []DiskGroup   `yaml:"groups"`           // Per-disk thresholds and weights; the first matching group applies
+	Flash           FlashConfig   `yaml:"flash"`            // Separate policy for SSD and NVMe drives
+	Hotplug         HotplugConfig `yaml:"hotplug"`          // Policy for disks that appear or disappear while running
+}
+
+// HotplugConfig contains the policy for disks removed while the controller runs
+type HotplugConfig struct {
+	OnRemoval   string        `yaml:"on_removal"`   // log (log and count only) or warn (also hold fans at warning_duty)
+	WarningDuty int           `yaml:"warning_duty"` // Minimum fan duty while a removed disk is missing (%)
+	WarningHold time.Duration `yaml:"warning_hold"` // Forget a removed disk after this long (0 = until it returns or restart)
+}
+
+// FlashConfig contains the thermal policy for non-rotational drives (SATA SSD, NVMe)
+type FlashConfig struct {
+	Enabled    bool    `yaml:"enabled"`     // Monitor flash drives; they never enter the HDD average
+	Mode       string  `yaml:"mode"`        // emergency (only max_temp forces 100%) or control (also drives the PID)
+	TargetTemp float64 `yaml:"target_temp"` // Target temp in control mode (°C)
+	MaxTemp    float64 `yaml:"max_temp"`    // Emergency temp (°C)
+}
+
+// EnclosureConfig contains the thermal policy for SES enclosure sensors (JBOD shelves, backplanes)
+type EnclosureConfig struct {
+	Enabled         bool     `yaml:"enabled"`          // Read /sys/class/enclosure devices with sg_ses every poll
+	Mode            string   `yaml:"mode"`             // emergency (only max_temp forces 100%) or control (also drives the PID)
+	TargetTemp      float64  `yaml:"target_temp"`      // Target temp in control mode (°C)
+	MaxTemp         float64  `yaml:"max_temp"`         // Emergency temp (°C)
+	ExcludePatterns []string `yaml:"exclude_patterns"` // Regex patterns for sensor names to ignore (<logical id>/temp<n>)
+}
+
+// DiskGroup overrides the thermal policy for the disks it matches
+type DiskGroup struct {
+	Name      string   `yaml:"name"`
+	Match     []string `yaml:"match"`      // Regex patterns matched against serial, model and by-id name
+	TargetHDD float64  `yaml:"target_hdd"` // Target temp for these disks (°C, 0 = temperature.target_hdd)
+	MaxHDD    float64  `yaml:"max_hdd"`    // Emergency temp for these disks (°C, 0 = temperature.max_hdd)
+	Weight    float64  `yaml:"weight"`     // Weight in the warmest-disk average (0 = 1)
+}
+
+// AutotuneConfig contains relay autotune experiment settings
+type AutotuneConfig struct {
+	HighDuty    int           `yaml:"high_duty"`    // Relay duty while above target (%)
+	LowDuty     int           `yaml:"low_duty"`     // Relay duty while below target (%)
+	Hysteresis  float64       `yaml:"hysteresis"`   // Relay switching band around target (°C)
+	Cycles      int           `yaml:"cycles"`       // Oscillation cycles to measure
+	MaxDuration time.Duration `yaml:"max_duration"` // Abort the experiment after this long
+	Rule        string        `yaml:"rule"`         // Tuning rule: ziegler_nichols, tyreus_luyben
+	Output      string        `yaml:"output"`       // File for the suggested config snippet (empty = stdout)
+}
+
+// WatchdogConfig contains dead-man switch settings for the control loop
+type WatchdogConfig struct {
+	Hardware       string        `yaml:"hardware"`        // Hardware watchdog petted every loop: none, device, ipmi
+	Device         string        `yaml:"device"`          // Watchdog device for hardware: device
+	Timeout        time.Duration `yaml:"timeout"`         // Countdown before the device or BMC resets the host
+	StallIntervals int           `yaml:"stall_intervals"` // Force 100% after this many poll intervals without a successful loop
+}
+
+// SensorFailureConfig contains the fan policy for consecutive sensor read failures
+type SensorFailureConfig struct {
+	TotalThreshold   int    `yaml:"total_threshold"`   // Polls in a row without usable readings before total_action
+	TotalAction      string `yaml:"total_action"`      // none (keep the current duty), failsafe or emergency (100%)
+	PartialThreshold int    `yaml:"partial_threshold"` // Polls in a row with an unreadable disk before partial_action
+	PartialAction    string `yaml:"partial_action"`    // none (PID on the readable disks), failsafe or emergency (100%)
+	FailsafeDuty     int    `yaml:"failsafe_duty"`     // Duty for the failsafe action; a floor under the PID for partial failures (%)
+}
+
+// PlausibilityConfig contains the checks applied to every reading before it is used
+type PlausibilityConfig struct {
+	HDD       SensorBounds `yaml:"hdd"`       // Accepted range for spinning disk temperatures
+	Flash     SensorBounds `yaml:"flash"`     // Accepted range for SSD/NVMe temperatures
+	Enclosure SensorBounds `yaml:"enclosure"` // Accepted range for SES enclosure sensors
+	CPU       SensorBounds `yaml:"cpu"`       // Accepted range for the CPU temperature
+	MaxRate   float64      `yaml:"max_rate"`  // Max change since the last accepted reading (°C per minute, 0 = no limit)
+	Median    bool         `yaml:"median"`    // Use the median of the last 3 accepted readings of each sensor
+}
+
+// SensorBounds is the range of temperatures a sensor can plausibly report (°C)
+type SensorBounds struct {
+	Min float64 `yaml:"min"`
+	Max float64 `yaml:"max"`
+}
+
+// NotifyConfig contains the notification targets for state changes
+type NotifyConfig struct {
+	RateLimit time.Duration  `yaml:"rate_limit"` // Min time between notifications for the same alert
+	Events    []string       `yaml:"events"`     // Events to send (empty = all)
+	StallRPM  int            `yaml:"stall_rpm"`  // A fan below this speed counts as stalled (RPM)
+	Webhook   WebhookConfig  `yaml:"webhook"`
+	Ntfy      NtfyConfig     `yaml:"ntfy"`
+	Pushover  PushoverConfig `yaml:"pushover"`
+	SMTP      SMTPConfig     `yaml:"smtp"`
+}
+
+// WebhookConfig posts notifications as JSON; enabled when url is set
+type WebhookConfig struct {
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"` // Extra request headers, e.g. Authorization
+}
+
+// NtfyConfig publishes notifications to an ntfy topic; enabled when topic is set
+type NtfyConfig struct {
+	Server string `yaml:"server"` // ntfy server URL
+	Topic  string `yaml:"topic"`
+	Token  string `yaml:"token"` // Access token for protected topics
+}
+
+// PushoverConfig sends notifications through Pushover; enabled when token is set
+type PushoverConfig struct {
+	Token string `yaml:"token"` // Application API token
+	User  string `yaml:"user"`  // User or group key
+}
+
+// SMTPConfig sends notifications by email; enabled when host is set
+type SMTPConfig struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	Username string   `yaml:"username"` // Plain auth when set
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+// MQTTConfig contains the MQTT connection and Home Assistant discovery settings
+type MQTTConfig struct {
+	Enabled         bool   `yaml:"enabled"`   // Publish state and accept commands over MQTT
+	Broker          string `yaml:"broker"`    // Broker URL: tcp://, ssl://, ws:// or wss://
+	ClientID        string `yaml:"client_id"` // MQTT client ID (default fan-controller-<node_id>)
+	Username        string `yaml:"username"`
+	Password        string `yaml:"password"`
+	TopicPrefix     string `yaml:"topic_prefix"`     // State and command topics live under <topic_prefix>/<node_id>
+	DiscoveryPrefix string `yaml:"discovery_prefix"` // Home Assistant discovery prefix
+	NodeID          string `yaml:"node_id"`          // Device ID in topics and Home Assistant (default hostname)
+	ReadOnly        bool   `yaml:"read_only"`        // Publish only; ignore mode, duty and setpoint commands
 }
 
 // LoadConfig loads and parses the configuration from a YAML file
@@ -83,6 +254,15 @@ func setDefaults(config *Config) {
 	if config.Server.LogLevel == "" {
 		config.Server.LogLevel = "info"
 	}
+	if config.Server.LogFormat == "" {
+		config.Server.LogFormat = LogFormatText
+	}
+	if config.Server.OnStartupFailure == "" {
+		config.Server.OnStartupFailure = StartupFailureExit
+	}
+	if config.Server.ShutdownTimeout == 0 {
+		config.Server.ShutdownTimeout = 5 * time.Second
+	}
 	if config.Temperature.TargetHDD == 0 {
 		config.Temperature.TargetHDD = 38.0
 	}
@@ -98,6 +278,15 @@ func setDefaults(config *Config) {
 	if config.Temperature.WarmestDisks == 0 {
 		config.Temperature.WarmestDisks = 4
 	}
+	if config.Temperature.Aggregate == "" {
+		config.Temperature.Aggregate = AggregateTopNMean
+	}
+	if config.Temperature.Percentile == 0 {
+		config.Temperature.Percentile = 90
+	}
+	if config.Temperature.EWMATimeConstant == 0 {
+		config.Temperature.EWMATimeConstant = 5 * time.Minute
+	}
 	if config.Fans.MinDuty == 0 {
 		config.Fans.MinDuty = 30
 	}
@@ -107,6 +296,12 @@ func setDefaults(config *Config) {
 	if config.Fans.StartupDuty == 0 {
 		config.Fans.StartupDuty = 50
 	}
+	if config.Fans.OnExit == "" {
+		config.Fans.OnExit = OnExitFull
+	}
+	if config.Fans.BMC == "" {
+		config.Fans.BMC = BMCASRock
+	}
 	if config.PID.Kp == 0 {
 		config.PID.Kp = 5.0
 	}
@@ -119,6 +314,135 @@ func setDefaults(config *Config) {
 	if config.PID.IntegralMax == 0 {
 		config.PID.IntegralMax = 50.0
 	}
+	if config.PID.Form == "" {
+		config.PID.Form = PIDFormLegacy // Preserve behaviour of configs written before pid.form existed
+	}
+	if config.PID.AntiWindup == "" {
+		config.PID.AntiWindup = AntiWindupClamp
+	}
+	if config.PID.TrackingGain == 0 {
+		config.PID.TrackingGain = 0.01
+	}
+	// Gains are pointers so an explicit 0 turns one input off instead of restoring the default
+	if config.PID.FeedForward.CPUGain == nil {
+		cpuGain := 0.1
+		config.PID.FeedForward.CPUGain = &cpuGain
+	}
+	if config.PID.FeedForward.IOGain == nil {
+		ioGain := 0.05
+		config.PID.FeedForward.IOGain = &ioGain
+	}
+	if config.PID.FeedForward.MaxBias == 0 {
+		config.PID.FeedForward.MaxBias = 20.0
+	}
+	if config.Autotune.HighDuty == 0 {
+		config.Autotune.HighDuty = config.Fans.MaxDuty
+	}
+	if config.Autotune.LowDuty == 0 {
+		config.Autotune.LowDuty = config.Fans.MinDuty
+	}
+	if config.Autotune.Hysteresis == 0 {
+		config.Autotune.Hysteresis = 0.5
+	}
+	if config.Autotune.Cycles == 0 {
+		config.Autotune.Cycles = 3
+	}
+	if config.Autotune.MaxDuration == 0 {
+		config.Autotune.MaxDuration = 6 * time.Hour
+	}
+	if config.Autotune.Rule == "" {
+		config.Autotune.Rule = TuningRuleTyreusLuyben
+	}
+	if config.Watchdog.Hardware == "" {
+		config.Watchdog.Hardware = WatchdogNone
+	}
+	if config.Watchdog.Device == "" {
+		config.Watchdog.Device = "/dev/watchdog"
+	}
+	if config.Watchdog.Timeout == 0 {
+		config.Watchdog.Timeout = 10 * time.Minute
+	}
+	if config.Watchdog.StallIntervals == 0 {
+		config.Watchdog.StallIntervals = 3
+	}
+	if config.Disks.Flash.Mode == "" {
+		config.Disks.Flash.Mode = SensorModeEmergency
+	}
+	if config.Disks.Flash.TargetTemp == 0 {
+		config.Disks.Flash.TargetTemp = 50.0
+	}
+	if config.Disks.Flash.MaxTemp == 0 {
+		config.Disks.Flash.MaxTemp = 65.0
+	}
+	if config.SensorFailure.TotalThreshold == 0 {
+		config.SensorFailure.TotalThreshold = 2
+	}
+	if config.SensorFailure.TotalAction == "" {
+		config.SensorFailure.TotalAction = SensorFailureEmergency
+	}
+	if config.SensorFailure.PartialThreshold == 0 {
+		config.SensorFailure.PartialThreshold = 3
+	}
+	if config.SensorFailure.PartialAction == "" {
+		config.SensorFailure.PartialAction = SensorFailureNone
+	}
+	if config.SensorFailure.FailsafeDuty == 0 {
+		config.SensorFailure.FailsafeDuty = 80
+	}
+	for _, bounds := range []struct {
+		bounds   *SensorBounds
+		min, max float64
+	}{
+		{&config.Plausibility.HDD, 1, 100},
+		{&config.Plausibility.Flash, 1, 125},
+		{&config.Plausibility.Enclosure, 1, 100},
+		{&config.Plausibility.CPU, 1, 125},
+	} {
+		if bounds.bounds.Min == 0 {
+			bounds.bounds.Min = bounds.min
+		}
+		if bounds.bounds.Max == 0 {
+			bounds.bounds.Max = bounds.max
+		}
+	}
+	if config.Notify.RateLimit == 0 {
+		config.Notify.RateLimit = 15 * time.Minute
+	}
+	if config.Notify.StallRPM == 0 {
+		config.Notify.StallRPM = 100
+	}
+	if config.Notify.Ntfy.Server == "" {
+		config.Notify.Ntfy.Server = "https://ntfy.sh"
+	}
+	if config.Notify.SMTP.Port == 0 {
+		config.Notify.SMTP.Port = 587
+	}
+	if config.MQTT.TopicPrefix == "" {
+		config.MQTT.TopicPrefix = "fan-controller"
+	}
+	if config.MQTT.DiscoveryPrefix == "" {
+		config.MQTT.DiscoveryPrefix = "homeassistant"
+	}
+	if config.Enclosures.Mode == "" {
+		config.Enclosures.Mode = SensorModeEmergency
+	}
+	if config.Enclosures.TargetTemp == 0 {
+		config.Enclosures.TargetTemp = 40.0
+	}
+	if config.Enclosures.MaxTemp == 0 {
+		config.Enclosures.MaxTemp = 55.0
+	}
+	if config.Disks.Hotplug.OnRemoval == "" {
+		config.Disks.Hotplug.OnRemoval = OnRemovalLog
+	}
+	if config.Disks.Hotplug.WarningDuty == 0 {
+		config.Disks.Hotplug.WarningDuty = 80
+	}
+	for i := range config.Disks.Groups {
+		if config.Disks.Groups[i].Weight == 0 {
+			config.Disks.Groups[i].Weight = 1
+		}
+	}
 	if len(config.Disks.ExcludePatterns) == 0 {
 		config.Disks.ExcludePatterns = []string{
 			"^loop",
@@ -134,7 +458,7 @@ func setDefaults(config *Config) {
 func (c *Config) Validate() error {
 	// Temperature validation
 	if c.Temperature.TargetHDD >= c.Temperature.MaxHDD {
-		return fmt.Errorf("target_hdd (%.1f) must be less than max_hdd (%.1f)", 
+		return fmt.Errorf("target_hdd (%.1f) must be less than max_hdd (%.1f)",
 			c.Temperature.TargetHDD, c.Temperature.MaxHDD)
 	}
 	if c.Temperature.TargetHDD <= 0 {
@@ -152,6 +476,17 @@ func (c *Config) Validate() error {
 	if c.Temperature.WarmestDisks <= 0 {
 		return fmt.Errorf("warmest_disks must be positive, got %d", c.Temperature.WarmestDisks)
 	}
+	switch c.Temperature.Aggregate {
+	case "", AggregateTopNMean, AggregateMax, AggregatePercentile, AggregateEWMA, AggregateMargin:
+	default:
+		return fmt.Errorf("aggregate must be one of: top_n_mean, max, percentile, ewma, margin, got %s", c.Temperature.Aggregate)
+	}
+	if c.Temperature.Percentile < 0 || c.Temperature.Percentile > 100 {
+		return fmt.Errorf("percentile must be between 0-100, got %.1f", c.Temperature.Percentile)
+	}
+	if c.Temperature.EWMATimeConstant < 0 {
+		return fmt.Errorf("ewma_time_constant must be positive, got %v", c.Temperature.EWMATimeConstant)
+	}
 
 	// Fan validation
 	if c.Fans.MinDuty < 0 || c.Fans.MinDuty > 100 {
@@ -164,9 +499,19 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("startup_duty must be between 0-100, got %d", c.Fans.StartupDuty)
 	}
 	if c.Fans.MinDuty >= c.Fans.MaxDuty {
-		return fmt.Errorf("min_duty (%d) must be less than max_duty (%d)", 
+		return fmt.Errorf("min_duty (%d) must be less than max_duty (%d)",
 			c.Fans.MinDuty, c.Fans.MaxDuty)
 	}
+	switch c.Fans.OnExit {
+	case "", OnExitFull, OnExitBMCAuto, OnExitHold:
+	default:
+		return fmt.Errorf("on_exit must be one of: full, bmc_auto, hold, got %s", c.Fans.OnExit)
+	}
+	switch c.Fans.BMC {
+	case "", BMCASRock, BMCSupermicro:
+	default:
+		return fmt.Errorf("bmc must be one of: asrock, supermicro, got %s", c.Fans.BMC)
+	}
 
 	// PID validation
 	if c.PID.Kp < 0 {
@@ -181,15 +526,249 @@ func (c *Config) Validate() error {
 	if c.PID.IntegralMax <= 0 {
 		return fmt.Errorf("integral_max must be positive, got %.3f", c.PID.IntegralMax)
 	}
+	switch c.PID.Form {
+	case "", PIDFormLegacy, PIDFormStandard:
+	default:
+		return fmt.Errorf("form must be one of: legacy, standard, got %s", c.PID.Form)
+	}
+	switch c.PID.AntiWindup {
+	case "", AntiWindupClamp, AntiWindupConditional, AntiWindupBackCalculation:
+	default:
+		return fmt.Errorf("anti_windup must be one of: clamp, conditional, back_calculation, got %s", c.PID.AntiWindup)
+	}
+	if c.PID.TrackingGain < 0 {
+		return fmt.Errorf("tracking_gain must be non-negative, got %.3f", c.PID.TrackingGain)
+	}
+	if c.PID.DerivativeFilter < 0 {
+		return fmt.Errorf("derivative_filter must be non-negative, got %v", c.PID.DerivativeFilter)
+	}
+	if gain := c.PID.FeedForward.CPUGain; gain != nil && *gain < 0 {
+		return fmt.Errorf("feed_forward.cpu_gain must be non-negative, got %.3f", *gain)
+	}
+	if gain := c.PID.FeedForward.IOGain; gain != nil && *gain < 0 {
+		return fmt.Errorf("feed_forward.io_gain must be non-negative, got %.3f", *gain)
+	}
+	if c.PID.FeedForward.MaxBias < 0 || c.PID.FeedForward.MaxBias > 100 {
+		return fmt.Errorf("feed_forward.max_bias must be between 0-100, got %.1f", c.PID.FeedForward.MaxBias)
+	}
+
+	// Autotune validation (zero values are allowed when defaults were not applied)
+	if c.Autotune.HighDuty < 0 || c.Autotune.HighDuty > 100 {
+		return fmt.Errorf("autotune.high_duty must be between 0-100, got %d", c.Autotune.HighDuty)
+	}
+	if c.Autotune.LowDuty < 0 || c.Autotune.LowDuty > 100 {
+		return fmt.Errorf("autotune.low_duty must be between 0-100, got %d", c.Autotune.LowDuty)
+	}
+	if c.Autotune.HighDuty != 0 && c.Autotune.LowDuty >= c.Autotune.HighDuty {
+		return fmt.Errorf("autotune.low_duty (%d) must be less than autotune.high_duty (%d)",
+			c.Autotune.LowDuty, c.Autotune.HighDuty)
+	}
+	if c.Autotune.Hysteresis < 0 {
+		return fmt.Errorf("autotune.hysteresis must be non-negative, got %.2f", c.Autotune.Hysteresis)
+	}
+	if c.Autotune.Cycles < 0 {
+		return fmt.Errorf("autotune.cycles must be positive, got %d", c.Autotune.Cycles)
+	}
+	switch c.Autotune.Rule {
+	case "", TuningRuleZieglerNichols, TuningRuleTyreusLuyben:
+	default:
+		return fmt.Errorf("autotune.rule must be one of: ziegler_nichols, tyreus_luyben, got %s", c.Autotune.Rule)
+	}
+
+	// Watchdog validation
+	switch c.Watchdog.Hardware {
+	case "", WatchdogNone:
+	case WatchdogDevice:
+		if c.Watchdog.Timeout <= c.Temperature.PollInterval {
+			return fmt.Errorf("watchdog.timeout must be greater than poll_interval (%v), got %v",
+				c.Temperature.PollInterval, c.Watchdog.Timeout)
+		}
+	case WatchdogIPMI:
+		if c.Watchdog.Timeout <= c.Temperature.PollInterval || c.Watchdog.Timeout > maxIPMIWatchdogTimeout {
+			return fmt.Errorf("watchdog.timeout must be greater than poll_interval (%v) and at most %v, got %v",
+				c.Temperature.PollInterval, maxIPMIWatchdogTimeout, c.Watchdog.Timeout)
+		}
+	default:
+		return fmt.Errorf("watchdog.hardware must be one of: none, device, ipmi, got %s", c.Watchdog.Hardware)
+	}
+	if c.Watchdog.StallIntervals < 0 {
+		return fmt.Errorf("watchdog.stall_intervals must be positive, got %d", c.Watchdog.StallIntervals)
+	}
+
+	// Disk validation
+	for _, pattern := range c.Disks.IncludePatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("include_patterns: invalid pattern %q: %v", pattern, err)
+		}
+	}
+	groups := make(map[string]bool, len(c.Disks.Groups))
+	for _, group := range c.Disks.Groups {
+		if group.Name == "" {
+			return fmt.Errorf("disk groups must have a name")
+		}
+		if groups[group.Name] {
+			return fmt.Errorf("disk group names must be unique, got %s twice", group.Name)
+		}
+		groups[group.Name] = true
+		if len(group.Match) == 0 {
+			return fmt.Errorf("disk group %s must have at least one match pattern", group.Name)
+		}
+		for _, pattern := range group.Match {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("disk group %s: invalid match pattern %q: %v", group.Name, pattern, err)
+			}
+		}
+		policy := group.policy(c.Temperature)
+		if policy.Target <= 0 || policy.Target >= policy.Max {
+			return fmt.Errorf("disk group %s: target_hdd (%.1f) must be positive and less than max_hdd (%.1f)",
+				group.Name, policy.Target, policy.Max)
+		}
+		if group.Weight < 0 {
+			return fmt.Errorf("disk group %s: weight must be non-negative, got %.2f", group.Name, group.Weight)
+		}
+	}
+
+	switch c.Disks.Flash.Mode {
+	case "", SensorModeEmergency, SensorModeControl:
+	default:
+		return fmt.Errorf("flash.mode must be one of: emergency, control, got %s", c.Disks.Flash.Mode)
+	}
+	if c.Disks.Flash.Enabled && (c.Disks.Flash.TargetTemp <= 0 || c.Disks.Flash.TargetTemp >= c.Disks.Flash.MaxTemp) {
+		return fmt.Errorf("flash.target_temp (%.1f) must be positive and less than flash.max_temp (%.1f)",
+			c.Disks.Flash.TargetTemp, c.Disks.Flash.MaxTemp)
+	}
+
+	switch c.Enclosures.Mode {
+	case "", SensorModeEmergency, SensorModeControl:
+	default:
+		return fmt.Errorf("enclosures.mode must be one of: emergency, control, got %s", c.Enclosures.Mode)
+	}
+	if c.Enclosures.Enabled && (c.Enclosures.TargetTemp <= 0 || c.Enclosures.TargetTemp >= c.Enclosures.MaxTemp) {
+		return fmt.Errorf("enclosures.target_temp (%.1f) must be positive and less than enclosures.max_temp (%.1f)",
+			c.Enclosures.TargetTemp, c.Enclosures.MaxTemp)
+	}
+	for _, pattern := range c.Enclosures.ExcludePatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("enclosures.exclude_patterns: invalid pattern %q: %v", pattern, err)
+		}
+	}
+
+	for _, action := range []struct{ name, value string }{
+		{"total_action", c.SensorFailure.TotalAction},
+		{"partial_action", c.SensorFailure.PartialAction},
+	} {
+		switch action.value {
+		case "", SensorFailureNone, SensorFailureFailsafe, SensorFailureEmergency:
+		default:
+			return fmt.Errorf("sensor_failure.%s must be one of: none, failsafe, emergency, got %s", action.name, action.value)
+		}
+	}
+	if c.SensorFailure.TotalThreshold < 0 || c.SensorFailure.PartialThreshold < 0 {
+		return fmt.Errorf("sensor_failure thresholds must be positive, got total %d, partial %d",
+			c.SensorFailure.TotalThreshold, c.SensorFailure.PartialThreshold)
+	}
+	if c.SensorFailure.FailsafeDuty < 0 || c.SensorFailure.FailsafeDuty > 100 {
+		return fmt.Errorf("sensor_failure.failsafe_duty must be between 0-100, got %d", c.SensorFailure.FailsafeDuty)
+	}
+
+	for _, source := range []struct {
+		name   string
+		bounds SensorBounds
+	}{
+		{SourceHDD, c.Plausibility.HDD},
+		{SourceFlash, c.Plausibility.Flash},
+		{SourceEnclosure, c.Plausibility.Enclosure},
+		{SourceCPU, c.Plausibility.CPU},
+	} {
+		if source.bounds.Min > source.bounds.Max {
+			return fmt.Errorf("plausibility.%s.min (%.1f) must not exceed plausibility.%s.max (%.1f)",
+				source.name, source.bounds.Min, source.name, source.bounds.Max)
+		}
+	}
+	if c.Plausibility.MaxRate < 0 {
+		return fmt.Errorf("plausibility.max_rate must be non-negative, got %.1f", c.Plausibility.MaxRate)
+	}
+
+	if c.Notify.RateLimit < 0 {
+		return fmt.Errorf("notify.rate_limit must be non-negative, got %v", c.Notify.RateLimit)
+	}
+	for _, event := range c.Notify.Events {
+		if !slices.Contains(notificationEvents, event) {
+			return fmt.Errorf("notify.events must be from: %s, got %s", strings.Join(notificationEvents, ", "), event)
+		}
+	}
+	if c.Notify.StallRPM < 0 {
+		return fmt.Errorf("notify.stall_rpm must be non-negative, got %d", c.Notify.StallRPM)
+	}
+	if c.Notify.Webhook.URL != "" {
+		if u, err := url.Parse(c.Notify.Webhook.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("notify.webhook.url must be an absolute URL, got %s", c.Notify.Webhook.URL)
+		}
+	}
+	if c.Notify.Pushover.Token != "" && c.Notify.Pushover.User == "" {
+		return fmt.Errorf("notify.pushover.user is required with notify.pushover.token")
+	}
+	if c.Notify.SMTP.Host != "" && (c.Notify.SMTP.From == "" || len(c.Notify.SMTP.To) == 0) {
+		return fmt.Errorf("notify.smtp.from and notify.smtp.to are required with notify.smtp.host")
+	}
+	if c.Notify.SMTP.Port < 0 || c.Notify.SMTP.Port > 65535 {
+		return fmt.Errorf("notify.smtp.port must be between 1-65535, got %d", c.Notify.SMTP.Port)
+	}
+
+	if c.MQTT.Enabled {
+		u, err := url.Parse(c.MQTT.Broker)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("mqtt.broker must be a URL like tcp://host:1883, got %s", c.MQTT.Broker)
+		}
+		switch u.Scheme {
+		case "tcp", "mqtt", "ssl", "tls", "mqtts", "ws", "wss":
+		default:
+			return fmt.Errorf("mqtt.broker scheme must be one of: tcp, mqtt, ssl, tls, mqtts, ws, wss, got %s", u.Scheme)
+		}
+	}
+	for _, prefix := range []struct{ name, value string }{
+		{"topic_prefix", c.MQTT.TopicPrefix},
+		{"discovery_prefix", c.MQTT.DiscoveryPrefix},
+		{"node_id", c.MQTT.NodeID},
+	} {
+		if strings.ContainsAny(prefix.value, "+#") {
+			return fmt.Errorf("mqtt.%s must not contain MQTT wildcards, got %s", prefix.name, prefix.value)
+		}
+	}
+
+	switch c.Disks.Hotplug.OnRemoval {
+	case "", OnRemovalLog, OnRemovalWarn:
+	default:
+		return fmt.Errorf("hotplug.on_removal must be one of: log, warn, got %s", c.Disks.Hotplug.OnRemoval)
+	}
+	if c.Disks.Hotplug.WarningDuty < 0 || c.Disks.Hotplug.WarningDuty > 100 {
+		return fmt.Errorf("hotplug.warning_duty must be between 0-100, got %d", c.Disks.Hotplug.WarningDuty)
+	}
+	if c.Disks.Hotplug.WarningHold < 0 {
+		return fmt.Errorf("hotplug.warning_hold must be non-negative, got %v", c.Disks.Hotplug.WarningHold)
+	}
 
 	// Server validation
 	if c.Server.MetricsPort <= 0 || c.Server.MetricsPort > 65535 {
 		return fmt.Errorf("metrics_port must be between 1-65535, got %d", c.Server.MetricsPort)
 	}
-	if c.Server.LogLevel != "debug" && c.Server.LogLevel != "info" && 
-	   c.Server.LogLevel != "warn" && c.Server.LogLevel != "error" {
+	if c.Server.LogLevel != "debug" && c.Server.LogLevel != "info" &&
+		c.Server.LogLevel != "warn" && c.Server.LogLevel != "error" {
 		return fmt.Errorf("log_level must be one of: debug, info, warn, error, got %s", c.Server.LogLevel)
 	}
+	switch c.Server.LogFormat {
+	case "", LogFormatText, LogFormatJSON:
+	default:
+		return fmt.Errorf("log_format must be one of: text, json, got %s", c.Server.LogFormat)
+	}
+	switch c.Server.OnStartupFailure {
+	case "", StartupFailureExit, StartupFailureDegraded:
+	default:
+		return fmt.Errorf("on_startup_failure must be one of: exit, degraded, got %s", c.Server.OnStartupFailure)
+	}
+	if c.Server.ShutdownTimeout < 0 {
+		return fmt.Errorf("shutdown_timeout must be positive, got %v", c.Server.ShutdownTimeout)
+	}
 
 	return nil
 }
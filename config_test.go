@@ -152,6 +152,13 @@ func TestSetDefaults_AllFieldsSet(t *testing.T) {
 	assert.Equal(t, 0.1, config.PID.Ki)
 	assert.Equal(t, 20.0, config.PID.Kd)
 	assert.Equal(t, 50.0, config.PID.IntegralMax)
+	assert.Equal(t, PIDFormLegacy, config.PID.Form)
+	assert.Equal(t, AntiWindupClamp, config.PID.AntiWindup)
+	assert.Equal(t, 0.01, config.PID.TrackingGain)
+	assert.False(t, config.PID.FeedForward.Enabled)
+	assert.Equal(t, 0.1, *config.PID.FeedForward.CPUGain)
+	assert.Equal(t, 0.05, *config.PID.FeedForward.IOGain)
+	assert.Equal(t, 20.0, config.PID.FeedForward.MaxBias)
 	assert.Len(t, config.Disks.ExcludePatterns, 5)
 }
 
@@ -189,6 +196,14 @@ func TestSetDefaults_EmptyConfig(t *testing.T) {
 	// Assert - all defaults should be set
 	assert.NotZero(t, config.Server.MetricsPort)
 	assert.NotEmpty(t, config.Server.LogLevel)
+	assert.Equal(t, 5*time.Second, config.Server.ShutdownTimeout)
+	assert.Equal(t, LogFormatText, config.Server.LogFormat)
+	assert.Equal(t, StartupFailureExit, config.Server.OnStartupFailure)
+	assert.Equal(t, AggregateTopNMean, config.Temperature.Aggregate)
+	assert.Equal(t, 90.0, config.Temperature.Percentile)
+	assert.Equal(t, 5*time.Minute, config.Temperature.EWMATimeConstant)
+	assert.Equal(t, OnExitFull, config.Fans.OnExit)
+	assert.Equal(t, BMCASRock, config.Fans.BMC)
 	assert.NotZero(t, config.Temperature.TargetHDD)
 	assert.NotZero(t, config.Fans.MinDuty)
 	assert.NotZero(t, config.PID.Kp)
@@ -606,6 +621,463 @@ func TestValidate_InvalidLogLevel_Error(t *testing.T) {
 	assert.Contains(t, err.Error(), "log_level must be one of")
 }
 
+// TestValidate_NegativeShutdownTimeout_Error tests validation error
+func TestValidate_NegativeShutdownTimeout_Error(t *testing.T) {
+	// Arrange
+	config := &Config{Server: ServerConfig{ShutdownTimeout: -time.Second}}
+	setDefaults(config)
+
+	// Act
+	err := config.Validate()
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "shutdown_timeout must be positive")
+}
+
+// TestValidate_FanExitAction_Error tests on_exit and bmc validation
+func TestValidate_FanExitAction_Error(t *testing.T) {
+	tests := []struct {
+		name     string
+		fans     FanConfig
+		errorMsg string
+	}{
+		{"unknown on_exit", FanConfig{OnExit: "off"}, "on_exit must be one of"},
+		{"unknown bmc", FanConfig{OnExit: OnExitBMCAuto, BMC: "dell"}, "bmc must be one of"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := &Config{Fans: tt.fans}
+			setDefaults(config)
+
+			// Act
+			err := config.Validate()
+
+			// Assert
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+// TestValidate_Watchdog_Error tests watchdog validation
+func TestValidate_Watchdog_Error(t *testing.T) {
+	tests := []struct {
+		name     string
+		watchdog WatchdogConfig
+		errorMsg string
+	}{
+		{"unknown hardware", WatchdogConfig{Hardware: "softdog"}, "watchdog.hardware must be one of"},
+		{"ipmi timeout below poll interval", WatchdogConfig{Hardware: WatchdogIPMI, Timeout: 10 * time.Second}, "watchdog.timeout must be greater than poll_interval"},
+		{"ipmi timeout too long", WatchdogConfig{Hardware: WatchdogIPMI, Timeout: 2 * time.Hour}, "watchdog.timeout must be greater than poll_interval"},
+		{"device timeout below poll interval", WatchdogConfig{Hardware: WatchdogDevice, Timeout: 30 * time.Second}, "watchdog.timeout must be greater than poll_interval"},
+		{"negative stall intervals", WatchdogConfig{StallIntervals: -1}, "watchdog.stall_intervals must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := &Config{Watchdog: tt.watchdog}
+			setDefaults(config)
+
+			// Act
+			err := config.Validate()
+
+			// Assert
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+// TestValidate_InvalidLogFormat_Error tests validation error
+func TestValidate_InvalidLogFormat_Error(t *testing.T) {
+	// Arrange
+	config := &Config{Server: ServerConfig{LogFormat: "logfmt"}}
+	setDefaults(config)
+
+	// Act
+	err := config.Validate()
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "log_format must be one of")
+}
+
+// TestValidate_InvalidOnStartupFailure_Error tests validation error
+func TestValidate_InvalidOnStartupFailure_Error(t *testing.T) {
+	// Arrange
+	config := &Config{Server: ServerConfig{OnStartupFailure: "retry"}}
+	setDefaults(config)
+
+	// Act
+	err := config.Validate()
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "on_startup_failure must be one of")
+}
+
+// TestValidate_Aggregate_Error tests aggregation strategy validation
+func TestValidate_Aggregate_Error(t *testing.T) {
+	tests := []struct {
+		name        string
+		temperature TemperatureConfig
+		errorMsg    string
+	}{
+		{"unknown aggregate", TemperatureConfig{Aggregate: "median"}, "aggregate must be one of"},
+		{"percentile above 100", TemperatureConfig{Percentile: 101}, "percentile must be between 0-100"},
+		{"negative ewma time constant", TemperatureConfig{EWMATimeConstant: -time.Minute}, "ewma_time_constant must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := &Config{Temperature: tt.temperature}
+			setDefaults(config)
+
+			// Act
+			err := config.Validate()
+
+			// Assert
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+// TestValidate_DiskGroups_Error tests include pattern and disk group validation
+func TestValidate_DiskGroups_Error(t *testing.T) {
+	tests := []struct {
+		name     string
+		disks    DiskConfig
+		errorMsg string
+	}{
+		{"invalid include pattern", DiskConfig{IncludePatterns: []string{"(sd"}}, "include_patterns: invalid pattern"},
+		{"missing name", DiskConfig{Groups: []DiskGroup{{Match: []string{"^ST"}}}}, "must have a name"},
+		{"duplicate name", DiskConfig{Groups: []DiskGroup{{Name: "a", Match: []string{"^ST"}}, {Name: "a", Match: []string{"^WD"}}}}, "must be unique"},
+		{"no match patterns", DiskConfig{Groups: []DiskGroup{{Name: "a"}}}, "at least one match pattern"},
+		{"invalid match pattern", DiskConfig{Groups: []DiskGroup{{Name: "a", Match: []string{"[ST"}}}}, "invalid match pattern"},
+		{"target above group max", DiskConfig{Groups: []DiskGroup{{Name: "a", Match: []string{"^ST"}, TargetHDD: 50, MaxHDD: 48}}}, "must be positive and less than max_hdd"},
+		{"target above default max", DiskConfig{Groups: []DiskGroup{{Name: "a", Match: []string{"^ST"}, TargetHDD: 50}}}, "must be positive and less than max_hdd"},
+		{"negative weight", DiskConfig{Groups: []DiskGroup{{Name: "a", Match: []string{"^ST"}, Weight: -1}}}, "weight must be non-negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := &Config{Disks: tt.disks}
+			setDefaults(config)
+
+			// Act
+			err := config.Validate()
+
+			// Assert
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+// TestValidate_Flash_Error tests flash policy validation
+func TestValidate_Flash_Error(t *testing.T) {
+	tests := []struct {
+		name     string
+		flash    FlashConfig
+		errorMsg string
+	}{
+		{"unknown mode", FlashConfig{Mode: "pid"}, "flash.mode must be one of"},
+		{"target above max", FlashConfig{Enabled: true, TargetTemp: 70, MaxTemp: 65}, "must be positive and less than flash.max_temp"},
+		{"negative target", FlashConfig{Enabled: true, TargetTemp: -5}, "must be positive and less than flash.max_temp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := &Config{Disks: DiskConfig{Flash: tt.flash}}
+			setDefaults(config)
+
+			// Act
+			err := config.Validate()
+
+			// Assert
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+// TestSetDefaults_Flash tests the flash policy defaults
+func TestSetDefaults_Flash(t *testing.T) {
+	// Arrange
+	config := &Config{}
+
+	// Act
+	setDefaults(config)
+
+	// Assert
+	assert.False(t, config.Disks.Flash.Enabled)
+	assert.Equal(t, SensorModeEmergency, config.Disks.Flash.Mode)
+	assert.Equal(t, 50.0, config.Disks.Flash.TargetTemp)
+	assert.Equal(t, 65.0, config.Disks.Flash.MaxTemp)
+}
+
+// TestValidate_Enclosures_Error tests SES enclosure policy validation
+func TestValidate_Enclosures_Error(t *testing.T) {
+	tests := []struct {
+		name       string
+		enclosures EnclosureConfig
+		errorMsg   string
+	}{
+		{"unknown mode", EnclosureConfig{Mode: "pid"}, "enclosures.mode must be one of"},
+		{"target above max", EnclosureConfig{Enabled: true, TargetTemp: 60, MaxTemp: 55}, "must be positive and less than enclosures.max_temp"},
+		{"invalid exclude pattern", EnclosureConfig{ExcludePatterns: []string{"(temp"}}, "enclosures.exclude_patterns: invalid pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := &Config{Enclosures: tt.enclosures}
+			setDefaults(config)
+
+			// Act
+			err := config.Validate()
+
+			// Assert
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+// TestValidate_SensorFailure_Error tests sensor failure policy validation
+func TestValidate_SensorFailure_Error(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   SensorFailureConfig
+		errorMsg string
+	}{
+		{"unknown total action", SensorFailureConfig{TotalAction: "restart"}, "sensor_failure.total_action must be one of"},
+		{"unknown partial action", SensorFailureConfig{PartialAction: "hold"}, "sensor_failure.partial_action must be one of"},
+		{"negative threshold", SensorFailureConfig{PartialThreshold: -1}, "thresholds must be positive"},
+		{"failsafe duty above 100", SensorFailureConfig{FailsafeDuty: 150}, "failsafe_duty must be between 0-100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := &Config{SensorFailure: tt.policy}
+			setDefaults(config)
+
+			// Act
+			err := config.Validate()
+
+			// Assert
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+// TestSetDefaults_SensorFailure tests the sensor failure policy defaults
+func TestSetDefaults_SensorFailure(t *testing.T) {
+	// Arrange
+	config := &Config{}
+
+	// Act
+	setDefaults(config)
+
+	// Assert
+	assert.Equal(t, SensorFailureConfig{
+		TotalThreshold:   2,
+		TotalAction:      SensorFailureEmergency,
+		PartialThreshold: 3,
+		PartialAction:    SensorFailureNone,
+		FailsafeDuty:     80,
+	}, config.SensorFailure)
+}
+
+// TestValidate_Plausibility_Error tests plausibility filter validation
+func TestValidate_Plausibility_Error(t *testing.T) {
+	tests := []struct {
+		name         string
+		plausibility PlausibilityConfig
+		errorMsg     string
+	}{
+		{"inverted hdd bounds", PlausibilityConfig{HDD: SensorBounds{Min: 60, Max: 20}}, "plausibility.hdd.min (60.0) must not exceed"},
+		{"cpu min above default max", PlausibilityConfig{CPU: SensorBounds{Min: 130}}, "plausibility.cpu.min"},
+		{"negative rate", PlausibilityConfig{MaxRate: -1}, "plausibility.max_rate must be non-negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := &Config{Plausibility: tt.plausibility}
+			setDefaults(config)
+
+			// Act
+			err := config.Validate()
+
+			// Assert
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+// TestSetDefaults_Plausibility tests the per-source bounds defaults
+func TestSetDefaults_Plausibility(t *testing.T) {
+	// Arrange
+	config := &Config{Plausibility: PlausibilityConfig{HDD: SensorBounds{Max: 90}}}
+
+	// Act
+	setDefaults(config)
+
+	// Assert
+	assert.Equal(t, PlausibilityConfig{
+		HDD:       SensorBounds{Min: 1, Max: 90},
+		Flash:     SensorBounds{Min: 1, Max: 125},
+		Enclosure: SensorBounds{Min: 1, Max: 100},
+		CPU:       SensorBounds{Min: 1, Max: 125},
+	}, config.Plausibility)
+}
+
+// TestValidate_Notify_Error tests notification target validation
+func TestValidate_Notify_Error(t *testing.T) {
+	tests := []struct {
+		name     string
+		notify   NotifyConfig
+		errorMsg string
+	}{
+		{"unknown event", NotifyConfig{Events: []string{"disk_hot"}}, "notify.events must be from"},
+		{"negative rate limit", NotifyConfig{RateLimit: -time.Minute}, "notify.rate_limit must be non-negative"},
+		{"relative webhook url", NotifyConfig{Webhook: WebhookConfig{URL: "/hook"}}, "notify.webhook.url must be an absolute URL"},
+		{"pushover without user", NotifyConfig{Pushover: PushoverConfig{Token: "app"}}, "notify.pushover.user is required"},
+		{"smtp without recipients", NotifyConfig{SMTP: SMTPConfig{Host: "mail.lan", From: "fans@nas.lan"}}, "notify.smtp.from and notify.smtp.to are required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := &Config{Notify: tt.notify}
+			setDefaults(config)
+
+			// Act
+			err := config.Validate()
+
+			// Assert
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+// TestSetDefaults_Notify tests the notification defaults
+func TestSetDefaults_Notify(t *testing.T) {
+	// Arrange
+	config := &Config{}
+
+	// Act
+	setDefaults(config)
+
+	// Assert
+	assert.Equal(t, 15*time.Minute, config.Notify.RateLimit)
+	assert.Equal(t, 100, config.Notify.StallRPM)
+	assert.Equal(t, "https://ntfy.sh", config.Notify.Ntfy.Server)
+	assert.Equal(t, 587, config.Notify.SMTP.Port)
+	assert.Empty(t, NewNotifiers(config.Notify), "no target is enabled by default")
+}
+
+// TestValidate_MQTT_Error tests MQTT broker and topic validation
+func TestValidate_MQTT_Error(t *testing.T) {
+	tests := []struct {
+		name     string
+		mqtt     MQTTConfig
+		errorMsg string
+	}{
+		{"missing broker", MQTTConfig{Enabled: true}, "mqtt.broker must be a URL"},
+		{"broker without scheme", MQTTConfig{Enabled: true, Broker: "broker.lan:1883"}, "mqtt.broker"},
+		{"http broker", MQTTConfig{Enabled: true, Broker: "http://broker.lan"}, "mqtt.broker scheme must be one of"},
+		{"wildcard topic prefix", MQTTConfig{TopicPrefix: "fans/#"}, "mqtt.topic_prefix must not contain MQTT wildcards"},
+		{"wildcard node id", MQTTConfig{NodeID: "nas+1"}, "mqtt.node_id must not contain MQTT wildcards"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := &Config{MQTT: tt.mqtt}
+			setDefaults(config)
+
+			// Act
+			err := config.Validate()
+
+			// Assert
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+// TestSetDefaults_MQTT tests the MQTT defaults and that a disabled bridge needs no broker
+func TestSetDefaults_MQTT(t *testing.T) {
+	// Arrange
+	config := &Config{}
+
+	// Act
+	setDefaults(config)
+
+	// Assert
+	assert.False(t, config.MQTT.Enabled)
+	assert.Equal(t, "fan-controller", config.MQTT.TopicPrefix)
+	assert.Equal(t, "homeassistant", config.MQTT.DiscoveryPrefix)
+	assert.NoError(t, config.Validate())
+	config.MQTT = MQTTConfig{Enabled: true, Broker: "ssl://broker.lan:8883", TopicPrefix: "fans", DiscoveryPrefix: "homeassistant"}
+	assert.NoError(t, config.Validate())
+}
+
+// TestValidate_Hotplug_Error tests disk hotplug policy validation
+func TestValidate_Hotplug_Error(t *testing.T) {
+	tests := []struct {
+		name     string
+		hotplug  HotplugConfig
+		errorMsg string
+	}{
+		{"unknown policy", HotplugConfig{OnRemoval: "emergency"}, "hotplug.on_removal must be one of"},
+		{"warning duty above 100", HotplugConfig{WarningDuty: 120}, "hotplug.warning_duty must be between 0-100"},
+		{"negative hold", HotplugConfig{WarningHold: -time.Minute}, "hotplug.warning_hold must be non-negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := &Config{Disks: DiskConfig{Hotplug: tt.hotplug}}
+			setDefaults(config)
+
+			// Act
+			err := config.Validate()
+
+			// Assert
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+// TestSetDefaults_DiskGroupWeight tests that unset group weights default to 1
+func TestSetDefaults_DiskGroupWeight(t *testing.T) {
+	// Arrange
+	config := &Config{Disks: DiskConfig{Groups: []DiskGroup{{Name: "a"}, {Name: "b", Weight: 0.5}}}}
+
+	// Act
+	setDefaults(config)
+
+	// Assert
+	assert.Equal(t, 1.0, config.Disks.Groups[0].Weight)
+	assert.Equal(t, 0.5, config.Disks.Groups[1].Weight)
+}
+
 // TestValidate_AllFieldsValid tests that valid config passes validation
 func TestValidate_AllFieldsValid(t *testing.T) {
 	// Arrange
@@ -697,6 +1169,148 @@ disks:
 	assert.Equal(t, []string{"^loop", "^zd", "^dm-"}, config.Disks.ExcludePatterns)
 }
 
+// TestValidate_FeedForward_Error tests feed-forward validation
+func TestValidate_FeedForward_Error(t *testing.T) {
+	tests := []struct {
+		name        string
+		feedForward FeedForwardConfig
+		errContains string
+	}{
+		{"negative cpu gain", FeedForwardConfig{CPUGain: float64Ptr(-0.1)}, "feed_forward.cpu_gain"},
+		{"negative io gain", FeedForwardConfig{IOGain: float64Ptr(-0.1)}, "feed_forward.io_gain"},
+		{"max bias above 100", FeedForwardConfig{MaxBias: 150}, "feed_forward.max_bias"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := &Config{PID: PIDConfig{FeedForward: tt.feedForward}}
+			setDefaults(config)
+
+			// Act
+			err := config.Validate()
+
+			// Assert
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errContains)
+		})
+	}
+}
+
+// TestLoadConfig_FeedForwardZeroGain tests that an explicit zero gain turns that input off
+func TestLoadConfig_FeedForwardZeroGain(t *testing.T) {
+	// Arrange
+	content := `
+pid:
+  feed_forward:
+    enabled: true
+    cpu_gain: 0
+`
+	tmpFile := createTempConfig(t, content)
+	defer os.Remove(tmpFile)
+
+	// Act
+	config, err := LoadConfig(tmpFile)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, *config.PID.FeedForward.CPUGain)
+	assert.Equal(t, 0.05, *config.PID.FeedForward.IOGain, "an unset gain still gets its default")
+}
+
+// float64Ptr returns a pointer to v, for optional config values
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+// TestSetDefaults_Autotune tests autotune defaults follow the fan limits
+func TestSetDefaults_Autotune(t *testing.T) {
+	// Arrange
+	config := &Config{Fans: FanConfig{MinDuty: 60, MaxDuty: 90}}
+
+	// Act
+	setDefaults(config)
+
+	// Assert
+	assert.Equal(t, 90, config.Autotune.HighDuty)
+	assert.Equal(t, 60, config.Autotune.LowDuty)
+	assert.Equal(t, 0.5, config.Autotune.Hysteresis)
+	assert.Equal(t, 3, config.Autotune.Cycles)
+	assert.Equal(t, 6*time.Hour, config.Autotune.MaxDuration)
+	assert.Equal(t, TuningRuleTyreusLuyben, config.Autotune.Rule)
+	assert.NoError(t, config.Validate())
+}
+
+// TestValidate_Autotune_Error tests autotune validation
+func TestValidate_Autotune_Error(t *testing.T) {
+	tests := []struct {
+		name        string
+		autotune    AutotuneConfig
+		errContains string
+	}{
+		{"low above high", AutotuneConfig{HighDuty: 50, LowDuty: 80}, "autotune.low_duty"},
+		{"high out of range", AutotuneConfig{HighDuty: 150}, "autotune.high_duty"},
+		{"unknown rule", AutotuneConfig{Rule: "cohen_coon"}, "autotune.rule"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := &Config{Autotune: tt.autotune}
+			setDefaults(config)
+
+			// Act
+			err := config.Validate()
+
+			// Assert
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errContains)
+		})
+	}
+}
+
+// TestValidate_InvalidPIDForm_Error tests PID form validation
+func TestValidate_InvalidPIDForm_Error(t *testing.T) {
+	// Arrange
+	config := &Config{PID: PIDConfig{Form: "parallel"}}
+	setDefaults(config)
+
+	// Act
+	err := config.Validate()
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "form must be one of")
+}
+
+// TestValidate_InvalidAntiWindup_Error tests anti-windup strategy validation
+func TestValidate_InvalidAntiWindup_Error(t *testing.T) {
+	// Arrange
+	config := &Config{PID: PIDConfig{AntiWindup: "bogus"}}
+	setDefaults(config)
+
+	// Act
+	err := config.Validate()
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "anti_windup must be one of")
+}
+
+// TestValidate_NegativeDerivativeFilter_Error tests derivative filter validation
+func TestValidate_NegativeDerivativeFilter_Error(t *testing.T) {
+	// Arrange
+	config := &Config{PID: PIDConfig{DerivativeFilter: -time.Second}}
+	setDefaults(config)
+
+	// Act
+	err := config.Validate()
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "derivative_filter must be non-negative")
+}
+
 // Helper function to create a temporary config file for testing
 func createTempConfig(t *testing.T, content string) string {
 	tmpDir := t.TempDir()
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// Consecutive failed fan commands before forcing emergency mode
+	maxIPMIFailures = 5
+)
+
+// Fan actions applied on shutdown or after a panic
+const (
+	OnExitFull    = "full"     // Set all fans to 100%
+	OnExitBMCAuto = "bmc_auto" // Return control to the BMC's automatic fan curve
+	OnExitHold    = "hold"     // Leave the last duty in place
+)
+
+// Controller runs the fan control loop against injected sensors, fans and clock
+type Controller struct {
+	config      *Config
+	pid         *PIDController
+	temps       TempSource
+	fans        FanActuator
+	clock       Clock
+	feedForward *FeedForward
+	aggregator  *Aggregator
+	filter      *PlausibilityFilter
+	disks       *DiskTracker
+	emergency   EmergencyState
+	watchdog    *Watchdog
+	systemd     *SystemdNotifier
+	degraded    string // Startup validation error when started with on_startup_failure: degraded
+	alerts      *Alerter
+	mqtt        *MQTTBridge
+
+	// Runtime settings changed over MQTT while the loop runs
+	controlsMu sync.Mutex
+	controls   Controls
+
+	// Loop state
+	consecutiveIPMIFailures    int
+	consecutiveSensorFailures  int  // Polls in a row without usable readings
+	consecutivePartialFailures int  // Polls in a row with an unreadable disk
+	manualActive               bool // The last iteration ran in manual mode, so the PID must restart
+	lastDuty                   int  // Duty of the last fan command sent
+
+	// Shutdown action runs exactly once
+	shutdownOnce sync.Once
+	shutdownErr  error
+}
+
+// NewController creates a control loop; the PID controller's time source is
+// switched to the given clock so time deltas follow the loop
+func NewController(config *Config, pid *PIDController, temps TempSource, fans FanActuator, clock Clock) *Controller {
+	pid.SetTimeSource(clock.Now)
+
+	c := &Controller{
+		config: config,
+		pid:    pid,
+		temps:  temps,
+		fans:   fans,
+		clock:  clock,
+
+		controls: Controls{
+			Mode:       ControlModeAuto,
+			ManualDuty: config.Fans.StartupDuty,
+			Setpoint:   config.Temperature.TargetHDD,
+		},
+
+		aggregator: NewAggregator(config),
+		filter:     NewPlausibilityFilter(config.Plausibility),
+		disks:      NewDiskTracker(),
+	}
+
+	// Optional load-based feed-forward
+	if config.PID.FeedForward.Enabled {
+		c.feedForward = NewFeedForward(config.PID.FeedForward)
+	}
+
+	return c
+}
+
+// Run sets the startup duty and executes Step every poll interval until ctx is cancelled
+func (c *Controller) Run(ctx context.Context) error {
+	slog.Info("Starting control loop",
+		"target", c.config.Temperature.TargetHDD, "interval", c.config.Temperature.PollInterval)
+	if c.feedForward != nil {
+		slog.Info("Feed-forward enabled", "cpu_gain", c.feedForward.cpuGain,
+			"io_gain", c.feedForward.ioGain, "max_bias", c.config.PID.FeedForward.MaxBias)
+	}
+
+	c.SetStartupDuty(ctx)
+
+	for {
+		// Errors are logged and counted by Step; keep polling
+		summary, err := c.Step(ctx)
+		if c.watchdog != nil && ctx.Err() == nil {
+			c.watchdog.Beat(ctx, err == nil)
+		}
+		if err == nil {
+			c.notifySystemd(summary)
+		} else if c.degraded != "" {
+			c.notifySystemdDegraded(err)
+		}
+
+		// Stop as soon as ctx is cancelled rather than finishing the poll interval
+		if err := c.clock.Sleep(ctx, c.config.Temperature.PollInterval); err != nil {
+			slog.Info("Control loop stopping", "reason", err)
+			return err
+		}
+	}
+}
+
+// SetWatchdog makes Run report every iteration to the watchdog
+func (c *Controller) SetWatchdog(watchdog *Watchdog) {
+	c.watchdog = watchdog
+}
+
+// SetSystemd makes Run send sd_notify WATCHDOG and STATUS updates
+func (c *Controller) SetSystemd(notifier *SystemdNotifier) {
+	c.systemd = notifier
+}
+
+// SetAlerter makes Step send notifications when alerts start and clear
+func (c *Controller) SetAlerter(alerts *Alerter) {
+	c.alerts = alerts
+}
+
+// SetMQTT makes Step publish every iteration to MQTT
+func (c *Controller) SetMQTT(bridge *MQTTBridge) {
+	c.mqtt = bridge
+}
+
+// notify reports the active alerts of one group, if notifications are configured
+func (c *Controller) notify(group string, alerts []Alert, now time.Time) {
+	if c.alerts != nil {
+		c.alerts.Observe(group, alerts, now)
+	}
+}
+
+// SetDegraded marks a degraded startup: the failure is already known and the fans
+// are at 100%, so failed iterations still pet the systemd watchdog instead of
+// letting systemd restart the service into the same state
+func (c *Controller) SetDegraded(reason string) {
+	c.degraded = reason
+}
+
+// notifySystemdDegraded pets the systemd watchdog after a failed iteration in degraded mode
+func (c *Controller) notifySystemdDegraded(err error) {
+	if c.systemd == nil {
+		return
+	}
+
+	if err := c.systemd.Alive(fmt.Sprintf("Degraded: %s; last error: %v", c.degraded, err)); err != nil {
+		slog.Warn("Failed to notify systemd", "error", err)
+	}
+}
+
+// notifySystemd pets the systemd watchdog and updates the status after a successful iteration
+func (c *Controller) notifySystemd(summary MetricsSummary) {
+	if c.systemd == nil {
+		return
+	}
+
+	if err := c.systemd.Alive(FormatMetricsSummary(summary)); err != nil {
+		slog.Warn("Failed to notify systemd", "error", err)
+	}
+}
+
+// Shutdown applies the fans.on_exit action exactly once; later calls return the first result
+// ctx bounds how long the fan command may take
+func (c *Controller) Shutdown(ctx context.Context) error {
+	c.shutdownOnce.Do(func() {
+		c.shutdownErr = c.applyExitAction(ctx)
+	})
+	return c.shutdownErr
+}
+
+// applyExitAction runs the configured exit action; a failed bmc_auto falls back to 100%
+// bmc_auto gets half of shutdown_timeout, and the fallback its own half, since IPMI
+// retries can use up ctx before the fallback runs
+func (c *Controller) applyExitAction(ctx context.Context) error {
+	switch c.config.Fans.OnExit {
+	case OnExitHold:
+		slog.Info("Leaving fans at their current duty", "on_exit", OnExitHold)
+		return nil
+	case OnExitBMCAuto:
+		budget := c.config.Server.ShutdownTimeout / 2
+		autoCtx, cancelAuto := context.WithTimeout(ctx, budget)
+		err := c.fans.SetAutoMode(autoCtx)
+		cancelAuto()
+		if err == nil {
+			slog.Info("Fan control returned to BMC automatic mode", "bmc", c.config.Fans.BMC)
+			return nil
+		}
+		slog.Warn("Failed to return fans to BMC automatic mode, falling back to 100%", "error", err)
+
+		fallbackCtx, cancelFallback := context.WithTimeout(context.Background(), budget)
+		defer cancelFallback()
+		ctx = fallbackCtx
+	}
+
+	if err := c.fans.SetAllFans(ctx, 100); err != nil {
+		return fmt.Errorf("failed to set fans to 100%% during shutdown: %w", err)
+	}
+	slog.Info("Fans set to 100% for safety")
+	return nil
+}
+
+// ShutdownOnPanic applies the exit action if the calling goroutine is panicking, then
+// re-panics. It must be deferred directly so recover can see the panic
+func (c *Controller) ShutdownOnPanic(timeout time.Duration) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	slog.Error("PANIC: applying on_exit action", "panic", r, "on_exit", c.config.Fans.OnExit)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := c.Shutdown(ctx); err != nil {
+		slog.Error("Failed to apply on_exit action after panic", "error", err)
+	}
+	panic(r)
+}
+
+// SetStartupDuty applies the configured initial fan duty
+func (c *Controller) SetStartupDuty(ctx context.Context) {
+	if err := c.fans.SetAllFans(ctx, c.config.Fans.StartupDuty); err != nil {
+		slog.Warn("Failed to set initial fan speed", "error", err)
+	} else {
+		c.lastDuty = c.config.Fans.StartupDuty
+		slog.Info("Set initial fan speed", "duty", c.config.Fans.StartupDuty)
+	}
+}
+
+// Step runs a single control loop iteration: read sensors, compute and apply the
+// fan duty, and update metrics. Returns the iteration summary
+func (c *Controller) Step(ctx context.Context) (MetricsSummary, error) {
+	loopStart := c.clock.Now()
+
+	// Read temperatures
+	readings, err := c.temps.ReadTemperatures(ctx)
+	if err == nil {
+		// Implausible values are replaced or dropped so they cannot trigger or mask an emergency
+		readings, err = c.filter.Filter(readings, loopStart)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return MetricsSummary{}, ctx.Err() // Shutting down, not a sensor failure
+		}
+		slog.Error("Failed to read temperatures", "error", err)
+		RecordError("temperature")
+		// Keep per-disk series and the tracked disk set accurate, unless discovery itself failed
+		if len(readings.Disks)+len(readings.FailedDisks) > 0 {
+			UpdateDiskMetrics(readings)
+			c.trackDisks(readings, loopStart)
+		}
+		c.notify(AlertGroupReads, readFailureAlerts(err), loopStart)
+		summary := c.handleSensorFailure(ctx, loopStart)
+		if summary.Emergency != "" {
+			c.notify(AlertGroupEmergency, emergencyAlerts(summary.Emergency), loopStart)
+		}
+		return summary, fmt.Errorf("error reading temperatures: %w", err)
+	}
+	diskTemps, cpuTemp := readings.Disks, readings.CPU
+	missingDisks := c.trackDisks(readings, loopStart)
+
+	// Calculate temperature metrics; disk groups shift each disk onto the global target and limit
+	avgTemp, limitTemp := diskControlInputs(c.aggregator, readings, loopStart)
+	maxTemp := GetMaxTemperature(diskTemps)
+
+	// Check for emergency conditions
+	emergencyReason := checkEmergencyConditions(newEmergencyInputs(readings, limitTemp), c.config)
+
+	// Unreadable disks: act once partial_threshold polls in a row had one
+	partialAction := c.partialSensorAction(readings)
+	if emergencyReason == "" && partialAction == SensorFailureEmergency {
+		emergencyReason = ReasonSensorPartial
+	}
+
+	var fanDuty int
+	var pidTerms PIDTerms
+
+	if emergencyReason != "" {
+		// Emergency mode: set fans to 100%
+		fanDuty = 100
+		pidTerms = PIDTerms{} // Zero terms in emergency
+		slog.Error("EMERGENCY: setting fans to 100%", "emergency", emergencyReason)
+	} else {
+		controls := c.Controls()
+		if controls.Mode == ControlModeManual {
+			// Manual mode: fixed duty; the floors and fan limits below still apply
+			fanDuty = controls.ManualDuty
+			c.manualActive = true
+		} else {
+			// Resuming from manual mode: the integral and derivative state are stale
+			if c.manualActive {
+				c.pid.Reset()
+				c.manualActive = false
+			}
+			c.pid.SetTarget(controls.Setpoint)
+
+			// Update feed-forward bias from current load
+			if c.feedForward != nil {
+				bias, err := c.feedForward.Sample(loopStart, diskNames(diskTemps))
+				if err != nil {
+					slog.Warn("Failed to sample load for feed-forward", "error", err)
+					RecordError("feedforward")
+					c.feedForward.Reset()
+					bias = 0
+				}
+				c.pid.SetFeedForward(bias)
+			}
+
+			// Normal PID control
+			output, terms := c.pid.Calculate(avgTemp)
+			pidTerms = terms
+			fanDuty = int(output)
+		}
+
+		// Warning tier: a disk that vanished may be a failed drive that is no longer cooled
+		if c.config.Disks.Hotplug.OnRemoval == OnRemovalWarn && missingDisks > 0 && fanDuty < c.config.Disks.Hotplug.WarningDuty {
+			slog.Debug("Holding fans at warning duty for missing disks",
+				"missing", missingDisks, "duty", c.config.Disks.Hotplug.WarningDuty)
+			fanDuty = c.config.Disks.Hotplug.WarningDuty
+		}
+
+		// Fail-safe floor while some disks cannot be read
+		if partialAction == SensorFailureFailsafe && fanDuty < c.config.SensorFailure.FailsafeDuty {
+			slog.Warn("Sensor failure: holding fans at fail-safe duty",
+				"failed_disks", readings.FailedDisks, "duty", c.config.SensorFailure.FailsafeDuty)
+			fanDuty = c.config.SensorFailure.FailsafeDuty
+			emergencyReason = ReasonSensorPartial
+		}
+
+		// Clamp to fan limits
+		if fanDuty < c.config.Fans.MinDuty {
+			fanDuty = c.config.Fans.MinDuty
+		}
+		if fanDuty > c.config.Fans.MaxDuty {
+			fanDuty = c.config.Fans.MaxDuty
+		}
+	}
+
+	// Set fan speed
+	if err := c.fans.SetAllFans(ctx, fanDuty); err != nil {
+		if ctx.Err() != nil {
+			return MetricsSummary{}, ctx.Err() // Shutting down, not an IPMI failure
+		}
+		c.consecutiveIPMIFailures++
+		RecordError("ipmi")
+		slog.Error("IPMI command failed",
+			"attempt", c.consecutiveIPMIFailures, "max_attempts", maxIPMIFailures, "error", err)
+
+		// If too many consecutive failures, force emergency mode
+		if c.consecutiveIPMIFailures >= maxIPMIFailures {
+			slog.Error("Too many IPMI failures, forcing emergency mode", "failures", c.consecutiveIPMIFailures)
+			emergencyReason = ReasonIPMIFailure
+			fanDuty = 100
+			// Try one more time to set 100%
+			if err := c.fans.SetAllFans(ctx, 100); err != nil {
+				slog.Error("Failed to set emergency fan speed", "error", err)
+			}
+		}
+	} else {
+		c.consecutiveIPMIFailures = 0 // Reset failure counter on success
+	}
+	c.lastDuty = fanDuty
+
+	// Read current fan speeds for metrics
+	fanSpeeds, err := c.fans.GetFanSpeeds(ctx)
+	if err != nil {
+		slog.Warn("Failed to read fan speeds", "error", err)
+		fanSpeeds = make(map[string]int) // Empty map for metrics
+	} else {
+		c.notify(AlertGroupFans, fanAlerts(fanSpeeds, readings.Enclosure.Fans, c.config.Notify.StallRPM), loopStart)
+	}
+	c.notify(AlertGroupReads, nil, loopStart)
+	c.notify(AlertGroupSensors, sensorAlerts(readings), loopStart)
+	c.notify(AlertGroupDisks, diskAlerts(c.config, readings), loopStart)
+	c.notify(AlertGroupEmergency, emergencyAlerts(emergencyReason), loopStart)
+
+	// Update metrics
+	c.emergency.Observe(emergencyReason, loopStart)
+	UpdateAllMetrics(
+		readings, fanSpeeds, fanDuty,
+		pidTerms, avgTemp, maxTemp, emergencyReason,
+		c.clock.Now().Sub(loopStart),
+	)
+
+	// Log status
+	summary := GetMetricsSummary(
+		diskTemps, cpuTemp, fanDuty, pidTerms,
+		avgTemp, maxTemp, emergencyReason, c.clock.Now().Sub(loopStart),
+	)
+	LogMetricsSummary(summary)
+	if c.mqtt != nil {
+		c.mqtt.Publish(summary, readings, fanSpeeds)
+	}
+
+	return summary, nil
+}
+
+// trackDisks logs and counts disks added or removed since the last poll and
+// returns how many removed disks are still missing
+func (c *Controller) trackDisks(readings TempReadings, now time.Time) int {
+	events := c.disks.Update(readings, now)
+	for _, event := range events {
+		logDiskEvent(event)
+	}
+	hdds, flash := c.disks.Counts()
+	missing := c.disks.Missing(now, c.config.Disks.Hotplug.WarningHold)
+	UpdateHotplugMetrics(events, hdds, flash, missing)
+	return missing
+}
@@ -0,0 +1,668 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTempSource returns fixed readings or an error
+type fakeTempSource struct {
+	diskTemps   map[string]int
+	flashTemps  map[string]int
+	failedDisks []string
+	identities  map[string]DiskIdentity
+	cpuTemp     float64
+	err         error
+}
+
+// ReadTemperatures returns the configured readings; disk fields are kept on error
+func (f *fakeTempSource) ReadTemperatures(ctx context.Context) (TempReadings, error) {
+	readings := TempReadings{Disks: f.diskTemps, Flash: f.flashTemps, FailedDisks: f.failedDisks, Identities: f.identities}
+	if f.err != nil {
+		return readings, f.err
+	}
+	readings.CPU = f.cpuTemp
+	return readings, nil
+}
+
+// fakeFanActuator records fan commands and fails the first failures calls
+type fakeFanActuator struct {
+	duties    []int
+	failures  int
+	speeds    map[string]int
+	autoCalls int
+	autoErr   error
+}
+
+// SetAllFans records the duty, failing while failures remain
+func (f *fakeFanActuator) SetAllFans(ctx context.Context, dutyPercent int) error {
+	f.duties = append(f.duties, dutyPercent)
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("ipmitool: BMC busy")
+	}
+	return nil
+}
+
+// GetFanSpeeds returns the configured speeds
+func (f *fakeFanActuator) GetFanSpeeds(ctx context.Context) (map[string]int, error) {
+	return f.speeds, nil
+}
+
+// SetAutoMode records the call and returns autoErr
+func (f *fakeFanActuator) SetAutoMode(ctx context.Context) error {
+	f.autoCalls++
+	return f.autoErr
+}
+
+// lastDuty returns the most recent duty command
+func (f *fakeFanActuator) lastDuty() int {
+	return f.duties[len(f.duties)-1]
+}
+
+// fakeClock advances only when slept on
+type fakeClock struct {
+	now    time.Time
+	sleeps int
+}
+
+// Now returns the fake time
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// Sleep advances the fake time unless ctx is already cancelled
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.now = c.now.Add(d)
+	c.sleeps++
+	return nil
+}
+
+var testMetricsOnce sync.Once
+
+// initTestMetrics registers the global metrics once for the test binary
+func initTestMetrics() {
+	testMetricsOnce.Do(func() { InitMetrics() })
+}
+
+// silenceLogs discards log output for the duration of the test
+func silenceLogs(t *testing.T) {
+	t.Helper()
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+}
+
+// newTestController creates a Controller with fakes and metrics initialised
+func newTestController(t *testing.T, temps TempSource, fans FanActuator) *Controller {
+	t.Helper()
+
+	initTestMetrics()
+	silenceLogs(t)
+
+	config := &Config{
+		Temperature: TemperatureConfig{TargetHDD: 38.0, MaxHDD: 45.0, MaxCPU: 75.0},
+		Fans:        FanConfig{MinDuty: 30, MaxDuty: 100, StartupDuty: 50},
+	}
+	setDefaults(config)
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	return NewController(config, newPIDFromConfig(config), temps, fans, clock)
+}
+
+// TestController_Step_Normal tests a normal PID iteration
+func TestController_Step_Normal(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{diskTemps: map[string]int{"sda": 39, "sdb": 38}, cpuTemp: 50}
+	fans := &fakeFanActuator{}
+	controller := newTestController(t, temps, fans)
+
+	// Act
+	summary, err := controller.Step(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "", summary.Emergency)
+	assert.Equal(t, 39, summary.MaxDiskTemp)
+	assert.InDelta(t, 38.5, summary.AvgDiskTemp, 0.01)
+	assert.GreaterOrEqual(t, summary.FanDuty, 30)
+	assert.LessOrEqual(t, summary.FanDuty, 100)
+	assert.Equal(t, []int{summary.FanDuty}, fans.duties)
+}
+
+// TestController_Step_Emergency tests the HDD and CPU emergency branches
+func TestController_Step_Emergency(t *testing.T) {
+	tests := []struct {
+		name      string
+		diskTemps map[string]int
+		cpuTemp   float64
+		reason    string
+	}{
+		{"hdd over max", map[string]int{"sda": 46, "sdb": 38}, 50, "hdd_temp"},
+		{"cpu over max", map[string]int{"sda": 38}, 80, "cpu_temp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			temps := &fakeTempSource{diskTemps: tt.diskTemps, cpuTemp: tt.cpuTemp}
+			fans := &fakeFanActuator{}
+			controller := newTestController(t, temps, fans)
+
+			// Act
+			summary, err := controller.Step(context.Background())
+
+			// Assert
+			require.NoError(t, err)
+			assert.Equal(t, tt.reason, summary.Emergency)
+			assert.Equal(t, 100, summary.FanDuty)
+			assert.Equal(t, 100, fans.lastDuty())
+		})
+	}
+}
+
+// TestController_Step_DiskGroupLimit tests that a disk below its group's max_hdd is not an emergency
+func TestController_Step_DiskGroupLimit(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{
+		diskTemps:  map[string]int{"sda": 50, "sdb": 38},
+		identities: map[string]DiskIdentity{"sda": {Device: "sda", Serial: "ZL2A", Model: "ST16000NM001G"}},
+		cpuTemp:    50,
+	}
+	fans := &fakeFanActuator{}
+	controller := newTestController(t, temps, fans)
+	controller.config.Disks.Groups = []DiskGroup{{Name: "enterprise", Match: []string{"^ST16000NM"}, TargetHDD: 48, MaxHDD: 60, Weight: 1}}
+
+	// Act
+	summary, err := controller.Step(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	assert.Empty(t, summary.Emergency)
+	assert.Equal(t, 50, summary.MaxDiskTemp, "the reported max is the raw temperature")
+	assert.Less(t, summary.FanDuty, 100)
+}
+
+// TestController_Step_FlashEmergency tests that a flash drive over flash.max_temp forces 100%
+func TestController_Step_FlashEmergency(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{diskTemps: map[string]int{"sda": 38}, flashTemps: map[string]int{"nvme0n1": 70}, cpuTemp: 50}
+	fans := &fakeFanActuator{}
+	controller := newTestController(t, temps, fans)
+	controller.config.Disks.Flash.Enabled = true
+
+	// Act
+	summary, err := controller.Step(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "flash_temp", summary.Emergency)
+	assert.Equal(t, 100, fans.lastDuty())
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.EmergencyMode.WithLabelValues("flash_temp")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.EmergencyMode.WithLabelValues("hdd_temp")))
+}
+
+// TestController_Step_DiskRemoved tests the hotplug on_removal policies
+func TestController_Step_DiskRemoved(t *testing.T) {
+	tests := []struct {
+		name      string
+		onRemoval string
+		minDuty   int
+	}{
+		{"log only", OnRemovalLog, 30},
+		{"warn holds warning duty", OnRemovalWarn, 80},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange - disks at target, so the PID alone stays well below the warning duty
+			temps := &fakeTempSource{diskTemps: map[string]int{"sda": 38, "sdb": 38}, cpuTemp: 50}
+			fans := &fakeFanActuator{}
+			controller := newTestController(t, temps, fans)
+			controller.config.Disks.Hotplug.OnRemoval = tt.onRemoval
+			ResetMetrics()
+			_, err := controller.Step(context.Background())
+			require.NoError(t, err)
+			events := testutil.ToFloat64(metrics.DiskEvents.WithLabelValues(DiskEventRemoved))
+
+			// Act
+			temps.diskTemps = map[string]int{"sda": 38}
+			summary, err := controller.Step(context.Background())
+
+			// Assert
+			require.NoError(t, err)
+			assert.Empty(t, summary.Emergency)
+			assert.GreaterOrEqual(t, summary.FanDuty, tt.minDuty)
+			assert.Less(t, summary.FanDuty, 100)
+			assert.Equal(t, events+1, testutil.ToFloat64(metrics.DiskEvents.WithLabelValues(DiskEventRemoved)))
+			assert.Equal(t, 1.0, testutil.ToFloat64(metrics.DiskCount.WithLabelValues("hdd")))
+			assert.Equal(t, 1.0, testutil.ToFloat64(metrics.DisksMissing))
+		})
+	}
+}
+
+// TestController_Step_ImplausibleReading tests that a 255°C SMART read does not trigger an emergency
+func TestController_Step_ImplausibleReading(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{diskTemps: map[string]int{"sda": 38, "sdb": 39}, cpuTemp: 50}
+	fans := &fakeFanActuator{}
+	controller := newTestController(t, temps, fans)
+	_, err := controller.Step(context.Background())
+	require.NoError(t, err)
+	temps.diskTemps = map[string]int{"sda": 255, "sdb": 39}
+
+	// Act
+	summary, err := controller.Step(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "", summary.Emergency)
+	assert.Equal(t, 39, summary.MaxDiskTemp)
+	assert.Equal(t, 38.0, testutil.ToFloat64(metrics.HDDTemperature.WithLabelValues("sda")))
+}
+
+// TestController_Step_AllReadingsImplausible tests that sensor_failure applies when no disk reading is plausible
+func TestController_Step_AllReadingsImplausible(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{diskTemps: map[string]int{"sda": 0, "sdb": 255}, cpuTemp: 50}
+	fans := &fakeFanActuator{}
+	controller := newTestController(t, temps, fans)
+
+	// Act
+	_, firstErr := controller.Step(context.Background())
+	summary, err := controller.Step(context.Background())
+
+	// Assert
+	require.Error(t, firstErr)
+	assert.Contains(t, firstErr.Error(), "no plausible disk temperature")
+	require.Error(t, err)
+	assert.Equal(t, ReasonSensorFailure, summary.Emergency)
+	assert.Equal(t, []int{100}, fans.duties)
+}
+
+// TestController_Step_Notify tests notifications for an emergency and its recovery
+func TestController_Step_Notify(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{diskTemps: map[string]int{"sda": 46}, cpuTemp: 50}
+	fans := &fakeFanActuator{speeds: map[string]int{"FAN1": 1500}}
+	controller := newTestController(t, temps, fans)
+	notifier := &fakeNotifier{}
+	controller.SetAlerter(NewAlerter(controller.config.Notify, []Notifier{notifier}))
+
+	// Act
+	_, err := controller.Step(context.Background())
+	require.NoError(t, err)
+	temps.diskTemps = map[string]int{"sda": 40}
+	_, err = controller.Step(context.Background())
+	require.NoError(t, err)
+	waitSent(t, controller.alerts)
+
+	// Assert
+	assert.ElementsMatch(t, []string{
+		"disk_over_max/sda", "emergency/hdd_temp", "resolved disk_over_max/sda", "resolved emergency/hdd_temp",
+	}, notifier.keys())
+}
+
+// TestController_Step_NotifyReadFailure tests that a total read failure leaves per-disk sensor alerts active
+func TestController_Step_NotifyReadFailure(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{
+		diskTemps:   map[string]int{"sda": 38},
+		failedDisks: []string{"sdb"},
+		identities:  map[string]DiskIdentity{"sdb": {Device: "sdb", Serial: "WD1"}},
+		cpuTemp:     50,
+	}
+	controller := newTestController(t, temps, &fakeFanActuator{})
+	notifier := &fakeNotifier{}
+	controller.SetAlerter(NewAlerter(controller.config.Notify, []Notifier{notifier}))
+
+	// Act
+	_, err := controller.Step(context.Background())
+	require.NoError(t, err)
+	temps.err = errors.New("smartctl hung")
+	_, err = controller.Step(context.Background())
+	require.Error(t, err)
+	temps.err = nil
+	_, err = controller.Step(context.Background())
+	require.NoError(t, err)
+	waitSent(t, controller.alerts)
+
+	// Assert
+	assert.ElementsMatch(t, []string{"sensor_loss/WD1", "sensor_loss/all", "resolved sensor_loss/all"}, notifier.keys())
+}
+
+// TestController_Step_IPMIFailure tests forcing emergency after repeated fan command failures
+func TestController_Step_IPMIFailure(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{diskTemps: map[string]int{"sda": 38}, cpuTemp: 50}
+	fans := &fakeFanActuator{failures: 100}
+	controller := newTestController(t, temps, fans)
+
+	// Act - failures below the limit keep normal mode
+	for i := 1; i < maxIPMIFailures; i++ {
+		summary, err := controller.Step(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "", summary.Emergency, "iteration %d", i)
+	}
+	summary, err := controller.Step(context.Background())
+
+	// Assert - the limit forces emergency and a retry at 100%
+	require.NoError(t, err)
+	assert.Equal(t, "ipmi_failure", summary.Emergency)
+	assert.Equal(t, 100, summary.FanDuty)
+	assert.Equal(t, 100, fans.lastDuty())
+	assert.Equal(t, maxIPMIFailures, controller.consecutiveIPMIFailures)
+}
+
+// TestController_Step_IPMIRecovery tests the failure counter reset on success
+func TestController_Step_IPMIRecovery(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{diskTemps: map[string]int{"sda": 38}, cpuTemp: 50}
+	fans := &fakeFanActuator{failures: maxIPMIFailures - 1}
+	controller := newTestController(t, temps, fans)
+
+	// Act
+	for i := 0; i < maxIPMIFailures-1; i++ {
+		controller.Step(context.Background())
+	}
+	summary, err := controller.Step(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "", summary.Emergency)
+	assert.Equal(t, 0, controller.consecutiveIPMIFailures)
+}
+
+// TestController_Step_IPMIFailureClears tests that the ipmi_failure gauge returns
+// to 0 once fan commands succeed again
+func TestController_Step_IPMIFailureClears(t *testing.T) {
+	// Arrange - every command fails until emergency, including its retry at 100%
+	temps := &fakeTempSource{diskTemps: map[string]int{"sda": 38}, cpuTemp: 50}
+	fans := &fakeFanActuator{failures: maxIPMIFailures + 1}
+	controller := newTestController(t, temps, fans)
+	transitions := testutil.ToFloat64(metrics.EmergencyEntered.WithLabelValues(ReasonIPMIFailure))
+	for i := 0; i < maxIPMIFailures; i++ {
+		controller.Step(context.Background())
+	}
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.EmergencyMode.WithLabelValues(ReasonIPMIFailure)))
+
+	// Act
+	summary, err := controller.Step(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "", summary.Emergency)
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.EmergencyMode.WithLabelValues(ReasonIPMIFailure)))
+	assert.Equal(t, transitions+1, testutil.ToFloat64(metrics.EmergencyEntered.WithLabelValues(ReasonIPMIFailure)))
+}
+
+// TestController_Step_TemperatureError tests that no fan command is sent without readings
+func TestController_Step_TemperatureError(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{err: errors.New("k10temp sensor not found")}
+	fans := &fakeFanActuator{}
+	controller := newTestController(t, temps, fans)
+
+	// Act
+	_, err := controller.Step(context.Background())
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "k10temp")
+	assert.Empty(t, fans.duties)
+}
+
+// TestController_Step_DiskReadFailure_UpdatesDiskMetrics tests that per-disk metrics
+// are updated even when no disk could be read
+func TestController_Step_DiskReadFailure_UpdatesDiskMetrics(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{diskTemps: map[string]int{"sda": 38}, cpuTemp: 50}
+	controller := newTestController(t, temps, &fakeFanActuator{})
+	ResetMetrics()
+	_, err := controller.Step(context.Background())
+	require.NoError(t, err)
+	temps.diskTemps, temps.failedDisks = map[string]int{}, []string{"sda"}
+	temps.err = errors.New("failed to read temperatures from any disk")
+
+	// Act
+	_, err = controller.Step(context.Background())
+
+	// Assert
+	require.Error(t, err)
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.HDDReadSuccess.WithLabelValues("sda")))
+	assert.Equal(t, 0, testutil.CollectAndCount(metrics.HDDTemperature))
+}
+
+// TestController_DryRun tests that the dry-run actuator never sends fan commands
+func TestController_DryRun(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{diskTemps: map[string]int{"sda": 46}, cpuTemp: 50}
+	fans := &fakeFanActuator{speeds: map[string]int{"FAN1": 1200}}
+	controller := newTestController(t, temps, dryRunFanActuator{FanActuator: fans})
+
+	// Act
+	summary, err := controller.Step(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "hdd_temp", summary.Emergency)
+	assert.Empty(t, fans.duties)
+}
+
+// TestController_Run_StopsOnCancel tests Run returns once the context is cancelled
+func TestController_Run_StopsOnCancel(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{diskTemps: map[string]int{"sda": 38}, cpuTemp: 50}
+	fans := &fakeFanActuator{}
+	controller := newTestController(t, temps, fans)
+	clock := controller.clock.(*fakeClock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	controller.clock = &cancellingClock{fakeClock: clock, after: 3, cancel: cancel}
+
+	// Act
+	err := controller.Run(ctx)
+
+	// Assert - startup duty plus three iterations
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, fans.duties, 4)
+	assert.Equal(t, 50, fans.duties[0])
+}
+
+// TestController_Run_CancelDuringSleep tests Run returns without waiting out the poll interval
+func TestController_Run_CancelDuringSleep(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{diskTemps: map[string]int{"sda": 38}, cpuTemp: 50}
+	fans := &fakeFanActuator{}
+	controller := newTestController(t, temps, fans)
+	controller.clock = systemClock{}
+	controller.config.Temperature.PollInterval = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- controller.Run(ctx) }()
+
+	// Act
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	// Assert
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
+
+// TestController_Shutdown_AppliesOnce tests the shutdown duty is sent exactly once
+func TestController_Shutdown_AppliesOnce(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{}
+	fans := &fakeFanActuator{}
+	controller := newTestController(t, temps, fans)
+
+	// Act
+	err1 := controller.Shutdown(context.Background())
+	err2 := controller.Shutdown(context.Background())
+
+	// Assert
+	require.NoError(t, err1)
+	require.NoError(t, err2)
+	assert.Equal(t, []int{100}, fans.duties)
+}
+
+// TestController_Shutdown_Failure tests the first failure is reported to every caller
+func TestController_Shutdown_Failure(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{}
+	fans := &fakeFanActuator{failures: 1}
+	controller := newTestController(t, temps, fans)
+
+	// Act
+	err1 := controller.Shutdown(context.Background())
+	err2 := controller.Shutdown(context.Background())
+
+	// Assert
+	require.Error(t, err1)
+	assert.Contains(t, err1.Error(), "during shutdown")
+	assert.Equal(t, err1, err2)
+	assert.Len(t, fans.duties, 1)
+}
+
+// TestController_Shutdown_OnExit tests each fans.on_exit action
+func TestController_Shutdown_OnExit(t *testing.T) {
+	tests := []struct {
+		name      string
+		onExit    string
+		autoErr   error
+		duties    []int
+		autoCalls int
+	}{
+		{name: "full", onExit: OnExitFull, duties: []int{100}},
+		{name: "bmc_auto", onExit: OnExitBMCAuto, autoCalls: 1},
+		{name: "bmc_auto falls back to full", onExit: OnExitBMCAuto, autoErr: errors.New("invalid command"), duties: []int{100}, autoCalls: 1},
+		{name: "hold", onExit: OnExitHold},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			fans := &fakeFanActuator{autoErr: tt.autoErr}
+			controller := newTestController(t, &fakeTempSource{}, fans)
+			controller.config.Fans.OnExit = tt.onExit
+
+			// Act
+			err := controller.Shutdown(context.Background())
+
+			// Assert
+			require.NoError(t, err)
+			assert.Equal(t, tt.duties, fans.duties)
+			assert.Equal(t, tt.autoCalls, fans.autoCalls)
+		})
+	}
+}
+
+// slowAutoFanActuator hangs in SetAutoMode like ipmitool retrying against a busy BMC
+type slowAutoFanActuator struct {
+	fakeFanActuator
+	fallbackErr error // ctx.Err() seen by the 100% fallback
+}
+
+// SetAutoMode blocks until its context ends
+func (f *slowAutoFanActuator) SetAutoMode(ctx context.Context) error {
+	f.autoCalls++
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// SetAllFans records the duty and whether its context was still live
+func (f *slowAutoFanActuator) SetAllFans(ctx context.Context, dutyPercent int) error {
+	f.fallbackErr = ctx.Err()
+	return f.fakeFanActuator.SetAllFans(ctx, dutyPercent)
+}
+
+// TestController_Shutdown_BMCAutoTimeout tests that a hung bmc_auto leaves time for the 100% fallback
+func TestController_Shutdown_BMCAutoTimeout(t *testing.T) {
+	// Arrange
+	fans := &slowAutoFanActuator{}
+	controller := newTestController(t, &fakeTempSource{}, fans)
+	controller.config.Fans.OnExit = OnExitBMCAuto
+	controller.config.Server.ShutdownTimeout = 100 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), controller.config.Server.ShutdownTimeout)
+	defer cancel()
+
+	// Act
+	err := controller.Shutdown(ctx)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 1, fans.autoCalls)
+	assert.Equal(t, []int{100}, fans.duties)
+	assert.NoError(t, fans.fallbackErr, "the fallback must not run on an expired context")
+}
+
+// TestController_ShutdownOnPanic tests a panic applies the exit action and is re-raised
+func TestController_ShutdownOnPanic(t *testing.T) {
+	// Arrange
+	fans := &fakeFanActuator{}
+	controller := newTestController(t, &fakeTempSource{}, fans)
+	controller.config.Fans.OnExit = OnExitBMCAuto
+
+	// Act
+	panicked := func() (r any) {
+		defer func() { r = recover() }()
+		defer controller.ShutdownOnPanic(time.Second)
+		panic("nil map write")
+	}()
+
+	// Assert
+	assert.Equal(t, "nil map write", panicked)
+	assert.Equal(t, 1, fans.autoCalls)
+}
+
+// TestController_ShutdownOnPanic_NoPanic tests nothing is applied on a normal return
+func TestController_ShutdownOnPanic_NoPanic(t *testing.T) {
+	// Arrange
+	fans := &fakeFanActuator{}
+	controller := newTestController(t, &fakeTempSource{}, fans)
+
+	// Act
+	func() {
+		defer controller.ShutdownOnPanic(time.Second)
+	}()
+
+	// Assert
+	assert.Empty(t, fans.duties)
+	assert.Zero(t, fans.autoCalls)
+}
+
+// cancellingClock cancels a context after a number of sleeps
+type cancellingClock struct {
+	*fakeClock
+	after  int
+	cancel context.CancelFunc
+}
+
+// Sleep advances the fake time and cancels once the limit is reached
+func (c *cancellingClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := c.fakeClock.Sleep(ctx, d); err != nil {
+		return err
+	}
+	if c.sleeps >= c.after {
+		c.cancel()
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// Control modes, switchable at runtime over MQTT
+const (
+	ControlModeAuto   = "auto"   // PID control
+	ControlModeManual = "manual" // Fixed duty from SetManualDuty; emergencies still force 100%
+)
+
+// Controls are the runtime settings that can be changed while the loop runs
+type Controls struct {
+	Mode       string  // ControlModeAuto or ControlModeManual
+	ManualDuty int     // Duty in manual mode (%)
+	Setpoint   float64 // PID target (°C), temperature.target_hdd until changed
+}
+
+// Controls returns the current runtime settings
+func (c *Controller) Controls() Controls {
+	c.controlsMu.Lock()
+	defer c.controlsMu.Unlock()
+	return c.controls
+}
+
+// SetMode switches between PID and manual control; the PID restarts cleanly
+// when auto mode resumes
+func (c *Controller) SetMode(mode string) error {
+	if mode != ControlModeAuto && mode != ControlModeManual {
+		return fmt.Errorf("mode must be one of: auto, manual, got %s", mode)
+	}
+
+	c.controlsMu.Lock()
+	defer c.controlsMu.Unlock()
+	if c.controls.Mode != mode {
+		slog.Info("Control mode changed", "from", c.controls.Mode, "to", mode)
+		c.controls.Mode = mode
+	}
+	return nil
+}
+
+// SetManualDuty sets the duty used in manual mode; fans.min_duty and max_duty still apply
+func (c *Controller) SetManualDuty(duty int) error {
+	if duty < 0 || duty > 100 {
+		return fmt.Errorf("manual duty must be between 0-100, got %d", duty)
+	}
+
+	c.controlsMu.Lock()
+	defer c.controlsMu.Unlock()
+	slog.Info("Manual duty changed", "from", c.controls.ManualDuty, "to", duty)
+	c.controls.ManualDuty = duty
+	return nil
+}
+
+// SetSetpoint changes the PID target; it must stay below temperature.max_hdd
+func (c *Controller) SetSetpoint(target float64) error {
+	if target <= 0 || target >= c.config.Temperature.MaxHDD {
+		return fmt.Errorf("setpoint must be positive and less than max_hdd (%.1f), got %.1f",
+			c.config.Temperature.MaxHDD, target)
+	}
+
+	c.controlsMu.Lock()
+	defer c.controlsMu.Unlock()
+	slog.Info("Setpoint changed", "from", c.controls.Setpoint, "to", target)
+	c.controls.Setpoint = target
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestController_Controls_Defaults tests that the controls start in auto mode at target_hdd
+func TestController_Controls_Defaults(t *testing.T) {
+	// Arrange
+	controller := newTestController(t, &fakeTempSource{}, &fakeFanActuator{})
+
+	// Act
+	controls := controller.Controls()
+
+	// Assert
+	assert.Equal(t, Controls{Mode: ControlModeAuto, ManualDuty: 50, Setpoint: 38.0}, controls)
+}
+
+// TestController_SetControls tests validation of the runtime settings
+func TestController_SetControls(t *testing.T) {
+	tests := []struct {
+		name    string
+		set     func(c *Controller) error
+		wantErr string
+	}{
+		{"manual mode", func(c *Controller) error { return c.SetMode(ControlModeManual) }, ""},
+		{"unknown mode", func(c *Controller) error { return c.SetMode("turbo") }, "mode must be one of"},
+		{"manual duty", func(c *Controller) error { return c.SetManualDuty(80) }, ""},
+		{"duty above 100", func(c *Controller) error { return c.SetManualDuty(101) }, "manual duty must be between 0-100"},
+		{"negative duty", func(c *Controller) error { return c.SetManualDuty(-1) }, "manual duty must be between 0-100"},
+		{"setpoint", func(c *Controller) error { return c.SetSetpoint(40) }, ""},
+		{"setpoint at max_hdd", func(c *Controller) error { return c.SetSetpoint(45) }, "less than max_hdd"},
+		{"zero setpoint", func(c *Controller) error { return c.SetSetpoint(0) }, "must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			controller := newTestController(t, &fakeTempSource{}, &fakeFanActuator{})
+			before := controller.Controls()
+
+			// Act
+			err := tt.set(controller)
+
+			// Assert
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				assert.Equal(t, before, controller.Controls())
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEqual(t, before, controller.Controls())
+		})
+	}
+}
+
+// TestController_Step_ManualMode tests that manual mode holds its duty, except in an emergency
+func TestController_Step_ManualMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		manualDuty int
+		diskTemps  map[string]int
+		want       int
+	}{
+		{"manual duty", 70, map[string]int{"sda": 38}, 70},
+		{"clamped to min_duty", 10, map[string]int{"sda": 38}, 30},
+		{"emergency overrides", 40, map[string]int{"sda": 46}, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			fans := &fakeFanActuator{}
+			controller := newTestController(t, &fakeTempSource{diskTemps: tt.diskTemps, cpuTemp: 50}, fans)
+			require.NoError(t, controller.SetMode(ControlModeManual))
+			require.NoError(t, controller.SetManualDuty(tt.manualDuty))
+
+			// Act
+			summary, err := controller.Step(context.Background())
+
+			// Assert
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, summary.FanDuty)
+			assert.Equal(t, tt.want, fans.lastDuty())
+		})
+	}
+}
+
+// TestController_Step_Setpoint tests that the PID error is taken against the new setpoint
+func TestController_Step_Setpoint(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{diskTemps: map[string]int{"sda": 38}, cpuTemp: 50}
+	base := newTestController(t, temps, &fakeFanActuator{})
+	lowered := newTestController(t, temps, &fakeFanActuator{})
+	require.NoError(t, lowered.SetSetpoint(34))
+
+	// Act
+	baseSummary, err := base.Step(context.Background())
+	require.NoError(t, err)
+	loweredSummary, err := lowered.Step(context.Background())
+	require.NoError(t, err)
+
+	// Assert
+	assert.InDelta(t, baseSummary.PIDError+4, loweredSummary.PIDError, 0.01)
+	assert.GreaterOrEqual(t, loweredSummary.FanDuty, baseSummary.FanDuty)
+}
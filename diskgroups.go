@@ -0,0 +1,128 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"time"
+)
+
+// DiskPolicy is the thermal policy of one disk after applying disks.groups
+type DiskPolicy struct {
+	Group  string  // Matching group name, empty for the temperature defaults
+	Target float64 // Target temp (°C)
+	Max    float64 // Emergency temp (°C)
+	Weight float64 // Weight in the warmest-disk average
+}
+
+// DiskPolicies maps kernel names to their resolved policy
+type DiskPolicies map[string]DiskPolicy
+
+// policy returns the group's thresholds, falling back to the temperature defaults
+func (g DiskGroup) policy(temperature TemperatureConfig) DiskPolicy {
+	policy := DiskPolicy{Group: g.Name, Target: g.TargetHDD, Max: g.MaxHDD, Weight: g.Weight}
+	if policy.Target == 0 {
+		policy.Target = temperature.TargetHDD
+	}
+	if policy.Max == 0 {
+		policy.Max = temperature.MaxHDD
+	}
+	return policy
+}
+
+// matches reports whether any of the group's patterns matches the disk's serial, model or by-id name
+func (g DiskGroup) matches(identity DiskIdentity) bool {
+	for _, pattern := range g.Match {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			continue // Rejected by Validate
+		}
+		for _, value := range []string{identity.Serial, identity.Model, identity.ByID} {
+			if value != "" && re.MatchString(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ResolveDiskPolicy returns the policy of the first group matching identity, or the temperature defaults
+func ResolveDiskPolicy(config *Config, identity DiskIdentity) DiskPolicy {
+	for _, group := range config.Disks.Groups {
+		if group.matches(identity) {
+			return group.policy(config.Temperature)
+		}
+	}
+	return DiskPolicy{Target: config.Temperature.TargetHDD, Max: config.Temperature.MaxHDD, Weight: 1}
+}
+
+// ResolveDiskPolicies resolves the policy of every disk in readings
+func ResolveDiskPolicies(config *Config, readings TempReadings) DiskPolicies {
+	policies := make(DiskPolicies, len(readings.Disks))
+	for device := range readings.Disks {
+		policies[device] = ResolveDiskPolicy(config, readings.Identity(device))
+	}
+	return policies
+}
+
+// ControlTemps shifts each disk's temperature by (target - its own target), so
+// every disk is compared against the single PID setpoint: a disk at its own
+// target reads as exactly target
+func (p DiskPolicies) ControlTemps(temps map[string]int, target float64) map[string]float64 {
+	shifted := make(map[string]float64, len(temps))
+	for device, temp := range temps {
+		shifted[device] = float64(temp)
+		if policy, ok := p[device]; ok {
+			shifted[device] += target - policy.Target
+		}
+	}
+	return shifted
+}
+
+// LimitTemp returns the hottest disk temperature shifted by (max - its own max),
+// rounded up, so comparing it with max_hdd applies every disk's own limit
+func (p DiskPolicies) LimitTemp(temps map[string]int, max float64) int {
+	limit := 0
+	for device, temp := range temps {
+		shifted := temp
+		if policy, ok := p[device]; ok {
+			shifted = int(math.Ceil(float64(temp) + max - policy.Max))
+		}
+		if shifted > limit {
+			limit = shifted
+		}
+	}
+	return limit
+}
+
+// Weights returns the aggregate weight of each disk
+func (p DiskPolicies) Weights() map[string]float64 {
+	weights := make(map[string]float64, len(p))
+	for device, policy := range p {
+		weights[device] = policy.Weight
+	}
+	return weights
+}
+
+// Targets returns each disk's own target temperature
+func (p DiskPolicies) Targets() map[string]float64 {
+	targets := make(map[string]float64, len(p))
+	for device, policy := range p {
+		targets[device] = policy.Target
+	}
+	return targets
+}
+
+// diskControlInputs returns the PID input from the aggregator and the hottest
+// disk temperature to compare with max_hdd, both after applying disk groups
+// In flash or enclosure control mode the input is raised to that sensor's input when higher
+func diskControlInputs(aggregator *Aggregator, readings TempReadings, now time.Time) (float64, int) {
+	policies := ResolveDiskPolicies(aggregator.config, readings)
+	input := aggregator.Aggregate(readings.Disks, policies, now)
+	if flashInput, ok := flashControlInput(aggregator.config, readings.Flash); ok && flashInput > input {
+		input = flashInput
+	}
+	if enclosureInput, ok := enclosureControlInput(aggregator.config, readings.Enclosure.Temperatures); ok && enclosureInput > input {
+		input = enclosureInput
+	}
+	return input, policies.LimitTemp(readings.Disks, aggregator.config.Temperature.MaxHDD)
+}
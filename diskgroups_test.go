@@ -0,0 +1,133 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newGroupsConfig returns a config with an enterprise group rated hotter than the defaults
+func newGroupsConfig() *Config {
+	config := &Config{
+		Temperature: TemperatureConfig{TargetHDD: 38, MaxHDD: 45},
+		Disks: DiskConfig{Groups: []DiskGroup{
+			{Name: "enterprise", Match: []string{"^ST16000NM"}, TargetHDD: 45, MaxHDD: 60, Weight: 0.5},
+			{Name: "archive", Match: []string{"^ata-WDC_WD80EMAZ"}},
+		}},
+	}
+	setDefaults(config)
+	return config
+}
+
+// TestResolveDiskPolicy tests group matching on model, serial and by-id name
+func TestResolveDiskPolicy(t *testing.T) {
+	config := newGroupsConfig()
+
+	tests := []struct {
+		name     string
+		identity DiskIdentity
+		expected DiskPolicy
+	}{
+		{"model match", DiskIdentity{Device: "sda", Serial: "ZL2ABC", Model: "ST16000NM001G"}, DiskPolicy{Group: "enterprise", Target: 45, Max: 60, Weight: 0.5}},
+		{"by-id match with defaults", DiskIdentity{Device: "sdb", Serial: "VAGXYZ", Model: "WDC WD80EMAZ", ByID: "ata-WDC_WD80EMAZ_VAGXYZ"}, DiskPolicy{Group: "archive", Target: 38, Max: 45, Weight: 1}},
+		{"no match", DiskIdentity{Device: "sdc", Serial: "ABC123", Model: "TOSHIBA MG08"}, DiskPolicy{Target: 38, Max: 45, Weight: 1}},
+		{"kernel name is not matched", DiskIdentity{Device: "ST16000NM"}, DiskPolicy{Target: 38, Max: 45, Weight: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			policy := ResolveDiskPolicy(config, tt.identity)
+
+			// Assert
+			assert.Equal(t, tt.expected, policy)
+		})
+	}
+}
+
+// TestDiskControlInputs tests that a hotter-rated group neither drags the average up nor trips emergency mode
+func TestDiskControlInputs(t *testing.T) {
+	tests := []struct {
+		name          string
+		disks         map[string]int
+		expectedAvg   float64
+		expectedLimit int
+	}{
+		// sda/sdb are enterprise (target 45, max 60, weight 0.5), sdc/sdd use the defaults
+		{"enterprise at its own target", map[string]int{"sda": 45, "sdb": 45, "sdc": 38, "sdd": 38}, 38, 38},
+		{"enterprise above its target", map[string]int{"sda": 49, "sdb": 47, "sdc": 38, "sdd": 37}, (0.5*42 + 0.5*40 + 38 + 37) / 3, 38},
+		{"enterprise near its own max", map[string]int{"sda": 59, "sdb": 45, "sdc": 38, "sdd": 38}, (0.5*52 + 0.5*38 + 38 + 38) / 3, 44},
+		{"default disk over max", map[string]int{"sda": 45, "sdb": 45, "sdc": 46, "sdd": 38}, (0.5*38 + 0.5*38 + 46 + 38) / 3, 46},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := newGroupsConfig()
+			enterprise := DiskIdentity{Model: "ST16000NM001G"}
+			readings := TempReadings{
+				Disks: tt.disks,
+				Identities: map[string]DiskIdentity{
+					"sda": {Device: "sda", Serial: "ZL2A", Model: enterprise.Model},
+					"sdb": {Device: "sdb", Serial: "ZL2B", Model: enterprise.Model},
+				},
+			}
+
+			// Act
+			avg, limit := diskControlInputs(NewAggregator(config), readings, time.Time{})
+
+			// Assert
+			assert.InDelta(t, tt.expectedAvg, avg, 0.01)
+			assert.Equal(t, tt.expectedLimit, limit)
+		})
+	}
+}
+
+// TestDiskControlInputs_FlashControl tests that in flash control mode a hot flash drive raises the input
+func TestDiskControlInputs_FlashControl(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        string
+		flash       map[string]int
+		expectedAvg float64
+	}{
+		{"flash hotter than its target", SensorModeControl, map[string]int{"nvme0n1": 55, "nvme1n1": 52}, 43},
+		{"flash cooler than its target", SensorModeControl, map[string]int{"nvme0n1": 45}, 38},
+		{"emergency mode ignores flash", SensorModeEmergency, map[string]int{"nvme0n1": 60}, 38},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := &Config{
+				Temperature: TemperatureConfig{TargetHDD: 38, MaxHDD: 45},
+				Disks:       DiskConfig{Flash: FlashConfig{Enabled: true, Mode: tt.mode, TargetTemp: 50, MaxTemp: 70}},
+			}
+			setDefaults(config)
+			readings := TempReadings{Disks: map[string]int{"sda": 38, "sdb": 38}, Flash: tt.flash}
+
+			// Act
+			avg, limit := diskControlInputs(NewAggregator(config), readings, time.Time{})
+
+			// Assert
+			assert.InDelta(t, tt.expectedAvg, avg, 0.01)
+			assert.Equal(t, 38, limit, "flash drives never count against max_hdd")
+		})
+	}
+}
+
+// TestDiskControlInputs_NoGroups tests that without groups the inputs match the plain warmest-N average
+func TestDiskControlInputs_NoGroups(t *testing.T) {
+	// Arrange
+	config := &Config{}
+	setDefaults(config)
+	temps := map[string]int{"sda": 35, "sdb": 42, "sdc": 38, "sdd": 45, "sde": 40, "sdf": 37}
+
+	// Act
+	avg, limit := diskControlInputs(NewAggregator(config), TempReadings{Disks: temps}, time.Time{})
+
+	// Assert
+	assert.InDelta(t, GetAverageOfWarmest(temps, config.Temperature.WarmestDisks), avg, 0.001)
+	assert.Equal(t, GetMaxTemperature(temps), limit)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Directory of persistent disk symlinks maintained by udev
+const diskByIDPath = "/dev/disk/by-id"
+
+// Time before a disk whose identity could not be resolved is tried again
+const identityRetryInterval = 10 * time.Minute
+
+var (
+	// Identities resolved with smartctl -i, by kernel name
+	diskIdentities = newDiskIdentityCache(sysBlockPath, smartctlIdentity)
+)
+
+// DiskIdentity identifies a physical disk independently of its kernel name,
+// which can change across reboots and HBA rescans
+type DiskIdentity struct {
+	Device string // Kernel name (sda)
+	Serial string
+	Model  string
+	WWN    string // World Wide Name (0x5000cca252c0ffee), empty if not reported
+	ByID   string // Name under /dev/disk/by-id (ata-WDC_WD80EFAX-68KNBN0_VAG1234)
+}
+
+// diskFingerprint is what can be read cheaply about the disk behind a kernel name;
+// when it changes a different disk took the name and its identity is resolved again
+type diskFingerprint struct {
+	byID string // /dev/disk/by-id name, empty without udev (Docker)
+	size string // Capacity in sectors from /sys/block/<device>/size
+	wwid string // Kernel WWID (naa.5000cca2..., t10.ATA <model> <serial>), empty if not exposed
+}
+
+// identityEntry is one cached resolution, successful or not
+type identityEntry struct {
+	fingerprint diskFingerprint
+	identity    DiskIdentity
+	err         error     // Set for a failed resolution, retried after identityRetryInterval
+	resolvedAt  time.Time // When smartctl -i ran
+}
+
+// diskIdentityCache runs smartctl -i once per disk instead of once per poll
+type diskIdentityCache struct {
+	sysBlock string
+	resolve  func(ctx context.Context, device string) (DiskIdentity, error)
+	now      func() time.Time
+	entries  map[string]identityEntry // By kernel name
+}
+
+// newDiskIdentityCache creates an empty cache resolving identities with resolve
+func newDiskIdentityCache(sysBlock string, resolve func(context.Context, string) (DiskIdentity, error)) *diskIdentityCache {
+	return &diskIdentityCache{
+		sysBlock: sysBlock,
+		resolve:  resolve,
+		now:      time.Now,
+		entries:  make(map[string]identityEntry),
+	}
+}
+
+// ResolveDiskIdentity returns the identity of device, running smartctl -i unless
+// the cached identity still matches the device's by-id name, size and WWID
+func ResolveDiskIdentity(ctx context.Context, device string, byID map[string]string) (DiskIdentity, error) {
+	return diskIdentities.Resolve(ctx, device, byID[device])
+}
+
+// Resolve returns the cached identity of device while its fingerprint is
+// unchanged, and repeats a failure until identityRetryInterval has passed
+func (c *diskIdentityCache) Resolve(ctx context.Context, device, byID string) (DiskIdentity, error) {
+	fingerprint := c.fingerprint(device, byID)
+	if entry, ok := c.entries[device]; ok && entry.fingerprint == fingerprint {
+		if entry.err == nil {
+			return entry.identity, nil
+		}
+		if c.now().Sub(entry.resolvedAt) < identityRetryInterval {
+			return DiskIdentity{}, entry.err
+		}
+	}
+
+	identity, err := c.resolve(ctx, device)
+	if err != nil {
+		if ctx.Err() == nil {
+			c.entries[device] = identityEntry{fingerprint: fingerprint, err: err, resolvedAt: c.now()}
+		}
+		return DiskIdentity{}, err
+	}
+
+	identity.Device = device
+	identity.ByID = byID
+	c.entries[device] = identityEntry{fingerprint: fingerprint, identity: identity, resolvedAt: c.now()}
+	return identity, nil
+}
+
+// fingerprint reads the by-id name, size and WWID of device; unreadable fields stay empty
+func (c *diskIdentityCache) fingerprint(device, byID string) diskFingerprint {
+	read := func(paths ...string) string {
+		for _, path := range paths {
+			if data, err := os.ReadFile(filepath.Join(c.sysBlock, device, path)); err == nil {
+				return strings.TrimSpace(string(data))
+			}
+		}
+		return ""
+	}
+	return diskFingerprint{
+		byID: byID,
+		size: read("size"),
+		wwid: read("wwid", "device/wwid"),
+	}
+}
+
+// smartctlIdentity reads the identity of device with smartctl -i
+func smartctlIdentity(ctx context.Context, device string) (DiskIdentity, error) {
+	output, err := exec.CommandContext(ctx, "smartctl", "-i", fmt.Sprintf("/dev/%s", device)).CombinedOutput()
+	identity := parseSmartctlIdentity(string(output))
+	if identity.Serial == "" {
+		// smartctl exits non-zero for some warnings, so only fail without a serial
+		if err != nil {
+			return DiskIdentity{}, fmt.Errorf("smartctl -i failed for %s: %w", device, err)
+		}
+		return DiskIdentity{}, fmt.Errorf("no serial number found for device %s", device)
+	}
+	return identity, nil
+}
+
+// parseSmartctlIdentity parses the model, serial and WWN from `smartctl -i` output
+// Handles SATA ("Device Model"), SAS ("Vendor"/"Product") and NVMe ("Model Number") disks
+func parseSmartctlIdentity(output string) DiskIdentity {
+	var identity DiskIdentity
+	var vendor string
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "device model", "model number", "product":
+			identity.Model = value
+		case "vendor":
+			vendor = value
+		case "serial number":
+			identity.Serial = value
+		case "lu wwn device id":
+			// SATA: "5 000cca 252c0ffee"
+			identity.WWN = "0x" + strings.ReplaceAll(value, " ", "")
+		case "logical unit id":
+			// SAS: "0x5000c500a1b2c3d4"
+			identity.WWN = value
+		}
+	}
+
+	if vendor != "" && identity.Model != "" {
+		identity.Model = vendor + " " + identity.Model
+	}
+	return identity
+}
+
+// findByIDNames maps kernel names to their preferred /dev/disk/by-id name
+// Partition links resolve to partitions (sda1) and so never match a whole disk
+func findByIDNames(byIDDir string) (map[string]string, error) {
+	entries, err := os.ReadDir(byIDDir)
+	if err != nil {
+		return map[string]string{}, fmt.Errorf("failed to read %s: %w", byIDDir, err)
+	}
+
+	names := make(map[string][]string)
+	for _, entry := range entries {
+		target, err := os.Readlink(filepath.Join(byIDDir, entry.Name()))
+		if err != nil {
+			continue // Not a symlink
+		}
+		device := filepath.Base(target)
+		names[device] = append(names[device], entry.Name())
+	}
+
+	byID := make(map[string]string, len(names))
+	for device, candidates := range names {
+		sort.Slice(candidates, func(i, j int) bool {
+			ri, rj := byIDRank(candidates[i]), byIDRank(candidates[j])
+			if ri != rj {
+				return ri < rj
+			}
+			return candidates[i] < candidates[j]
+		})
+		byID[device] = candidates[0]
+	}
+	return byID, nil
+}
+
+// byIDRank orders by-id names so the ones containing model and serial come first
+func byIDRank(name string) int {
+	switch {
+	case strings.HasPrefix(name, "ata-"):
+		return 0
+	case strings.HasPrefix(name, "nvme-") && !strings.HasPrefix(name, "nvme-eui."):
+		return 1
+	case strings.HasPrefix(name, "scsi-"):
+		return 2
+	case strings.HasPrefix(name, "wwn-"):
+		return 3
+	default:
+		return 4
+	}
+}
+
+// selectDisk applies the identity-based filters to a spinning disk: exclude
+// patterns on serial and model, then include patterns on any of its names
+func selectDisk(identity DiskIdentity, disks DiskConfig) string {
+	if matchesIdentityExcludePattern(identity, disks.ExcludePatterns) {
+		return DiskExcluded
+	}
+	if len(disks.IncludePatterns) == 0 {
+		return DiskMonitored
+	}
+	for _, name := range []string{identity.Device, identity.Serial, identity.Model, identity.ByID} {
+		if name != "" && matchesExcludePattern(name, disks.IncludePatterns) {
+			return DiskMonitored
+		}
+	}
+	return DiskNotIncluded
+}
+
+// matchesIdentityExcludePattern checks the disk's serial and model against the exclude patterns
+func matchesIdentityExcludePattern(identity DiskIdentity, patterns []string) bool {
+	return (identity.Serial != "" && matchesExcludePattern(identity.Serial, patterns)) ||
+		(identity.Model != "" && matchesExcludePattern(identity.Model, patterns))
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseSmartctlIdentity tests SATA, SAS and NVMe `smartctl -i` output
+func TestParseSmartctlIdentity(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected DiskIdentity
+	}{
+		{
+			name: "SATA",
+			output: `=== START OF INFORMATION SECTION ===
+Model Family:     Western Digital Red
+Device Model:     WDC WD80EFAX-68KNBN0
+Serial Number:    VAGXYZ12
+LU WWN Device Id: 5 000cca 252c0ffee
+Firmware Version: 81.00A81
+`,
+			expected: DiskIdentity{Model: "WDC WD80EFAX-68KNBN0", Serial: "VAGXYZ12", WWN: "0x5000cca252c0ffee"},
+		},
+		{
+			name: "SAS",
+			output: `Vendor:               SEAGATE
+Product:              ST4000NM0023
+Revision:             GS0F
+Serial number:        Z1Z0ABCD
+Logical Unit id:      0x5000c50056a1b2c3
+`,
+			expected: DiskIdentity{Model: "SEAGATE ST4000NM0023", Serial: "Z1Z0ABCD", WWN: "0x5000c50056a1b2c3"},
+		},
+		{
+			name: "NVMe",
+			output: `Model Number:                       Samsung SSD 970 EVO Plus 1TB
+Serial Number:                      S4EWNX0N123456
+Firmware Version:                   2B2QEXM7
+`,
+			expected: DiskIdentity{Model: "Samsung SSD 970 EVO Plus 1TB", Serial: "S4EWNX0N123456"},
+		},
+		{
+			name:     "no identity",
+			output:   "Smartctl open device: /dev/sdz failed: No such device\n",
+			expected: DiskIdentity{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			identity := parseSmartctlIdentity(tt.output)
+
+			// Assert
+			assert.Equal(t, tt.expected, identity)
+		})
+	}
+}
+
+// fakeIdentityResolver returns identities by device and counts smartctl -i runs
+type fakeIdentityResolver struct {
+	identities map[string]DiskIdentity
+	calls      int
+}
+
+// resolve returns the device's identity, or an error for an unknown device
+func (f *fakeIdentityResolver) resolve(ctx context.Context, device string) (DiskIdentity, error) {
+	f.calls++
+	identity, ok := f.identities[device]
+	if !ok {
+		return DiskIdentity{}, errors.New("no serial number found")
+	}
+	return identity, nil
+}
+
+// newTestIdentityCache creates a cache over a temporary /sys/block with sda present
+func newTestIdentityCache(t *testing.T, resolver *fakeIdentityResolver) (*diskIdentityCache, func(size, wwid string)) {
+	t.Helper()
+	sysBlock := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(sysBlock, "sda", "device"), 0755))
+	setDisk := func(size, wwid string) {
+		require.NoError(t, os.WriteFile(filepath.Join(sysBlock, "sda", "size"), []byte(size+"\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(sysBlock, "sda", "device", "wwid"), []byte(wwid+"\n"), 0644))
+	}
+	setDisk("15628053168", "naa.5000cca252c0ffee")
+	return newDiskIdentityCache(sysBlock, resolver.resolve), setDisk
+}
+
+// TestDiskIdentityCache_Swap tests that a disk swapped onto the same kernel name is resolved again without by-id
+func TestDiskIdentityCache_Swap(t *testing.T) {
+	// Arrange
+	resolver := &fakeIdentityResolver{identities: map[string]DiskIdentity{"sda": {Serial: "VAG1"}}}
+	cache, setDisk := newTestIdentityCache(t, resolver)
+	first, err := cache.Resolve(context.Background(), "sda", "")
+	require.NoError(t, err)
+	cached, err := cache.Resolve(context.Background(), "sda", "")
+	require.NoError(t, err)
+
+	// Act - same model and size, different drive
+	setDisk("15628053168", "naa.5000cca252c0beef")
+	resolver.identities["sda"] = DiskIdentity{Serial: "VAG2"}
+	swapped, err := cache.Resolve(context.Background(), "sda", "")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "VAG1", first.Serial)
+	assert.Equal(t, first, cached)
+	assert.Equal(t, DiskIdentity{Device: "sda", Serial: "VAG2"}, swapped)
+	assert.Equal(t, 2, resolver.calls)
+}
+
+// TestDiskIdentityCache_Failure tests that a failed resolution is retried only after identityRetryInterval
+func TestDiskIdentityCache_Failure(t *testing.T) {
+	// Arrange
+	resolver := &fakeIdentityResolver{identities: map[string]DiskIdentity{}}
+	cache, _ := newTestIdentityCache(t, resolver)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	// Act
+	_, firstErr := cache.Resolve(context.Background(), "sda", "")
+	now = now.Add(identityRetryInterval / 2)
+	_, cachedErr := cache.Resolve(context.Background(), "sda", "")
+	callsWhileCached := resolver.calls
+	now = now.Add(identityRetryInterval)
+	resolver.identities["sda"] = DiskIdentity{Serial: "VAG1"}
+	identity, err := cache.Resolve(context.Background(), "sda", "")
+
+	// Assert
+	require.Error(t, firstErr)
+	assert.Equal(t, firstErr, cachedErr)
+	assert.Equal(t, 1, callsWhileCached)
+	require.NoError(t, err)
+	assert.Equal(t, "VAG1", identity.Serial)
+}
+
+// TestFindByIDNames tests that the most descriptive by-id name is chosen per disk
+func TestFindByIDNames(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	links := map[string]string{
+		"wwn-0x5000cca252c0ffee":                  "../../sda",
+		"ata-WDC_WD80EFAX-68KNBN0_VAGXYZ12":       "../../sda",
+		"ata-WDC_WD80EFAX-68KNBN0_VAGXYZ12-part1": "../../sda1",
+		"nvme-eui.0025385b91234567":               "../../nvme0n1",
+		"nvme-Samsung_SSD_970_EVO_S4EWNX0N123456": "../../nvme0n1",
+		"wwn-0x5000c50056a1b2c3":                  "../../sdb",
+	}
+	for name, target := range links {
+		require.NoError(t, os.Symlink(target, filepath.Join(dir, name)))
+	}
+
+	// Act
+	byID, err := findByIDNames(dir)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "ata-WDC_WD80EFAX-68KNBN0_VAGXYZ12", byID["sda"])
+	assert.Equal(t, "ata-WDC_WD80EFAX-68KNBN0_VAGXYZ12-part1", byID["sda1"])
+	assert.Equal(t, "nvme-Samsung_SSD_970_EVO_S4EWNX0N123456", byID["nvme0n1"])
+	assert.Equal(t, "wwn-0x5000c50056a1b2c3", byID["sdb"])
+}
+
+// TestFindByIDNames_MissingDir tests that a missing by-id directory is an error with an empty map
+func TestFindByIDNames_MissingDir(t *testing.T) {
+	// Act
+	byID, err := findByIDNames(filepath.Join(t.TempDir(), "missing"))
+
+	// Assert
+	require.Error(t, err)
+	assert.Empty(t, byID)
+}
+
+// TestMatchesIdentityExcludePattern tests excluding disks by serial or model
+func TestMatchesIdentityExcludePattern(t *testing.T) {
+	identity := DiskIdentity{Device: "sda", Serial: "VAGXYZ12", Model: "WDC WD80EFAX-68KNBN0"}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		expected bool
+	}{
+		{"serial", []string{"^VAGXYZ12$"}, true},
+		{"model", []string{"WD80EFAX"}, true},
+		{"kernel name only", []string{"^sda$"}, false},
+		{"default patterns", []string{"^loop", "^sr", "^zd", "^dm-"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			matched := matchesIdentityExcludePattern(identity, tt.patterns)
+
+			// Assert
+			assert.Equal(t, tt.expected, matched)
+		})
+	}
+}
+
+// TestSelectDisk tests serial/model exclusion and include patterns
+func TestSelectDisk(t *testing.T) {
+	identity := DiskIdentity{Device: "sda", Serial: "VAGXYZ12", Model: "WDC WD80EFAX-68KNBN0", ByID: "ata-WDC_WD80EFAX-68KNBN0_VAGXYZ12"}
+
+	tests := []struct {
+		name     string
+		disks    DiskConfig
+		expected string
+	}{
+		{"no filters", DiskConfig{}, DiskMonitored},
+		{"excluded by serial", DiskConfig{ExcludePatterns: []string{"^VAGXYZ12$"}}, DiskExcluded},
+		{"included by serial", DiskConfig{IncludePatterns: []string{"^VAGXYZ12$"}}, DiskMonitored},
+		{"included by by-id name", DiskConfig{IncludePatterns: []string{"^ata-WDC_"}}, DiskMonitored},
+		{"included by kernel name", DiskConfig{IncludePatterns: []string{"^sda$"}}, DiskMonitored},
+		{"not included", DiskConfig{IncludePatterns: []string{"^ST16000"}}, DiskNotIncluded},
+		{"exclude wins over include", DiskConfig{IncludePatterns: []string{"WD80"}, ExcludePatterns: []string{"WD80EFAX"}}, DiskExcluded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			status := selectDisk(identity, tt.disks)
+
+			// Assert
+			assert.Equal(t, tt.expected, status)
+		})
+	}
+}
+
+// TestTempReadings_Identity tests the kernel name fallback for unidentified disks
+func TestTempReadings_Identity(t *testing.T) {
+	// Arrange
+	readings := TempReadings{Identities: map[string]DiskIdentity{"sda": {Device: "sda", Serial: "VAGXYZ12"}}}
+
+	// Act & Assert
+	assert.Equal(t, "VAGXYZ12", readings.Identity("sda").Serial)
+	assert.Equal(t, "sdb", readings.Identity("sdb").Serial)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Emergency reasons, used as the reason label of the emergency metrics
+const (
+	ReasonCPUTemp       = "cpu_temp"       // CPU above max_cpu
+	ReasonHDDTemp       = "hdd_temp"       // A disk above its max_hdd
+	ReasonFlashTemp     = "flash_temp"     // A flash drive above flash.max_temp
+	ReasonEnclosureTemp = "enclosure_temp" // A SES enclosure sensor above enclosures.max_temp
+	ReasonIPMIFailure   = "ipmi_failure"   // maxIPMIFailures fan commands in a row failed
+	ReasonSensorFailure = "sensor_failure" // No usable reading: no disk readable, CPU sensor or discovery failed
+	ReasonSensorPartial = "sensor_partial" // Some discovered disks could not be read
+)
+
+// EmergencyInputs are the temperatures the emergency conditions are evaluated on
+type EmergencyInputs struct {
+	CPUTemp          float64
+	LimitTemp        int // Hottest disk, shifted onto temperature.max_hdd by its disk group
+	MaxFlashTemp     int
+	MaxEnclosureTemp int
+}
+
+// newEmergencyInputs collects the emergency inputs from one poll
+func newEmergencyInputs(readings TempReadings, limitTemp int) EmergencyInputs {
+	return EmergencyInputs{
+		CPUTemp:          readings.CPU,
+		LimitTemp:        limitTemp,
+		MaxFlashTemp:     GetMaxTemperature(readings.Flash),
+		MaxEnclosureTemp: GetMaxTemperature(readings.Enclosure.Temperatures),
+	}
+}
+
+// EmergencyCondition is a temperature check that forces fans to 100% while it holds
+type EmergencyCondition struct {
+	Reason string
+	Active func(inputs EmergencyInputs, config *Config) bool
+}
+
+// emergencyConditions are evaluated every loop in order; the first active one is the reason
+var emergencyConditions = []EmergencyCondition{
+	{ReasonCPUTemp, func(inputs EmergencyInputs, config *Config) bool {
+		return inputs.CPUTemp > config.Temperature.MaxCPU
+	}},
+	{ReasonHDDTemp, func(inputs EmergencyInputs, config *Config) bool {
+		return inputs.LimitTemp > int(config.Temperature.MaxHDD)
+	}},
+	{ReasonFlashTemp, func(inputs EmergencyInputs, config *Config) bool {
+		return config.Disks.Flash.Enabled && inputs.MaxFlashTemp > int(config.Disks.Flash.MaxTemp)
+	}},
+	{ReasonEnclosureTemp, func(inputs EmergencyInputs, config *Config) bool {
+		return config.Enclosures.Enabled && inputs.MaxEnclosureTemp > int(config.Enclosures.MaxTemp)
+	}},
+}
+
+// emergencyReasons lists every reason, including those raised by the loop itself
+// rather than a temperature condition, so each gets a gauge that returns to 0
+func emergencyReasons() []string {
+	reasons := make([]string, 0, len(emergencyConditions)+3)
+	for _, condition := range emergencyConditions {
+		reasons = append(reasons, condition.Reason)
+	}
+	return append(reasons, ReasonIPMIFailure, ReasonSensorFailure, ReasonSensorPartial)
+}
+
+// checkEmergencyConditions returns the reason of the first active emergency condition, or ""
+func checkEmergencyConditions(inputs EmergencyInputs, config *Config) string {
+	for _, condition := range emergencyConditions {
+		if condition.Active(inputs, config) {
+			return condition.Reason
+		}
+	}
+	return "" // No emergency
+}
+
+// EmergencyState follows the active emergency reason across loops to count
+// transitions and the time spent in each reason
+type EmergencyState struct {
+	reason string
+	since  time.Time // When reason became active
+	last   time.Time // Previous observation
+}
+
+// Reason returns the reason observed last, empty outside an emergency
+func (s *EmergencyState) Reason() string {
+	return s.reason
+}
+
+// Observe records the reason active at now. The time since the previous
+// observation is credited to the reason that was active during it
+func (s *EmergencyState) Observe(reason string, now time.Time) {
+	if s.reason != "" {
+		RecordEmergencyTime(s.reason, now.Sub(s.last))
+	}
+	if reason != s.reason {
+		if s.reason != "" {
+			slog.Info("Emergency cleared", "emergency", s.reason, "duration", now.Sub(s.since))
+		}
+		if reason != "" {
+			RecordEmergencyTransition(reason)
+			slog.Warn("Emergency entered", "emergency", reason)
+		}
+		s.since = now
+	}
+	s.reason, s.last = reason, now
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCheckEmergencyConditions_CPUOverTemp tests CPU emergency condition
+func TestCheckEmergencyConditions_CPUOverTemp(t *testing.T) {
+	// Arrange
+	config := &Config{
+		Temperature: TemperatureConfig{
+			MaxCPU: 75.0,
+			MaxHDD: 45.0,
+		},
+	}
+	cpuTemp := 80.0   // Above max
+	maxDiskTemp := 40 // Normal
+
+	// Act
+	reason := checkEmergencyConditions(EmergencyInputs{CPUTemp: cpuTemp, LimitTemp: maxDiskTemp}, config)
+
+	// Assert
+	assert.Equal(t, "cpu_temp", reason)
+}
+
+// TestCheckEmergencyConditions_HDDOverTemp tests HDD emergency condition
+func TestCheckEmergencyConditions_HDDOverTemp(t *testing.T) {
+	// Arrange
+	config := &Config{
+		Temperature: TemperatureConfig{
+			MaxCPU: 75.0,
+			MaxHDD: 45.0,
+		},
+	}
+	cpuTemp := 60.0   // Normal
+	maxDiskTemp := 50 // Above max
+
+	// Act
+	reason := checkEmergencyConditions(EmergencyInputs{CPUTemp: cpuTemp, LimitTemp: maxDiskTemp}, config)
+
+	// Assert
+	assert.Equal(t, "hdd_temp", reason)
+}
+
+// TestCheckEmergencyConditions_BothNormal tests no emergency condition
+func TestCheckEmergencyConditions_BothNormal(t *testing.T) {
+	// Arrange
+	config := &Config{
+		Temperature: TemperatureConfig{
+			MaxCPU: 75.0,
+			MaxHDD: 45.0,
+		},
+	}
+	cpuTemp := 60.0   // Normal
+	maxDiskTemp := 40 // Normal
+
+	// Act
+	reason := checkEmergencyConditions(EmergencyInputs{CPUTemp: cpuTemp, LimitTemp: maxDiskTemp}, config)
+
+	// Assert
+	assert.Equal(t, "", reason)
+}
+
+// TestCheckEmergencyConditions_BothOverTemp tests both over temp
+func TestCheckEmergencyConditions_BothOverTemp(t *testing.T) {
+	// Arrange
+	config := &Config{
+		Temperature: TemperatureConfig{
+			MaxCPU: 75.0,
+			MaxHDD: 45.0,
+		},
+	}
+	cpuTemp := 80.0   // Above max
+	maxDiskTemp := 50 // Above max
+
+	// Act
+	reason := checkEmergencyConditions(EmergencyInputs{CPUTemp: cpuTemp, LimitTemp: maxDiskTemp}, config)
+
+	// Assert - CPU check comes first, so should return cpu_temp
+	assert.Equal(t, "cpu_temp", reason)
+}
+
+// TestCheckEmergencyConditions_FlashOverTemp tests the flash emergency condition
+func TestCheckEmergencyConditions_FlashOverTemp(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  bool
+		flash    int
+		expected string
+	}{
+		{"over max", true, 70, "flash_temp"},
+		{"at max", true, 65, ""},
+		{"disabled", false, 70, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := &Config{
+				Temperature: TemperatureConfig{MaxCPU: 75.0, MaxHDD: 45.0},
+				Disks:       DiskConfig{Flash: FlashConfig{Enabled: tt.enabled, MaxTemp: 65.0}},
+			}
+
+			// Act
+			reason := checkEmergencyConditions(EmergencyInputs{CPUTemp: 60.0, LimitTemp: 40, MaxFlashTemp: tt.flash}, config)
+
+			// Assert
+			assert.Equal(t, tt.expected, reason)
+		})
+	}
+}
+
+// TestCheckEmergencyConditions_EnclosureOverTemp tests the SES enclosure emergency condition
+func TestCheckEmergencyConditions_EnclosureOverTemp(t *testing.T) {
+	tests := []struct {
+		name      string
+		enabled   bool
+		enclosure int
+		expected  string
+	}{
+		{"over max", true, 56, "enclosure_temp"},
+		{"at max", true, 55, ""},
+		{"disabled", false, 60, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := &Config{
+				Temperature: TemperatureConfig{MaxCPU: 75.0, MaxHDD: 45.0},
+				Enclosures:  EnclosureConfig{Enabled: tt.enabled, MaxTemp: 55.0},
+			}
+
+			// Act
+			reason := checkEmergencyConditions(EmergencyInputs{CPUTemp: 60.0, LimitTemp: 40, MaxEnclosureTemp: tt.enclosure}, config)
+
+			// Assert
+			assert.Equal(t, tt.expected, reason)
+		})
+	}
+}
+
+// TestEmergencyReasons tests that every registered condition has a unique reason label
+func TestEmergencyReasons(t *testing.T) {
+	// Act
+	reasons := emergencyReasons()
+
+	// Assert
+	assert.Len(t, reasons, len(emergencyConditions)+3)
+	seen := make(map[string]bool)
+	for _, reason := range reasons {
+		assert.False(t, seen[reason], "duplicate reason %s", reason)
+		seen[reason] = true
+	}
+	assert.Contains(t, reasons, ReasonIPMIFailure)
+}
+
+// TestEmergencyState_Observe tests the transition and time-in-emergency counters
+func TestEmergencyState_Observe(t *testing.T) {
+	// Arrange
+	initTestMetrics()
+	silenceLogs(t)
+	transitions := testutil.ToFloat64(metrics.EmergencyEntered.WithLabelValues(ReasonHDDTemp))
+	seconds := testutil.ToFloat64(metrics.EmergencyTime.WithLabelValues(ReasonHDDTemp))
+	cpuSeconds := testutil.ToFloat64(metrics.EmergencyTime.WithLabelValues(ReasonCPUTemp))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var state EmergencyState
+
+	// Act - normal, then hdd_temp for two polls, cpu_temp for one, then normal again
+	state.Observe("", start)
+	state.Observe(ReasonHDDTemp, start.Add(30*time.Second))
+	state.Observe(ReasonHDDTemp, start.Add(60*time.Second))
+	state.Observe(ReasonCPUTemp, start.Add(90*time.Second))
+	state.Observe("", start.Add(120*time.Second))
+	state.Observe("", start.Add(150*time.Second))
+
+	// Assert
+	assert.Equal(t, transitions+1, testutil.ToFloat64(metrics.EmergencyEntered.WithLabelValues(ReasonHDDTemp)))
+	assert.Equal(t, seconds+60, testutil.ToFloat64(metrics.EmergencyTime.WithLabelValues(ReasonHDDTemp)))
+	assert.Equal(t, cpuSeconds+30, testutil.ToFloat64(metrics.EmergencyTime.WithLabelValues(ReasonCPUTemp)))
+}
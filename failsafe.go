@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Actions for consecutive sensor read failures (sensor_failure.total_action, partial_action)
+const (
+	SensorFailureNone      = "none"      // Keep the current duty (total) or keep running the PID (partial)
+	SensorFailureFailsafe  = "failsafe"  // Hold fans at failsafe_duty or above
+	SensorFailureEmergency = "emergency" // Force fans to 100%
+)
+
+// handleSensorFailure counts a poll without usable readings and applies
+// sensor_failure.total_action once total_threshold polls in a row failed.
+// Returns the duty and reason applied, or a zero summary when no action is due
+func (c *Controller) handleSensorFailure(ctx context.Context, now time.Time) MetricsSummary {
+	policy := c.config.SensorFailure
+	c.consecutiveSensorFailures++
+	if policy.TotalAction == SensorFailureNone || c.consecutiveSensorFailures < policy.TotalThreshold {
+		// The fans keep their duty, and so does the emergency that set it; observing
+		// it keeps the reason gauge and time in emergency current
+		held := c.emergency.Reason()
+		c.emergency.Observe(held, now)
+		UpdateEmergencyMetrics(held)
+		return MetricsSummary{}
+	}
+
+	// failsafe_duty is a floor: an emergency or a PID already above it keeps its duty
+	duty := max(policy.FailsafeDuty, c.lastDuty)
+	if policy.TotalAction == SensorFailureEmergency {
+		duty = 100
+	}
+	slog.Error("Sensor failure: overriding fan duty",
+		"failures", c.consecutiveSensorFailures, "action", policy.TotalAction, "duty", duty)
+	if err := c.fans.SetAllFans(ctx, duty); err != nil {
+		slog.Error("Failed to set fail-safe fan speed", "error", err)
+		RecordError("ipmi")
+	}
+	c.lastDuty = duty
+
+	c.emergency.Observe(ReasonSensorFailure, now)
+	UpdateEmergencyMetrics(ReasonSensorFailure)
+	metrics.FanDutyPercent.Set(float64(duty))
+	return MetricsSummary{FanDuty: duty, Emergency: ReasonSensorFailure}
+}
+
+// partialSensorAction counts polls in a row with unreadable disks and returns
+// sensor_failure.partial_action once partial_threshold is reached, otherwise none
+// It is called after a successful read, which also ends any run of total failures
+func (c *Controller) partialSensorAction(readings TempReadings) string {
+	c.consecutiveSensorFailures = 0
+	if len(readings.FailedDisks) == 0 {
+		c.consecutivePartialFailures = 0
+		return SensorFailureNone
+	}
+
+	c.consecutivePartialFailures++
+	if c.consecutivePartialFailures < c.config.SensorFailure.PartialThreshold {
+		return SensorFailureNone
+	}
+	return c.config.SensorFailure.PartialAction
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestController_Step_TotalSensorFailure tests the total_action policies and recovery
+func TestController_Step_TotalSensorFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		action   string
+		expected []int // Fan commands after three failed polls
+	}{
+		{"emergency", SensorFailureEmergency, []int{100, 100}},
+		{"failsafe", SensorFailureFailsafe, []int{80, 80}},
+		{"none keeps the current duty", SensorFailureNone, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			temps := &fakeTempSource{err: errors.New("k10temp sensor not found")}
+			fans := &fakeFanActuator{}
+			controller := newTestController(t, temps, fans)
+			controller.config.SensorFailure.TotalAction = tt.action
+			ResetMetrics()
+
+			// Act
+			var summary MetricsSummary
+			for i := 0; i < 3; i++ {
+				var err error
+				summary, err = controller.Step(context.Background())
+				require.Error(t, err)
+			}
+
+			// Assert
+			assert.Equal(t, tt.expected, fans.duties, "the first failure is below total_threshold")
+			if tt.action != SensorFailureNone {
+				assert.Equal(t, ReasonSensorFailure, summary.Emergency)
+				assert.Equal(t, 1.0, testutil.ToFloat64(metrics.EmergencyMode.WithLabelValues(ReasonSensorFailure)))
+			}
+
+			// Act - sensors recover
+			temps.err, temps.diskTemps, temps.cpuTemp = nil, map[string]int{"sda": 38}, 50
+			summary, err := controller.Step(context.Background())
+
+			// Assert
+			require.NoError(t, err)
+			assert.Empty(t, summary.Emergency)
+			assert.Equal(t, 0, controller.consecutiveSensorFailures)
+			assert.Equal(t, 0.0, testutil.ToFloat64(metrics.EmergencyMode.WithLabelValues(ReasonSensorFailure)))
+		})
+	}
+}
+
+// TestController_Step_SensorFailure_HoldsEmergency tests that emergency metrics stay current below total_threshold
+func TestController_Step_SensorFailure_HoldsEmergency(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{diskTemps: map[string]int{"sda": 46}, cpuTemp: 50}
+	fans := &fakeFanActuator{}
+	controller := newTestController(t, temps, fans)
+	clock := controller.clock.(*fakeClock)
+	ResetMetrics()
+	_, err := controller.Step(context.Background())
+	require.NoError(t, err)
+	inEmergency := testutil.ToFloat64(metrics.EmergencyTime.WithLabelValues(ReasonHDDTemp))
+
+	// Act - the next poll fails, below the default total_threshold of 2
+	clock.now = clock.now.Add(30 * time.Second)
+	temps.err = errors.New("smartctl hung")
+	_, err = controller.Step(context.Background())
+
+	// Assert
+	require.Error(t, err)
+	assert.Equal(t, []int{100}, fans.duties, "no fail-safe command below total_threshold")
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.EmergencyMode.WithLabelValues(ReasonHDDTemp)))
+	assert.Equal(t, inEmergency+30, testutil.ToFloat64(metrics.EmergencyTime.WithLabelValues(ReasonHDDTemp)))
+}
+
+// TestController_Step_TotalSensorFailure_KeepsHigherDuty tests that failsafe_duty does not lower the fans
+func TestController_Step_TotalSensorFailure_KeepsHigherDuty(t *testing.T) {
+	// Arrange - a disk over max_hdd puts the fans at 100%
+	temps := &fakeTempSource{diskTemps: map[string]int{"sda": 46}, cpuTemp: 50}
+	fans := &fakeFanActuator{}
+	controller := newTestController(t, temps, fans)
+	controller.config.SensorFailure.TotalAction = SensorFailureFailsafe
+	_, err := controller.Step(context.Background())
+	require.NoError(t, err)
+
+	// Act - every sensor fails until total_threshold is reached
+	temps.err = errors.New("smartctl hung")
+	var summary MetricsSummary
+	for i := 0; i < 2; i++ {
+		summary, err = controller.Step(context.Background())
+		require.Error(t, err)
+	}
+
+	// Assert
+	assert.Equal(t, ReasonSensorFailure, summary.Emergency)
+	assert.Equal(t, 100, summary.FanDuty)
+	assert.Equal(t, []int{100, 100}, fans.duties)
+}
+
+// TestController_Step_PartialSensorFailure tests the partial_action policies
+func TestController_Step_PartialSensorFailure(t *testing.T) {
+	tests := []struct {
+		name           string
+		action         string
+		expectedDuty   int
+		expectedReason string
+	}{
+		{"failsafe floor", SensorFailureFailsafe, 80, ReasonSensorPartial},
+		{"emergency", SensorFailureEmergency, 100, ReasonSensorPartial},
+		{"none", SensorFailureNone, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange - readable disks at target, so the PID alone stays below the fail-safe duty
+			temps := &fakeTempSource{diskTemps: map[string]int{"sda": 38}, failedDisks: []string{"sdb"}, cpuTemp: 50}
+			fans := &fakeFanActuator{}
+			controller := newTestController(t, temps, fans)
+			controller.config.SensorFailure.PartialAction = tt.action
+
+			// Act
+			var summaries []MetricsSummary
+			for i := 0; i < 3; i++ {
+				summary, err := controller.Step(context.Background())
+				require.NoError(t, err)
+				summaries = append(summaries, summary)
+			}
+
+			// Assert
+			assert.Empty(t, summaries[1].Emergency, "below partial_threshold")
+			assert.Equal(t, tt.expectedReason, summaries[2].Emergency)
+			if tt.expectedDuty > 0 {
+				assert.Equal(t, tt.expectedDuty, summaries[2].FanDuty)
+			} else {
+				assert.Less(t, summaries[2].FanDuty, 80)
+			}
+		})
+	}
+}
+
+// TestController_Step_PartialSensorFailure_Resets tests that a clean poll restarts the count
+func TestController_Step_PartialSensorFailure_Resets(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{diskTemps: map[string]int{"sda": 38}, failedDisks: []string{"sdb"}, cpuTemp: 50}
+	controller := newTestController(t, temps, &fakeFanActuator{})
+	controller.config.SensorFailure.PartialAction = SensorFailureEmergency
+
+	// Act
+	controller.Step(context.Background())
+	controller.Step(context.Background())
+	temps.failedDisks = nil
+	controller.Step(context.Background())
+	temps.failedDisks = []string{"sdb"}
+	summary, err := controller.Step(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	assert.Empty(t, summary.Emergency)
+	assert.Equal(t, 1, controller.consecutivePartialFailures)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Sector size used by /sys/block/*/stat counters, independent of the device
+	blockStatSectorSize = 512
+)
+
+// FeedForward computes a fan duty bias from CPU utilisation and disk I/O
+// Temperatures lag workload by minutes, so load is used to spin fans up early
+type FeedForward struct {
+	config  FeedForwardConfig
+	cpuGain float64 // Unset gains contribute nothing
+	ioGain  float64
+
+	// Sources (overridable for testing)
+	procStatPath string
+	sysBlockPath string
+
+	// Previous sample for rate calculation
+	prevCPUTotal uint64
+	prevCPUIdle  uint64
+	prevSectors  map[string]uint64 // Per disk, so a disk joining the set adds no lifetime total
+	prevTime     time.Time
+	hasPrev      bool
+}
+
+// NewFeedForward creates a feed-forward estimator reading the live /proc and /sys
+func NewFeedForward(config FeedForwardConfig) *FeedForward {
+	f := &FeedForward{
+		config:       config,
+		procStatPath: "/proc/stat",
+		sysBlockPath: "/sys/block",
+	}
+	if config.CPUGain != nil {
+		f.cpuGain = *config.CPUGain
+	}
+	if config.IOGain != nil {
+		f.ioGain = *config.IOGain
+	}
+	return f
+}
+
+// Sample reads current CPU and disk counters at now, the loop's clock time, and
+// returns the duty bias (%). The first call only primes the counters and returns a zero bias
+func (f *FeedForward) Sample(now time.Time, disks []string) (float64, error) {
+	cpuTotal, cpuIdle, err := readCPUCounters(f.procStatPath)
+	if err != nil {
+		return 0, err
+	}
+
+	sectors, err := readDiskSectors(f.sysBlockPath, disks)
+	if err != nil {
+		return 0, err
+	}
+
+	// Need two samples to compute rates
+	if !f.hasPrev {
+		f.storeSample(cpuTotal, cpuIdle, sectors, now)
+		return 0, nil
+	}
+
+	cpuPercent := cpuUtilisation(f.prevCPUTotal, f.prevCPUIdle, cpuTotal, cpuIdle)
+
+	// Only disks present in both samples count; a new disk is primed and a reset counter skipped
+	var ioMBps float64
+	var transferred uint64
+	for disk, count := range sectors {
+		if prev, ok := f.prevSectors[disk]; ok && count >= prev {
+			transferred += count - prev
+		}
+	}
+	if dt := now.Sub(f.prevTime).Seconds(); dt > 0 {
+		ioMBps = float64(transferred) * blockStatSectorSize / dt / (1024 * 1024)
+	}
+
+	f.storeSample(cpuTotal, cpuIdle, sectors, now)
+
+	return f.Bias(cpuPercent, ioMBps), nil
+}
+
+// Bias converts CPU utilisation (%) and disk throughput (MB/s) into a duty bias (%)
+func (f *FeedForward) Bias(cpuPercent, ioMBps float64) float64 {
+	bias := f.cpuGain*cpuPercent + f.ioGain*ioMBps
+	return clamp(bias, 0, f.config.MaxBias)
+}
+
+// Reset discards the previous sample so the next call re-primes the counters
+func (f *FeedForward) Reset() {
+	f.hasPrev = false
+}
+
+// storeSample records the counters for the next rate calculation
+func (f *FeedForward) storeSample(cpuTotal, cpuIdle uint64, sectors map[string]uint64, now time.Time) {
+	f.prevCPUTotal = cpuTotal
+	f.prevCPUIdle = cpuIdle
+	f.prevSectors = sectors
+	f.prevTime = now
+	f.hasPrev = true
+}
+
+// cpuUtilisation returns the busy percentage between two /proc/stat samples
+func cpuUtilisation(prevTotal, prevIdle, total, idle uint64) float64 {
+	if total <= prevTotal || idle < prevIdle {
+		return 0
+	}
+	totalDelta := float64(total - prevTotal)
+	idleDelta := float64(idle - prevIdle)
+	return clamp((totalDelta-idleDelta)/totalDelta*100, 0, 100)
+}
+
+// readCPUCounters reads the aggregate "cpu" line from /proc/stat
+// Returns total and idle (idle + iowait) jiffies
+func readCPUCounters(path string) (uint64, uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return parseProcStat(string(data))
+}
+
+// parseProcStat parses the aggregate CPU line of /proc/stat
+// Format: "cpu  user nice system idle iowait irq softirq steal guest guest_nice"
+func parseProcStat(content string) (uint64, uint64, error) {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+
+		// guest and guest_nice are already included in user and nice
+		values := fields[1:]
+		if len(values) > 8 {
+			values = values[:8]
+		}
+
+		var total uint64
+		counters := make([]uint64, len(values))
+		for i, field := range values {
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return 0, 0, fmt.Errorf("failed to parse cpu counter %q: %w", field, err)
+			}
+			counters[i] = value
+			total += value
+		}
+
+		idle := counters[3]
+		if len(counters) > 4 {
+			idle += counters[4] // iowait
+		}
+
+		return total, idle, nil
+	}
+
+	return 0, 0, fmt.Errorf("no aggregate cpu line found in /proc/stat")
+}
+
+// readDiskSectors returns the sectors read and written of each given block device
+func readDiskSectors(sysBlockPath string, disks []string) (map[string]uint64, error) {
+	counts := make(map[string]uint64, len(disks))
+	for _, disk := range disks {
+		statPath := filepath.Join(sysBlockPath, disk, "stat")
+		data, err := os.ReadFile(statPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", statPath, err)
+		}
+
+		sectors, err := parseBlockStat(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", statPath, err)
+		}
+		counts[disk] = sectors
+	}
+	return counts, nil
+}
+
+// parseBlockStat returns sectors read + sectors written from a /sys/block/*/stat line
+// Fields: read_ios read_merges read_sectors read_ticks write_ios write_merges write_sectors ...
+func parseBlockStat(content string) (uint64, error) {
+	fields := strings.Fields(content)
+	if len(fields) < 7 {
+		return 0, fmt.Errorf("expected at least 7 fields, got %d", len(fields))
+	}
+
+	readSectors, err := strconv.ParseUint(fields[2], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid read sectors %q: %w", fields[2], err)
+	}
+
+	writeSectors, err := strconv.ParseUint(fields[6], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid write sectors %q: %w", fields[6], err)
+	}
+
+	return readSectors + writeSectors, nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseProcStat_AggregateLine tests parsing of the aggregate cpu line
+func TestParseProcStat_AggregateLine(t *testing.T) {
+	// Arrange
+	content := "cpu  100 0 50 800 50 0 0 0 10 0\ncpu0 50 0 25 400 25 0 0 0 5 0\n"
+
+	// Act
+	total, idle, err := parseProcStat(content)
+
+	// Assert - guest columns are excluded from the total
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1000), total)
+	assert.Equal(t, uint64(850), idle) // idle + iowait
+}
+
+// TestParseProcStat_MissingCPULine tests error on missing aggregate line
+func TestParseProcStat_MissingCPULine(t *testing.T) {
+	// Act
+	_, _, err := parseProcStat("intr 12345\nctxt 6789\n")
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no aggregate cpu line")
+}
+
+// TestParseBlockStat_SumsReadAndWriteSectors tests block stat parsing
+func TestParseBlockStat_SumsReadAndWriteSectors(t *testing.T) {
+	// Arrange
+	content := "    1000      10    2048     500     2000      20    4096     900        0     700    1400\n"
+
+	// Act
+	sectors, err := parseBlockStat(content)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2048+4096), sectors)
+}
+
+// TestParseBlockStat_TooFewFields tests error on truncated stat line
+func TestParseBlockStat_TooFewFields(t *testing.T) {
+	// Act
+	_, err := parseBlockStat("1 2 3")
+
+	// Assert
+	require.Error(t, err)
+}
+
+// TestCPUUtilisation tests busy percentage between two samples
+func TestCPUUtilisation(t *testing.T) {
+	// 1000 jiffies elapsed, 250 of them idle -> 75% busy
+	assert.InDelta(t, 75.0, cpuUtilisation(1000, 800, 2000, 1050), 0.01)
+
+	// Counter reset or no elapsed time -> 0
+	assert.Equal(t, 0.0, cpuUtilisation(2000, 1000, 1000, 500))
+	assert.Equal(t, 0.0, cpuUtilisation(1000, 800, 1000, 800))
+}
+
+// TestFeedForward_Bias tests gain application and clamping
+func TestFeedForward_Bias(t *testing.T) {
+	// Arrange
+	ff := NewFeedForward(FeedForwardConfig{Enabled: true, CPUGain: float64Ptr(0.1), IOGain: float64Ptr(0.05), MaxBias: 20})
+
+	// Act & Assert
+	assert.InDelta(t, 5.0+2.5, ff.Bias(50, 50), 0.01)
+	assert.Equal(t, 0.0, ff.Bias(0, 0))
+	assert.Equal(t, 20.0, ff.Bias(100, 1000), "Bias should be clamped to max_bias")
+}
+
+// TestFeedForward_Sample tests rate calculation from two counter samples
+func TestFeedForward_Sample(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	procStat := filepath.Join(dir, "stat")
+	sysBlock := filepath.Join(dir, "block")
+	require.NoError(t, os.MkdirAll(filepath.Join(sysBlock, "sda"), 0755))
+
+	writeCounters := func(cpuLine, blockLine string) {
+		require.NoError(t, os.WriteFile(procStat, []byte(cpuLine), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(sysBlock, "sda", "stat"), []byte(blockLine), 0644))
+	}
+
+	ff := NewFeedForward(FeedForwardConfig{Enabled: true, CPUGain: float64Ptr(0.1), IOGain: float64Ptr(0), MaxBias: 100})
+	ff.procStatPath = procStat
+	ff.sysBlockPath = sysBlock
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Act - first sample primes the counters
+	writeCounters("cpu  0 0 0 1000 0 0 0 0\n", "0 0 0 0 0 0 0 0 0 0 0\n")
+	bias1, err := ff.Sample(start, []string{"sda"})
+	require.NoError(t, err)
+
+	// Second sample: 1000 jiffies, all busy
+	writeCounters("cpu  1000 0 0 1000 0 0 0 0\n", "0 0 100 0 0 0 100 0 0 0 0\n")
+	bias2, err := ff.Sample(start.Add(10*time.Second), []string{"sda"})
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, 0.0, bias1, "First sample should only prime counters")
+	assert.InDelta(t, 10.0, bias2, 0.01) // 100% CPU * 0.1
+}
+
+// TestFeedForward_Sample_IORate tests that throughput is divided by the time between the given sample times
+func TestFeedForward_Sample_IORate(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	procStat := filepath.Join(dir, "stat")
+	sysBlock := filepath.Join(dir, "block")
+	require.NoError(t, os.WriteFile(procStat, []byte("cpu  0 0 0 1000 0 0 0 0\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(sysBlock, "sda"), 0755))
+	writeSectors := func(read, written string) {
+		line := "0 0 " + read + " 0 0 0 " + written + " 0 0 0 0\n"
+		require.NoError(t, os.WriteFile(filepath.Join(sysBlock, "sda", "stat"), []byte(line), 0644))
+	}
+
+	ff := NewFeedForward(FeedForwardConfig{Enabled: true, CPUGain: float64Ptr(0), IOGain: float64Ptr(1), MaxBias: 100})
+	ff.procStatPath = procStat
+	ff.sysBlockPath = sysBlock
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	writeSectors("0", "0")
+	_, err := ff.Sample(start, []string{"sda"})
+	require.NoError(t, err)
+
+	// Act - 40 MiB read and written over one 20s poll
+	writeSectors("40960", "40960")
+	bias, err := ff.Sample(start.Add(20*time.Second), []string{"sda"})
+
+	// Assert
+	require.NoError(t, err)
+	assert.InDelta(t, 2.0, bias, 0.01) // 2 MB/s * 1
+}
+
+// TestFeedForward_Sample_DiskJoins tests that a disk joining the set adds no burst of lifetime I/O
+func TestFeedForward_Sample_DiskJoins(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	procStat := filepath.Join(dir, "stat")
+	sysBlock := filepath.Join(dir, "block")
+	require.NoError(t, os.WriteFile(procStat, []byte("cpu  0 0 0 1000 0 0 0 0\n"), 0644))
+	writeSectors := func(disk string, sectors string) {
+		require.NoError(t, os.MkdirAll(filepath.Join(sysBlock, disk), 0755))
+		line := "0 0 " + sectors + " 0 0 0 0 0 0 0 0\n"
+		require.NoError(t, os.WriteFile(filepath.Join(sysBlock, disk, "stat"), []byte(line), 0644))
+	}
+
+	ff := NewFeedForward(FeedForwardConfig{Enabled: true, CPUGain: float64Ptr(0), IOGain: float64Ptr(1), MaxBias: 100})
+	ff.procStatPath = procStat
+	ff.sysBlockPath = sysBlock
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	writeSectors("sda", "1000")
+	_, err := ff.Sample(start, []string{"sda"})
+	require.NoError(t, err)
+
+	// Act - sdb arrives with a lifetime of ~500 GB transferred, sda is idle
+	writeSectors("sdb", "1000000000")
+	bias, err := ff.Sample(start.Add(10*time.Second), []string{"sda", "sdb"})
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, 0.0, bias, "A new disk is primed, not counted as one poll of I/O")
+}
+
+// TestFeedForward_Sample_MissingDisk tests error when a disk stat is missing
+func TestFeedForward_Sample_MissingDisk(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	procStat := filepath.Join(dir, "stat")
+	require.NoError(t, os.WriteFile(procStat, []byte("cpu  1 0 0 1 0 0 0 0\n"), 0644))
+
+	ff := NewFeedForward(FeedForwardConfig{Enabled: true})
+	ff.procStatPath = procStat
+	ff.sysBlockPath = filepath.Join(dir, "block")
+
+	// Act
+	_, err := ff.Sample(time.Now(), []string{"sda"})
+
+	// Assert
+	require.Error(t, err)
+}
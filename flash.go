@@ -0,0 +1,26 @@
+package main
+
+// Policies for temperature inputs outside the HDD aggregate (disks.flash.mode, enclosures.mode)
+const (
+	SensorModeEmergency = "emergency" // The input only forces 100% above its max_temp
+	SensorModeControl   = "control"   // The hottest sensor also drives the PID, relative to its target_temp
+)
+
+// flashControlInput returns the hottest flash drive shifted onto target_hdd, so a
+// drive at flash.target_temp reads as exactly target_hdd. ok is false when flash
+// drives do not drive the PID or none were read
+func flashControlInput(config *Config, flash map[string]int) (float64, bool) {
+	if !config.Disks.Flash.Enabled {
+		return 0, false
+	}
+	return sensorControlInput(config.Disks.Flash.Mode, config.Disks.Flash.TargetTemp, config.Temperature.TargetHDD, flash)
+}
+
+// sensorControlInput shifts the hottest of temps from its own target onto targetHDD
+// in control mode; ok is false in emergency mode or without readings
+func sensorControlInput(mode string, target, targetHDD float64, temps map[string]int) (float64, bool) {
+	if mode != SensorModeControl || len(temps) == 0 {
+		return 0, false
+	}
+	return float64(GetMaxTemperature(temps)) - target + targetHDD, true
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFlashControlInput tests shifting the hottest flash drive onto target_hdd
+func TestFlashControlInput(t *testing.T) {
+	tests := []struct {
+		name       string
+		flash      FlashConfig
+		temps      map[string]int
+		expected   float64
+		expectedOK bool
+	}{
+		{"at target", FlashConfig{Enabled: true, Mode: SensorModeControl, TargetTemp: 50}, map[string]int{"nvme0n1": 50}, 38, true},
+		{"hottest drive wins", FlashConfig{Enabled: true, Mode: SensorModeControl, TargetTemp: 50}, map[string]int{"nvme0n1": 48, "sdx": 54}, 42, true},
+		{"emergency mode", FlashConfig{Enabled: true, Mode: SensorModeEmergency, TargetTemp: 50}, map[string]int{"nvme0n1": 54}, 0, false},
+		{"disabled", FlashConfig{Mode: SensorModeControl, TargetTemp: 50}, map[string]int{"nvme0n1": 54}, 0, false},
+		{"no readings", FlashConfig{Enabled: true, Mode: SensorModeControl, TargetTemp: 50}, map[string]int{}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := &Config{Temperature: TemperatureConfig{TargetHDD: 38}, Disks: DiskConfig{Flash: tt.flash}}
+
+			// Act
+			input, ok := flashControlInput(config, tt.temps)
+
+			// Assert
+			assert.Equal(t, tt.expectedOK, ok)
+			assert.InDelta(t, tt.expected, input, 0.001)
+		})
+	}
+}
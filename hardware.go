@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// TempReadings is one poll of the temperature sensors
+type TempReadings struct {
+	Disks       map[string]int          // Disk temperatures by device
+	Flash       map[string]int          // SSD/NVMe temperatures by device, kept out of the HDD aggregate
+	FailedDisks []string                // Discovered disks and flash drives whose temperature could not be read
+	Identities  map[string]DiskIdentity // Serial, model and WWN by device, where resolved
+	Enclosure   EnclosureReadings       // SES enclosure temperatures and fan speeds by sensor name
+	CPU         float64                 // CPU temperature (°C)
+}
+
+// Identity returns the identity of device; unidentified disks use the kernel name as serial
+func (r TempReadings) Identity(device string) DiskIdentity {
+	if identity, ok := r.Identities[device]; ok {
+		return identity
+	}
+	return DiskIdentity{Device: device, Serial: device}
+}
+
+// TempSource provides disk and CPU temperatures for one control loop iteration
+type TempSource interface {
+	// ReadTemperatures returns the readings; on error the disk fields still hold
+	// whatever was read so per-disk metrics stay accurate
+	ReadTemperatures(ctx context.Context) (TempReadings, error)
+}
+
+// FanActuator sets the fan duty cycle and reads back fan speeds
+type FanActuator interface {
+	SetAllFans(ctx context.Context, dutyPercent int) error
+	GetFanSpeeds(ctx context.Context) (map[string]int, error)
+	// SetAutoMode returns fan control to the BMC's automatic curve
+	SetAutoMode(ctx context.Context) error
+}
+
+// Clock provides the current time and sleeping so the loop can run on simulated time
+type Clock interface {
+	Now() time.Time
+	// Sleep waits for d or until ctx is cancelled, returning ctx.Err() if cancelled
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// hostTempSource reads temperatures from smartctl and the k10temp hwmon sensor
+type hostTempSource struct {
+	config *Config
+}
+
+// ReadTemperatures reads all disk and CPU temperatures on the host
+func (s *hostTempSource) ReadTemperatures(ctx context.Context) (TempReadings, error) {
+	return readAllTemperatures(ctx, s.config)
+}
+
+// ipmiFanActuator controls the fans through ipmitool
+type ipmiFanActuator struct {
+	bmc string // BMC type used for SetAutoMode
+}
+
+// SetAllFans sets all fan headers to the given duty cycle
+func (ipmiFanActuator) SetAllFans(ctx context.Context, dutyPercent int) error {
+	return SetAllFans(ctx, dutyPercent)
+}
+
+// GetFanSpeeds reads fan RPMs from the IPMI sensors
+func (ipmiFanActuator) GetFanSpeeds(ctx context.Context) (map[string]int, error) {
+	return GetFanSpeeds(ctx)
+}
+
+// SetAutoMode sends the board-specific command for BMC automatic fan control
+func (a ipmiFanActuator) SetAutoMode(ctx context.Context) error {
+	return SetBMCAutoMode(ctx, a.bmc)
+}
+
+// dryRunFanActuator reads fan speeds but never changes the duty
+type dryRunFanActuator struct {
+	FanActuator
+}
+
+// SetAllFans does nothing in dry-run mode
+func (dryRunFanActuator) SetAllFans(ctx context.Context, dutyPercent int) error {
+	return nil
+}
+
+// SetAutoMode does nothing in dry-run mode
+func (dryRunFanActuator) SetAutoMode(ctx context.Context) error {
+	return nil
+}
+
+// systemClock is the wall clock
+type systemClock struct{}
+
+// Now returns the current wall clock time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sleep pauses the calling goroutine for d, returning early if ctx is cancelled
+func (systemClock) Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
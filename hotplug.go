@@ -0,0 +1,136 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Policies for a previously seen disk that disappears (disks.hotplug.on_removal)
+const (
+	OnRemovalLog  = "log"  // Log and count the removal only
+	OnRemovalWarn = "warn" // Also hold fans at warning_duty while the disk is missing
+)
+
+// Disk change events
+const (
+	DiskEventAdded   = "added"
+	DiskEventRemoved = "removed"
+)
+
+// DiskEvent is a disk that appeared or disappeared between two polls
+type DiskEvent struct {
+	Type     string // DiskEventAdded or DiskEventRemoved
+	Identity DiskIdentity
+	Flash    bool
+}
+
+// trackedDisk is a disk present in the last poll
+type trackedDisk struct {
+	identity DiskIdentity
+	flash    bool
+}
+
+// DiskTracker follows the set of disks between polls by serial number, so a
+// kernel rename is not reported as a removal followed by an arrival
+type DiskTracker struct {
+	present     map[string]trackedDisk // By serial
+	missing     map[string]time.Time   // Removed disks by serial, with the removal time
+	initialized bool
+}
+
+// NewDiskTracker creates a tracker; the first poll sets the baseline without events
+func NewDiskTracker() *DiskTracker {
+	return &DiskTracker{
+		present: make(map[string]trackedDisk),
+		missing: make(map[string]time.Time),
+	}
+}
+
+// Update compares the disks in readings with the previous poll and returns the
+// added and removed disks. A disk whose read failed is still present, and keeps
+// the class it was last read as since FailedDisks mixes HDDs and flash
+func (t *DiskTracker) Update(readings TempReadings, now time.Time) []DiskEvent {
+	current := make(map[string]trackedDisk, len(readings.Disks)+len(readings.Flash)+len(readings.FailedDisks))
+	for _, device := range diskNames(readings.Disks) {
+		identity := readings.Identity(device)
+		current[identity.Serial] = trackedDisk{identity: identity}
+	}
+	for _, device := range diskNames(readings.Flash) {
+		identity := readings.Identity(device)
+		current[identity.Serial] = trackedDisk{identity: identity, flash: true}
+	}
+	for _, device := range readings.FailedDisks {
+		identity := readings.Identity(device)
+		if _, ok := current[identity.Serial]; ok {
+			continue
+		}
+		disk, known := t.present[identity.Serial]
+		if !known {
+			disk = trackedDisk{} // Never read yet, so its class is unknown; count it as an HDD
+		}
+		disk.identity = identity
+		current[identity.Serial] = disk
+	}
+
+	var events []DiskEvent
+	if t.initialized {
+		for serial, disk := range current {
+			if _, ok := t.present[serial]; !ok {
+				events = append(events, DiskEvent{Type: DiskEventAdded, Identity: disk.identity, Flash: disk.flash})
+				delete(t.missing, serial)
+			}
+		}
+		for serial, disk := range t.present {
+			if _, ok := current[serial]; !ok {
+				events = append(events, DiskEvent{Type: DiskEventRemoved, Identity: disk.identity, Flash: disk.flash})
+				t.missing[serial] = now
+			}
+		}
+	}
+
+	t.present = current
+	t.initialized = true
+	return events
+}
+
+// Counts returns the number of spinning and flash disks present in the last poll
+func (t *DiskTracker) Counts() (hdds, flash int) {
+	for _, disk := range t.present {
+		if disk.flash {
+			flash++
+		} else {
+			hdds++
+		}
+	}
+	return hdds, flash
+}
+
+// Missing returns how many removed disks have not come back. Disks missing for
+// longer than hold are forgotten; a zero hold keeps them until they return
+func (t *DiskTracker) Missing(now time.Time, hold time.Duration) int {
+	if hold > 0 {
+		for serial, removed := range t.missing {
+			if now.Sub(removed) >= hold {
+				slog.Info("Forgetting removed disk", "serial", serial, "missing_for", now.Sub(removed))
+				delete(t.missing, serial)
+			}
+		}
+	}
+	return len(t.missing)
+}
+
+// logDiskEvent logs a disk arrival at info level and a removal at warn, since a
+// disk that vanishes mid-resilver is often a failed drive that is no longer cooled
+func logDiskEvent(event DiskEvent) {
+	args := []any{
+		"serial", event.Identity.Serial,
+		"device", event.Identity.Device,
+		"model", event.Identity.Model,
+		"flash", event.Flash,
+	}
+	if event.Type == DiskEventRemoved {
+		slog.Warn("Disk removed", args...)
+		return
+	}
+	slog.Info("Disk added", args...)
+}
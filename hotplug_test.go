@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDiskTracker_Update tests the events reported between polls
+func TestDiskTracker_Update(t *testing.T) {
+	// Arrange
+	tracker := NewDiskTracker()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	identities := map[string]DiskIdentity{
+		"sda": {Device: "sda", Serial: "VAG1"},
+		"sdb": {Device: "sdb", Serial: "VAG2"},
+		"sdc": {Device: "sdc", Serial: "VAG3"},
+	}
+
+	// Act
+	baseline := tracker.Update(TempReadings{Disks: map[string]int{"sda": 38, "sdb": 39}, Identities: identities}, now)
+	failed := tracker.Update(TempReadings{Disks: map[string]int{"sda": 38}, FailedDisks: []string{"sdb"}, Identities: identities}, now)
+	swapped := tracker.Update(TempReadings{Disks: map[string]int{"sda": 38, "sdc": 30}, Identities: identities}, now)
+
+	// Assert
+	assert.Empty(t, baseline, "the first poll sets the baseline")
+	assert.Empty(t, failed, "a disk whose read failed is still present")
+	require.Len(t, swapped, 2)
+	events := map[string]string{}
+	for _, event := range swapped {
+		events[event.Identity.Serial] = event.Type
+	}
+	assert.Equal(t, map[string]string{"VAG2": DiskEventRemoved, "VAG3": DiskEventAdded}, events)
+}
+
+// TestDiskTracker_FailedFlash tests that a flash drive whose read fails is still counted as flash
+func TestDiskTracker_FailedFlash(t *testing.T) {
+	// Arrange
+	tracker := NewDiskTracker()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker.Update(TempReadings{Disks: map[string]int{"sda": 38}, Flash: map[string]int{"nvme0n1": 45}}, now)
+
+	// Act
+	events := tracker.Update(TempReadings{Disks: map[string]int{"sda": 38}, FailedDisks: []string{"nvme0n1"}}, now)
+	hdds, flash := tracker.Counts()
+
+	// Assert
+	assert.Empty(t, events)
+	assert.Equal(t, 1, hdds)
+	assert.Equal(t, 1, flash)
+}
+
+// TestDiskTracker_Rename tests that a kernel rename of the same serial is not an event
+func TestDiskTracker_Rename(t *testing.T) {
+	// Arrange
+	tracker := NewDiskTracker()
+	tracker.Update(TempReadings{Disks: map[string]int{"sda": 38}, Identities: map[string]DiskIdentity{"sda": {Device: "sda", Serial: "VAG1"}}}, time.Time{})
+
+	// Act
+	events := tracker.Update(TempReadings{Disks: map[string]int{"sdd": 38}, Identities: map[string]DiskIdentity{"sdd": {Device: "sdd", Serial: "VAG1"}}}, time.Time{})
+
+	// Assert
+	assert.Empty(t, events)
+}
+
+// TestDiskTracker_Missing tests that removed disks count as missing until they return or the hold expires
+func TestDiskTracker_Missing(t *testing.T) {
+	// Arrange
+	silenceLogs(t)
+	tracker := NewDiskTracker()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker.Update(TempReadings{Disks: map[string]int{"sda": 38, "sdb": 39}, Flash: map[string]int{"nvme0n1": 45}}, now)
+	tracker.Update(TempReadings{Disks: map[string]int{"sda": 38}, Flash: map[string]int{"nvme0n1": 45}}, now)
+
+	// Act & Assert
+	hdds, flash := tracker.Counts()
+	assert.Equal(t, 1, hdds)
+	assert.Equal(t, 1, flash)
+	assert.Equal(t, 1, tracker.Missing(now.Add(2*time.Hour), 0), "a zero hold never forgets")
+	assert.Equal(t, 1, tracker.Missing(now.Add(30*time.Minute), time.Hour))
+	assert.Equal(t, 0, tracker.Missing(now.Add(time.Hour), time.Hour))
+
+	tracker.Update(TempReadings{Disks: map[string]int{"sda": 38}}, now)
+	tracker.Update(TempReadings{Disks: map[string]int{"sda": 38, "nvme0n1": 44}}, now)
+	assert.Equal(t, 0, tracker.Missing(now, 0), "a returning disk is no longer missing")
+}
@@ -2,8 +2,9 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os/exec"
 	"regexp"
 	"strconv"
@@ -18,9 +19,16 @@ const (
 	numPadding = 10
 )
 
+// BMC types for returning fan control to the board's automatic curve
+const (
+	BMCASRock     = "asrock"
+	BMCSupermicro = "supermicro"
+)
+
 // SetAllFans sets the duty cycle for all fans using the confirmed 0xd6 format
 // ASRock X570D4U-2L2T uses format: ipmitool raw 0x3a 0xd6 [6 fan values] [10 padding bytes]
-func SetAllFans(dutyPercent int) error {
+// The context cancels an in-flight ipmitool call and the retry delay
+func SetAllFans(ctx context.Context, dutyPercent int) error {
 	if dutyPercent < 0 || dutyPercent > 100 {
 		return fmt.Errorf("duty cycle must be between 0-100, got %d", dutyPercent)
 	}
@@ -41,22 +49,61 @@ func SetAllFans(dutyPercent int) error {
 		args = append(args, "0x64")
 	}
 
-	// Execute with retries (3 attempts, 2 second delays)
+	return runIPMIRaw(ctx, args)
+}
+
+// SetBMCAutoMode hands fan control back to the BMC's own fan curve
+// ASRock clears manual mode on every header (0x3a 0xd8, 0 = auto); Supermicro selects Standard mode
+func SetBMCAutoMode(ctx context.Context, bmc string) error {
+	args, err := bmcAutoModeArgs(bmc)
+	if err != nil {
+		return err
+	}
+	return runIPMIRaw(ctx, args)
+}
+
+// bmcAutoModeArgs returns the ipmitool arguments that restore automatic fan control
+func bmcAutoModeArgs(bmc string) ([]string, error) {
+	switch bmc {
+	case BMCASRock, "":
+		// Same layout as 0xd6: 6 fan values plus 10 padding bytes, all 0x00 (auto)
+		args := []string{"raw", "0x3a", "0xd8"}
+		for i := 0; i < numFans+numPadding; i++ {
+			args = append(args, "0x00")
+		}
+		return args, nil
+	case BMCSupermicro:
+		return []string{"raw", "0x30", "0x45", "0x01", "0x00"}, nil
+	default:
+		return nil, fmt.Errorf("unknown BMC type %q", bmc)
+	}
+}
+
+// runIPMIRaw executes an ipmitool command with retries (3 attempts, 2 second delays)
+// The context cancels an in-flight ipmitool call and the retry delay
+func runIPMIRaw(ctx context.Context, args []string) error {
 	var lastErr error
 	for attempt := 1; attempt <= 3; attempt++ {
-		cmd := exec.Command("ipmitool", args...)
+		cmd := exec.CommandContext(ctx, "ipmitool", args...)
 		output, err := cmd.CombinedOutput()
 		
 		if err == nil {
 			// Success - no need to retry
 			return nil
 		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("IPMI command cancelled: %w", ctx.Err())
+		}
 		
 		lastErr = fmt.Errorf("attempt %d failed: %v, output: %s", attempt, err, string(output))
 		
 		if attempt < 3 {
-			log.Printf("IPMI command failed, retrying in 2s: %v", lastErr)
-			time.Sleep(2 * time.Second)
+			slog.Warn("IPMI command failed, retrying in 2s", "error", lastErr)
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("IPMI command cancelled: %w", ctx.Err())
+			case <-time.After(2 * time.Second):
+			}
 		}
 	}
 	
@@ -65,8 +112,8 @@ func SetAllFans(dutyPercent int) error {
 
 // GetFanSpeeds reads current fan speeds from IPMI sensors
 // Returns a map of fan name -> RPM, or error if reading fails
-func GetFanSpeeds() (map[string]int, error) {
-	cmd := exec.Command("ipmitool", "sensor")
+func GetFanSpeeds(ctx context.Context) (map[string]int, error) {
+	cmd := exec.CommandContext(ctx, "ipmitool", "sensor")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return nil, fmt.Errorf("failed to read IPMI sensors: %w", err)
@@ -94,7 +141,7 @@ func GetFanSpeeds() (map[string]int, error) {
 			// Parse RPM value
 			rpm, err := strconv.ParseFloat(rpmStr, 64)
 			if err != nil {
-				log.Printf("Warning: failed to parse RPM for %s: %v", fanName, err)
+				slog.Warn("Failed to parse fan RPM", "fan", fanName, "error", err)
 				continue
 			}
 			
@@ -115,8 +162,8 @@ func GetFanSpeeds() (map[string]int, error) {
 
 // GetFanSpeedsForLogging returns fan speeds formatted for logging
 // Returns a string like "FAN1:1600 FAN2:1700 FAN3:2900" for easy reading
-func GetFanSpeedsForLogging() string {
-	speeds, err := GetFanSpeeds()
+func GetFanSpeedsForLogging(ctx context.Context) string {
+	speeds, err := GetFanSpeeds(ctx)
 	if err != nil {
 		return fmt.Sprintf("Error reading fan speeds: %v", err)
 	}
@@ -131,34 +178,34 @@ func GetFanSpeedsForLogging() string {
 
 // TestIPMICommand tests the IPMI command format and returns results
 // This is used by the --test-ipmi CLI flag to verify IPMI functionality
-func TestIPMICommand() error {
-	log.Println("Testing IPMI command format 0xd6...")
+func TestIPMICommand(ctx context.Context) error {
+	slog.Info("Testing IPMI command format 0xd6...")
 	
 	// Get baseline fan speeds
-	log.Println("Getting baseline fan speeds...")
-	baseline, err := GetFanSpeeds()
+	slog.Info("Getting baseline fan speeds...")
+	baseline, err := GetFanSpeeds(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get baseline fan speeds: %w", err)
 	}
-	log.Printf("Baseline speeds: %s", GetFanSpeedsForLogging())
+	slog.Info("Baseline speeds", "fans", GetFanSpeedsForLogging(ctx))
 	
 	// Test setting to 50% duty cycle
-	log.Println("Setting fans to 50% duty cycle...")
-	if err := SetAllFans(50); err != nil {
+	slog.Info("Setting fans to 50% duty cycle...")
+	if err := SetAllFans(ctx, 50); err != nil {
 		return fmt.Errorf("failed to set fans to 50%%: %w", err)
 	}
 	
 	// Wait for fans to adjust
-	log.Println("Waiting 10 seconds for fans to adjust...")
+	slog.Info("Waiting 10 seconds for fans to adjust...")
 	time.Sleep(10 * time.Second)
 	
 	// Check fan speeds after adjustment
-	log.Println("Checking fan speeds after adjustment...")
-	adjusted, err := GetFanSpeeds()
+	slog.Info("Checking fan speeds after adjustment...")
+	adjusted, err := GetFanSpeeds(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get adjusted fan speeds: %w", err)
 	}
-	log.Printf("Adjusted speeds: %s", GetFanSpeedsForLogging())
+	slog.Info("Adjusted speeds", "fans", GetFanSpeedsForLogging(ctx))
 	
 	// Verify speeds changed (should be roughly 50% of baseline)
 	changesDetected := 0
@@ -170,12 +217,10 @@ func TestIPMICommand() error {
 			
 			if newRPM >= expectedMin && newRPM <= expectedMax {
 				changesDetected++
-				log.Printf("✓ %s: %d -> %d RPM (%.1f%% change)", 
-					fan, baselineRPM, newRPM, 
-					float64(newRPM)/float64(baselineRPM)*100)
+				slog.Info("✓ Fan responded", "fan", fan, "baseline_rpm", baselineRPM, "rpm", newRPM,
+					"percent", float64(newRPM)/float64(baselineRPM)*100)
 			} else {
-				log.Printf("⚠ %s: %d -> %d RPM (unexpected change)", 
-					fan, baselineRPM, newRPM)
+				slog.Warn("⚠ Unexpected fan speed change", "fan", fan, "baseline_rpm", baselineRPM, "rpm", newRPM)
 			}
 		}
 	}
@@ -184,24 +229,24 @@ func TestIPMICommand() error {
 		return fmt.Errorf("no fan speed changes detected - IPMI command may not be working")
 	}
 	
-	log.Printf("✓ IPMI test successful: %d fans responded to command", changesDetected)
+	slog.Info("✓ IPMI test successful", "fans_responded", changesDetected)
 	
 	// Reset to 100% for safety
-	log.Println("Resetting fans to 100% duty cycle...")
-	if err := SetAllFans(100); err != nil {
+	slog.Info("Resetting fans to 100% duty cycle...")
+	if err := SetAllFans(ctx, 100); err != nil {
 		return fmt.Errorf("failed to reset fans to 100%%: %w", err)
 	}
 	
 	// Wait and verify reset
-	log.Println("Waiting 10 seconds for fans to return to 100%...")
+	slog.Info("Waiting 10 seconds for fans to return to 100%...")
 	time.Sleep(10 * time.Second)
 	
-	_, err = GetFanSpeeds()
+	_, err = GetFanSpeeds(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get final fan speeds: %w", err)
 	}
-	log.Printf("Final speeds: %s", GetFanSpeedsForLogging())
+	slog.Info("Final speeds", "fans", GetFanSpeedsForLogging(ctx))
 	
-	log.Println("✓ IPMI test completed successfully")
+	slog.Info("✓ IPMI test completed successfully")
 	return nil
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBMCAutoModeArgs tests the board-specific automatic fan mode commands
+func TestBMCAutoModeArgs(t *testing.T) {
+	tests := []struct {
+		bmc            string
+		prefix         []string
+		length         int
+		expectErrorMsg string
+	}{
+		{bmc: BMCASRock, prefix: []string{"raw", "0x3a", "0xd8", "0x00"}, length: 3 + numFans + numPadding},
+		{bmc: BMCSupermicro, prefix: []string{"raw", "0x30", "0x45", "0x01", "0x00"}, length: 5},
+		{bmc: "dell", expectErrorMsg: "unknown BMC type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.bmc, func(t *testing.T) {
+			// Act
+			args, err := bmcAutoModeArgs(tt.bmc)
+
+			// Assert
+			if tt.expectErrorMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectErrorMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, args, tt.length)
+			assert.Equal(t, tt.prefix, args[:len(tt.prefix)])
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default journald native protocol socket
+const journalSocket = "/run/systemd/journal/socket"
+
+// Journal priorities (syslog levels)
+const (
+	journalPriorityErr     = 3
+	journalPriorityWarning = 4
+	journalPriorityInfo    = 6
+	journalPriorityDebug   = 7
+)
+
+// JournalWriter sends log entries to journald using the native protocol, so
+// fields such as FAN_DUTY can be queried with journalctl
+type JournalWriter struct {
+	mu         sync.Mutex
+	conn       *net.UnixConn
+	identifier string
+}
+
+// NewJournalWriter connects to the journald socket at path
+func NewJournalWriter(path, identifier string) (*JournalWriter, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to journald: %w", err)
+	}
+	return &JournalWriter{conn: conn, identifier: identifier}, nil
+}
+
+// Send writes one journal entry with MESSAGE, PRIORITY and the extra fields
+// Field names must be upper case letters, digits and underscores
+func (j *JournalWriter) Send(priority int, message string, fields map[string]string) error {
+	var buf bytes.Buffer
+	writeJournalField(&buf, "MESSAGE", message)
+	writeJournalField(&buf, "PRIORITY", strconv.Itoa(priority))
+	writeJournalField(&buf, "SYSLOG_IDENTIFIER", j.identifier)
+	for name, value := range fields {
+		writeJournalField(&buf, name, value)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.conn.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write to journald: %w", err)
+	}
+	return nil
+}
+
+// Close closes the journald socket
+func (j *JournalWriter) Close() error {
+	return j.conn.Close()
+}
+
+// writeJournalField encodes a field; values containing newlines use the
+// length-prefixed binary form
+func writeJournalField(buf *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(buf, "%s=%s\n", name, value)
+		return
+	}
+	buf.WriteString(name)
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journalHandler is a slog.Handler that sends each record to journald, with
+// attributes as upper-case journal fields (duty -> DUTY, fans.bmc -> FANS_BMC)
+type journalHandler struct {
+	writer *JournalWriter
+	level  slog.Leveler
+	attrs  []slog.Attr
+	group  string
+}
+
+// newJournalHandler creates a handler for records at or above level
+func newJournalHandler(writer *JournalWriter, level slog.Leveler) *journalHandler {
+	return &journalHandler{writer: writer, level: level}
+}
+
+// Enabled reports whether the level is logged
+func (h *journalHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle sends the record as one journal entry
+func (h *journalHandler) Handle(_ context.Context, record slog.Record) error {
+	fields := make(map[string]string, len(h.attrs)+record.NumAttrs())
+	for _, attr := range h.attrs {
+		addJournalFields(fields, "", attr)
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		addJournalFields(fields, h.group, attr)
+		return true
+	})
+	return h.writer.Send(journalPriority(record.Level), record.Message, fields)
+}
+
+// WithAttrs returns a handler that adds attrs to every record
+func (h *journalHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append([]slog.Attr{}, h.attrs...)
+	for _, attr := range attrs {
+		if h.group != "" {
+			attr = slog.Group(h.group, attr)
+		}
+		clone.attrs = append(clone.attrs, attr)
+	}
+	return &clone
+}
+
+// WithGroup returns a handler that prefixes later attribute names with name
+func (h *journalHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.group = journalFieldName(h.group, name)
+	return &clone
+}
+
+// journalPriority maps slog levels to syslog priorities
+func journalPriority(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return journalPriorityErr
+	case level >= slog.LevelWarn:
+		return journalPriorityWarning
+	case level >= slog.LevelInfo:
+		return journalPriorityInfo
+	default:
+		return journalPriorityDebug
+	}
+}
+
+// addJournalFields flattens attr (and nested groups) into fields
+func addJournalFields(fields map[string]string, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	name := journalFieldName(prefix, attr.Key)
+	if attr.Value.Kind() == slog.KindGroup {
+		for _, member := range attr.Value.Group() {
+			addJournalFields(fields, name, member)
+		}
+		return
+	}
+	fields[name] = attr.Value.String()
+}
+
+// journalFieldName joins prefix and key into a valid journal field name
+func journalFieldName(prefix, key string) string {
+	if prefix != "" {
+		key = prefix + "_" + key
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseJournalEntry decodes a native protocol datagram into fields
+func parseJournalEntry(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+
+	fields := make(map[string]string)
+	for len(data) > 0 {
+		line := bytes.IndexByte(data, '\n')
+		require.GreaterOrEqual(t, line, 0)
+		if eq := bytes.IndexByte(data[:line], '='); eq >= 0 {
+			fields[string(data[:eq])] = string(data[eq+1 : line])
+			data = data[line+1:]
+			continue
+		}
+
+		// Binary form: NAME\n<uint64 length><value>\n
+		name := string(data[:line])
+		size := binary.LittleEndian.Uint64(data[line+1 : line+9])
+		fields[name] = string(data[line+9 : line+9+int(size)])
+		data = data[line+9+int(size)+1:]
+	}
+	return fields
+}
+
+// TestJournalWriter_Send tests plain and multi-line fields
+func TestJournalWriter_Send(t *testing.T) {
+	// Arrange
+	conn, path := listenUnixgram(t, "journal")
+	writer, err := NewJournalWriter(path, "fan-controller")
+	require.NoError(t, err)
+	defer writer.Close()
+
+	// Act
+	require.NoError(t, writer.Send(journalPriorityWarning, "line one\nline two", map[string]string{"FAN_DUTY": "40"}))
+
+	// Assert
+	messages := readDatagrams(t, conn)
+	require.Len(t, messages, 1)
+	fields := parseJournalEntry(t, []byte(messages[0]))
+	assert.Equal(t, "line one\nline two", fields["MESSAGE"])
+	assert.Equal(t, "4", fields["PRIORITY"])
+	assert.Equal(t, "fan-controller", fields["SYSLOG_IDENTIFIER"])
+	assert.Equal(t, "40", fields["FAN_DUTY"])
+}
+
+// TestJournalHandler tests slog records become journal entries with upper-case fields
+func TestJournalHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		log      func(logger *slog.Logger)
+		priority string
+		fields   map[string]string
+	}{
+		{
+			name:     "info with attrs",
+			log:      func(logger *slog.Logger) { logger.Info("Set initial fan speed", "duty", 50) },
+			priority: "6",
+			fields:   map[string]string{"DUTY": "50"},
+		},
+		{
+			name:     "warn",
+			log:      func(logger *slog.Logger) { logger.Warn("Failed to read fan speeds", "error", errors.New("timeout")) },
+			priority: "4",
+			fields:   map[string]string{"ERROR": "timeout"},
+		},
+		{
+			name: "error with logger attrs and group",
+			log: func(logger *slog.Logger) {
+				logger.With("bmc", "asrock").WithGroup("fan").Error("Fan stalled", "name", "FAN1")
+			},
+			priority: "3",
+			fields:   map[string]string{"BMC": "asrock", "FAN_NAME": "FAN1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			conn, path := listenUnixgram(t, "journal")
+			writer, err := NewJournalWriter(path, "fan-controller")
+			require.NoError(t, err)
+			defer writer.Close()
+			logger := slog.New(newJournalHandler(writer, slog.LevelInfo))
+
+			// Act
+			tt.log(logger)
+
+			// Assert
+			messages := readDatagrams(t, conn)
+			require.Len(t, messages, 1)
+			fields := parseJournalEntry(t, []byte(messages[0]))
+			assert.Equal(t, tt.priority, fields["PRIORITY"])
+			for name, value := range tt.fields {
+				assert.Equal(t, value, fields[name], name)
+			}
+		})
+	}
+}
+
+// TestJournalHandler_Level tests records below the level are dropped
+func TestJournalHandler_Level(t *testing.T) {
+	// Arrange
+	conn, path := listenUnixgram(t, "journal")
+	writer, err := NewJournalWriter(path, "fan-controller")
+	require.NoError(t, err)
+	defer writer.Close()
+	logger := slog.New(newJournalHandler(writer, slog.LevelInfo))
+
+	// Act
+	logger.Debug("Control loop status", "duty", 40)
+
+	// Assert
+	assert.Empty(t, readDatagrams(t, conn))
+}
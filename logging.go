@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Log output formats
+const (
+	LogFormatText = "text" // logfmt-style key=value lines
+	LogFormatJSON = "json" // One JSON object per line
+)
+
+// parseLogLevel converts the log_level config value to a slog level
+func parseLogLevel(level string) (slog.Level, error) {
+	switch level {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q", level)
+	}
+}
+
+// newLogHandler creates the text or JSON handler writing to w at the configured level
+func newLogHandler(w io.Writer, server ServerConfig) (slog.Handler, error) {
+	level, err := parseLogLevel(server.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	options := &slog.HandlerOptions{Level: level}
+
+	switch server.LogFormat {
+	case LogFormatText, "":
+		return slog.NewTextHandler(w, options), nil
+	case LogFormatJSON:
+		return slog.NewJSONHandler(w, options), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", server.LogFormat)
+	}
+}
+
+// setupLogging installs the default slog logger from the server config. With
+// journald enabled, logs go to the journal and fall back to stderr if it is unreachable
+func setupLogging(server ServerConfig) error {
+	handler, err := newLogHandler(os.Stderr, server)
+	if err != nil {
+		return err
+	}
+
+	if server.Journald {
+		writer, err := NewJournalWriter(journalSocket, "fan-controller")
+		if err != nil {
+			slog.SetDefault(slog.New(handler))
+			slog.Warn("journald logging disabled", "error", err)
+			return nil
+		}
+		level, _ := parseLogLevel(server.LogLevel)
+		handler = newJournalHandler(writer, level)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// fatal logs msg at error level and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
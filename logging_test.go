@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLogs routes the default logger to a buffer for the duration of the test
+func captureLogs(t *testing.T, server ServerConfig) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	handler, err := newLogHandler(&buf, server)
+	require.NoError(t, err)
+
+	previous := slog.Default()
+	slog.SetDefault(slog.New(handler))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// decodeJSONLines parses one JSON object per log line
+func decodeJSONLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+// TestParseLogLevel tests the log_level values
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		input          string
+		expected       slog.Level
+		expectErrorMsg string
+	}{
+		{input: "debug", expected: slog.LevelDebug},
+		{input: "info", expected: slog.LevelInfo},
+		{input: "", expected: slog.LevelInfo},
+		{input: "warn", expected: slog.LevelWarn},
+		{input: "error", expected: slog.LevelError},
+		{input: "trace", expectErrorMsg: "unknown log level"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			// Act
+			level, err := parseLogLevel(tt.input)
+
+			// Assert
+			if tt.expectErrorMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectErrorMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, level)
+		})
+	}
+}
+
+// TestNewLogHandler_Formats tests text and JSON output
+func TestNewLogHandler_Formats(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{LogFormatText, `level=INFO msg="Set initial fan speed" duty=50`},
+		{LogFormatJSON, `"level":"INFO","msg":"Set initial fan speed","duty":50}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			// Arrange
+			buf := captureLogs(t, ServerConfig{LogLevel: "info", LogFormat: tt.format})
+
+			// Act
+			slog.Info("Set initial fan speed", "duty", 50)
+
+			// Assert
+			assert.Contains(t, buf.String(), tt.expected)
+		})
+	}
+}
+
+// TestNewLogHandler_InvalidFormat tests the error for unknown formats
+func TestNewLogHandler_InvalidFormat(t *testing.T) {
+	// Act
+	_, err := newLogHandler(&bytes.Buffer{}, ServerConfig{LogFormat: "xml"})
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown log format")
+}
+
+// TestLogMetricsSummary_Levels tests the loop summary respects log_level
+func TestLogMetricsSummary_Levels(t *testing.T) {
+	normal := MetricsSummary{CPUTemp: 50, MaxDiskTemp: 39, AvgDiskTemp: 38.5, FanDuty: 40}
+	emergency := MetricsSummary{CPUTemp: 50, MaxDiskTemp: 46, AvgDiskTemp: 44, FanDuty: 100, Emergency: "hdd_temp"}
+
+	tests := []struct {
+		name     string
+		level    string
+		summary  MetricsSummary
+		expected int
+	}{
+		{"status hidden at info", "info", normal, 0},
+		{"status shown at debug", "debug", normal, 1},
+		{"emergency shown at info", "info", emergency, 1},
+		{"emergency hidden at error", "error", emergency, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			buf := captureLogs(t, ServerConfig{LogLevel: tt.level, LogFormat: LogFormatJSON})
+
+			// Act
+			LogMetricsSummary(tt.summary)
+
+			// Assert
+			assert.Len(t, decodeJSONLines(t, buf), tt.expected)
+		})
+	}
+}
+
+// TestLogMetricsSummary_Fields tests the structured loop fields
+func TestLogMetricsSummary_Fields(t *testing.T) {
+	// Arrange
+	buf := captureLogs(t, ServerConfig{LogLevel: "debug", LogFormat: LogFormatJSON})
+	summary := MetricsSummary{
+		CPUTemp: 52.5, MaxDiskTemp: 40, AvgDiskTemp: 39.25, FanDuty: 45, PIDError: 1.25,
+		P: 1.875, I: 40, D: -0.5, FF: 3, LoopTime: 1500 * time.Millisecond,
+	}
+
+	// Act
+	LogMetricsSummary(summary)
+
+	// Assert
+	records := decodeJSONLines(t, buf)
+	require.Len(t, records, 1)
+	record := records[0]
+	assert.Equal(t, "DEBUG", record["level"])
+	assert.Equal(t, 52.5, record["cpu_temp"])
+	assert.Equal(t, 39.25, record["avg_temp"])
+	assert.Equal(t, 40.0, record["max_temp"])
+	assert.Equal(t, 45.0, record["duty"])
+	assert.Equal(t, 1.875, record["p"])
+	assert.Equal(t, 40.0, record["i"])
+	assert.Equal(t, -0.5, record["d"])
+	assert.Equal(t, 3.0, record["ff"])
+	assert.Equal(t, "", record["emergency"])
+}
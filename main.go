@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -15,16 +17,29 @@ var (
 	configPath = flag.String("config", "/config/config.yaml", "Path to configuration file")
 	dryRun     = flag.Bool("dry-run", false, "Run in dry-run mode (no IPMI commands)")
 	testIPMI   = flag.Bool("test-ipmi", false, "Test IPMI functionality and exit")
+	autotune   = flag.Bool("autotune", false, "Run relay autotune, print suggested PID gains and exit")
 	logLevel   = flag.String("log-level", "", "Override log level (debug, info, warn, error)")
 )
 
 func main() {
-	flag.Parse()
+	// `fan-control check` prints the preflight report; flags may come before or after the command
+	command, args := "", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	flag.CommandLine.Parse(args)
+	if command == "" && flag.NArg() > 0 {
+		command = flag.Arg(0)
+	}
+	if command != "" && command != "check" {
+		fmt.Fprintf(os.Stderr, "Unknown command %q (available: check)\n", command)
+		os.Exit(2)
+	}
 	
 	// Load configuration
 	config, err := LoadConfig(*configPath)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		fatal("Failed to load config", "error", err)
 	}
 	
 	// Override log level if specified
@@ -32,231 +47,241 @@ func main() {
 		config.Server.LogLevel = *logLevel
 	}
 	
-	log.Printf("Starting fan controller (config: %s)", *configPath)
+	// Structured logging at the configured level, to stderr or journald
+	if err := setupLogging(config.Server); err != nil {
+		fatal("Failed to set up logging", "error", err)
+	}
+	
+	// Handle check subcommand: read-only report, never touches the fans
+	if command == "check" {
+		report := runCheck(context.Background(), config, *configPath)
+		report.Write(os.Stdout)
+		if len(report.Problems()) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	
+	slog.Info("Starting fan controller", "config", *configPath)
 	
 	// Handle test-ipmi flag
 	if *testIPMI {
-		if err := TestIPMICommand(); err != nil {
-			log.Fatalf("IPMI test failed: %v", err)
+		if err := TestIPMICommand(context.Background()); err != nil {
+			fatal("IPMI test failed", "error", err)
+		}
+		slog.Info("IPMI test completed successfully")
+		return
+	}
+	
+	// Handle autotune flag
+	if *autotune {
+		// A signal stops the experiment, and the fans are still left at 100%
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		err := runAutotune(ctx, config)
+		stop()
+		if err != nil {
+			fatal("Autotune failed", "error", err)
 		}
-		log.Println("IPMI test completed successfully")
 		return
 	}
 	
 	// Initialize metrics
-	metrics := InitMetrics()
+	InitMetrics()
 	
 	// Start metrics server
 	if err := StartMetricsServer(config.Server.MetricsPort); err != nil {
-		log.Fatalf("Failed to start metrics server: %v", err)
+		fatal("Failed to start metrics server", "error", err)
 	}
 	
 	// Initialize PID controller
-	pid := NewPIDController(
-		config.PID.Kp,
-		config.PID.Ki,
-		config.PID.Kd,
-		config.Temperature.TargetHDD,
-		float64(config.Fans.MinDuty),
-		float64(config.Fans.MaxDuty),
-		config.PID.IntegralMax,
-	)
+	pid := newPIDFromConfig(config)
+	if config.PID.Form == PIDFormLegacy {
+		slog.Warn("pid.form is legacy - ki has no effect and integral_max acts as the integral gain; set pid.form: standard to apply ki")
+	}
 	
-	// Set up signal handling for graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	// Fan commands are skipped in dry-run mode, but speeds are still read
+	var fans FanActuator = ipmiFanActuator{bmc: config.Fans.BMC}
+	if *dryRun {
+		fans = dryRunFanActuator{FanActuator: fans}
+	}
+	controller := NewController(config, pid, &hostTempSource{config: config}, fans, systemClock{})
 	
-	// Start control loop in goroutine
-	controlLoopDone := make(chan bool)
-	go func() {
-		runControlLoop(config, pid, metrics)
-		controlLoopDone <- true
-	}()
+	// Cancel the control loop, and any in-flight smartctl/ipmitool call, on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 	
-	// Wait for shutdown signal
-	<-sigChan
-	log.Println("Received shutdown signal, setting fans to 100%...")
+	// Preflight: refuse to start without sensors or IPMI, unless degraded mode is configured
+	notifier := NewSystemdNotifier()
+	if err := validateEnvironment(ctx, config); err != nil {
+		if config.Server.OnStartupFailure != StartupFailureDegraded {
+			fatal("Environment validation failed (run `fan-control check` for a full report)", "error", err)
+		}
+		slog.Error("Environment validation failed, starting in degraded mode with fans at 100%", "error", err)
+		// Run applies startup_duty first and failed loops leave the duty alone, so
+		// fans stay at 100% until a loop succeeds
+		config.Fans.StartupDuty = 100
+		controller.SetDegraded(err.Error())
+		notifier.Ready("Degraded: " + err.Error())
+	} else {
+		notifier.Ready("Environment validation passed")
+	}
 	
-	// Emergency shutdown: set fans to 100%
+	// A crash anywhere must not leave the fans at a low manual duty
+	timeout := config.Server.ShutdownTimeout
+	defer controller.ShutdownOnPanic(timeout)
+	
+	// Dead-man switch: pet the hardware watchdog every loop and force 100% if the loop stalls
+	// The hardware watchdog can reset the host, so it is never armed in dry-run mode
+	var hardware HardwareWatchdog
 	if !*dryRun {
-		if err := SetAllFans(100); err != nil {
-			log.Printf("Warning: failed to set fans to 100%% during shutdown: %v", err)
-		} else {
-			log.Println("Fans set to 100% for safety")
+		hardware, err = NewHardwareWatchdog(ctx, config.Watchdog, config.Temperature.PollInterval)
+		if err != nil {
+			fatal("Failed to start hardware watchdog", "error", err)
 		}
 	}
+	watchdog := NewWatchdog(config, hardware, fans, systemClock{})
+	controller.SetWatchdog(watchdog)
+	go func() {
+		defer controller.ShutdownOnPanic(timeout)
+		watchdog.Run(ctx)
+	}()
 	
-	// Wait for control loop to finish
-	<-controlLoopDone
-	log.Println("Fan controller stopped")
-}
+	// sd_notify WATCHDOG/STATUS when started by systemd with Type=notify
+	if interval := notifier.WatchdogInterval(); interval > 0 && interval <= config.Temperature.PollInterval {
+		slog.Warn("systemd WatchdogSec must be longer than poll_interval",
+			"watchdog_sec", interval, "poll_interval", config.Temperature.PollInterval)
+	}
+	controller.SetSystemd(notifier)
+	
+	// Notifications on emergency, fan and sensor state changes
+	var alerts *Alerter
+	if notifiers := NewNotifiers(config.Notify); len(notifiers) > 0 {
+		alerts = NewAlerter(config.Notify, notifiers)
+		controller.SetAlerter(alerts)
+		slog.Info("Notifications enabled", "notifiers", len(notifiers), "rate_limit", config.Notify.RateLimit)
+	}
 
-// runControlLoop executes the main control loop
-func runControlLoop(config *Config, pid *PIDController, metrics *Metrics) {
-	log.Printf("Starting control loop (target: %.1f°C, interval: %v)", 
-		config.Temperature.TargetHDD, config.Temperature.PollInterval)
+	// MQTT state publishing, Home Assistant discovery and remote control
+	var bridge *MQTTBridge
+	if config.MQTT.Enabled {
+		bridge = NewMQTTBridge(config.MQTT, controller)
+		bridge.Connect()
+		controller.SetMQTT(bridge)
+	}
 	
-	// Set initial fan speed
-	if !*dryRun {
-		if err := SetAllFans(config.Fans.StartupDuty); err != nil {
-			log.Printf("Warning: failed to set initial fan speed: %v", err)
-		} else {
-			log.Printf("Set initial fan speed to %d%%", config.Fans.StartupDuty)
-		}
+	// Start control loop in goroutine
+	controlLoopDone := make(chan struct{})
+	go func() {
+		defer close(controlLoopDone)
+		defer controller.ShutdownOnPanic(timeout)
+		controller.Run(ctx)
+	}()
+	
+	// Wait for shutdown signal
+	<-ctx.Done()
+	stop()
+	slog.Info("Received shutdown signal, stopping control loop...")
+	if err := notifier.Stopping(); err != nil {
+		slog.Warn("Failed to notify systemd", "error", err)
 	}
 	
-	// Control loop state
-	var consecutiveIPMIFailures int
-	const maxIPMIFailures = 5
+	// Wait for control loop to finish, but never hang the shutdown on it
+	select {
+	case <-controlLoopDone:
+	case <-time.After(timeout):
+		slog.Warn("Control loop did not stop in time", "timeout", timeout)
+	}
 	
-	// Main control loop
-	for {
-		loopStart := time.Now()
-		
-		// Read temperatures
-		diskTemps, cpuTemp, err := readAllTemperatures(config)
-		if err != nil {
-			log.Printf("Error reading temperatures: %v", err)
-			RecordError("temperature")
-			time.Sleep(config.Temperature.PollInterval)
-			continue
-		}
-		
-		// Calculate temperature metrics
-		avgTemp := GetAverageOfWarmest(diskTemps, config.Temperature.WarmestDisks)
-		maxTemp := GetMaxTemperature(diskTemps)
-		
-		// Check for emergency conditions
-		emergencyReason := checkEmergencyConditions(cpuTemp, maxTemp, config)
-		
-		var fanDuty int
-		var pidTerms PIDTerms
-		
-		if emergencyReason != "" {
-			// Emergency mode: set fans to 100%
-			fanDuty = 100
-			pidTerms = PIDTerms{} // Zero terms in emergency
-			log.Printf("EMERGENCY: %s - setting fans to 100%%", emergencyReason)
-		} else {
-			// Normal PID control
-			output, terms := pid.Calculate(avgTemp)
-			pidTerms = terms
-			fanDuty = int(output)
-			
-			// Clamp to fan limits
-			if fanDuty < config.Fans.MinDuty {
-				fanDuty = config.Fans.MinDuty
-			}
-			if fanDuty > config.Fans.MaxDuty {
-				fanDuty = config.Fans.MaxDuty
-			}
-		}
-		
-		// Set fan speed (unless in dry-run mode)
-		if !*dryRun {
-			if err := SetAllFans(fanDuty); err != nil {
-				consecutiveIPMIFailures++
-				RecordError("ipmi")
-				log.Printf("IPMI command failed (attempt %d/%d): %v", 
-					consecutiveIPMIFailures, maxIPMIFailures, err)
-				
-				// If too many consecutive failures, force emergency mode
-				if consecutiveIPMIFailures >= maxIPMIFailures {
-					log.Printf("Too many IPMI failures (%d), forcing emergency mode", consecutiveIPMIFailures)
-					emergencyReason = "ipmi_failure"
-					fanDuty = 100
-					// Try one more time to set 100%
-					if err := SetAllFans(100); err != nil {
-						log.Printf("Critical: failed to set emergency fan speed: %v", err)
-					}
-				}
-			} else {
-				consecutiveIPMIFailures = 0 // Reset failure counter on success
-			}
-		}
-		
-		// Read current fan speeds for metrics
-		fanSpeeds, err := GetFanSpeeds()
-		if err != nil {
-			log.Printf("Warning: failed to read fan speeds: %v", err)
-			fanSpeeds = make(map[string]int) // Empty map for metrics
+	// Apply the fans.on_exit action (no-op in dry-run mode)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := controller.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to apply on_exit action", "error", err)
+	}
+	if err := watchdog.Close(); err != nil {
+		slog.Warn("Failed to disarm hardware watchdog", "error", err)
+	}
+	if alerts != nil {
+		if err := alerts.Wait(shutdownCtx); err != nil {
+			slog.Warn("Notifications still in flight at shutdown", "error", err)
 		}
-		
-		// Update metrics
-		UpdateAllMetrics(
-			diskTemps, cpuTemp, fanSpeeds, fanDuty,
-			pidTerms, avgTemp, maxTemp, emergencyReason,
-			time.Since(loopStart),
-		)
-		
-		// Log status
-		summary := GetMetricsSummary(
-			diskTemps, cpuTemp, fanDuty, pidTerms,
-			avgTemp, maxTemp, emergencyReason, time.Since(loopStart),
-		)
-		LogMetricsSummary(summary)
-		
-		// Sleep until next iteration
-		time.Sleep(config.Temperature.PollInterval)
 	}
+	if bridge != nil {
+		bridge.Close()
+	}
+	slog.Info("Fan controller stopped")
+}
+
+// newPIDFromConfig creates the fan PID controller from the pid and fans config
+func newPIDFromConfig(config *Config) *PIDController {
+	pid := NewPIDController(
+		config.PID.Kp,
+		config.PID.Ki,
+		config.PID.Kd,
+		config.Temperature.TargetHDD,
+		float64(config.Fans.MinDuty),
+		float64(config.Fans.MaxDuty),
+		config.PID.IntegralMax,
+	)
+	pid.SetForm(config.PID.Form)
+	pid.SetDerivativeMode(config.PID.DerivativeOnMeasurement, config.PID.DerivativeFilter.Seconds())
+	pid.SetAntiWindup(config.PID.AntiWindup, config.PID.TrackingGain)
+	return pid
 }
 
 // readAllTemperatures reads all temperature sensors
-func readAllTemperatures(config *Config) (map[string]int, float64, error) {
+func readAllTemperatures(ctx context.Context, config *Config) (TempReadings, error) {
 	// Read disk temperatures
-	diskTemps, err := GetAllDiskTemperatures(config.Disks.ExcludePatterns)
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to read disk temperatures: %w", err)
-	}
-	
-	// Read CPU temperature
-	cpuTemp, err := GetCPUTemperature()
+	readings, err := GetAllDiskTemperatures(ctx, config.Disks)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to read CPU temperature: %w", err)
+		return readings, fmt.Errorf("failed to read disk temperatures: %w", err)
 	}
 	
-	return diskTemps, cpuTemp, nil
-}
-
-// checkEmergencyConditions checks for emergency temperature conditions
-func checkEmergencyConditions(cpuTemp float64, maxDiskTemp int, config *Config) string {
-	// Check CPU emergency temperature
-	if cpuTemp > config.Temperature.MaxCPU {
-		return "cpu_temp"
+	// Read SES enclosure sensors; they only feed their own policy, so failures never fail the poll
+	if config.Enclosures.Enabled {
+		readings.Enclosure, err = GetEnclosureTemperatures(ctx, config.Enclosures)
+		if err != nil {
+			if ctx.Err() != nil {
+				return readings, ctx.Err()
+			}
+			slog.Warn("Failed to read enclosure sensors", "error", err)
+			RecordError("enclosure")
+		}
 	}
 	
-	// Check disk emergency temperature
-	if maxDiskTemp > int(config.Temperature.MaxHDD) {
-		return "hdd_temp"
+	// Read CPU temperature
+	readings.CPU, err = GetCPUTemperature()
+	if err != nil {
+		return readings, fmt.Errorf("failed to read CPU temperature: %w", err)
 	}
 	
-	return "" // No emergency
+	return readings, nil
 }
 
 // validateEnvironment checks if the environment is suitable for operation
-func validateEnvironment(config *Config) error {
+func validateEnvironment(ctx context.Context, config *Config) error {
 	// Check if we can read CPU temperature
 	if _, err := GetCPUTemperature(); err != nil {
 		return fmt.Errorf("CPU temperature sensor not accessible: %w", err)
 	}
 	
 	// Check if we can read disk temperatures
-	diskTemps, err := GetAllDiskTemperatures(config.Disks.ExcludePatterns)
+	readings, err := GetAllDiskTemperatures(ctx, config.Disks)
 	if err != nil {
 		return fmt.Errorf("disk temperature sensors not accessible: %w", err)
 	}
 	
-	if len(diskTemps) == 0 {
+	if len(readings.Disks) == 0 {
 		return fmt.Errorf("no spinning disks found for temperature monitoring")
 	}
 	
 	// Check IPMI accessibility (unless in dry-run mode)
 	if !*dryRun {
-		if _, err := GetFanSpeeds(); err != nil {
+		if _, err := GetFanSpeeds(ctx); err != nil {
 			return fmt.Errorf("IPMI not accessible: %w", err)
 		}
 	}
 	
-	log.Printf("Environment validation passed: %d disks, CPU sensor OK", len(diskTemps))
+	slog.Info("Environment validation passed", "disks", len(readings.Disks))
 	return nil
 }
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -15,6 +17,15 @@ import (
 type Metrics struct {
 	// Temperature metrics
 	HDDTemperature     *prometheus.GaugeVec   // Individual disk temperatures
+	HDDReadSuccess     *prometheus.GaugeVec   // 1 if the disk was read in the last poll, 0 if the read failed
+	HDDLastRead        *prometheus.GaugeVec   // Unix time of the last successful read per disk
+	DiskInfo           *prometheus.GaugeVec   // Device, model, WWN and by-id name per serial
+	FlashTemperature   *prometheus.GaugeVec   // Individual SSD/NVMe temperatures
+	EnclosureTemp      *prometheus.GaugeVec   // SES enclosure temperature sensors
+	EnclosureFanSpeed  *prometheus.GaugeVec   // SES enclosure cooling element speeds
+	DiskCount          *prometheus.GaugeVec   // Disks present in the last poll, by class
+	DisksMissing       prometheus.Gauge       // Removed disks that have not come back
+	DiskEvents         *prometheus.CounterVec // Disks added and removed while running
 	HDDTemperatureMax  prometheus.Gauge      // Maximum disk temperature
 	HDDTemperatureAvg  prometheus.Gauge      // Average of warmest disks
 	CPUTemperature     prometheus.Gauge      // CPU temperature
@@ -27,12 +38,24 @@ type Metrics struct {
 	PIDProportional    prometheus.Gauge      // P term
 	PIDIntegral        prometheus.Gauge      // I term
 	PIDDerivative      prometheus.Gauge      // D term
+	PIDFeedForward     prometheus.Gauge      // Feed-forward bias term
 	PIDError           prometheus.Gauge      // Current error
 	
 	// System metrics
 	EmergencyMode      *prometheus.GaugeVec // Emergency mode status
+	EmergencyEntered   *prometheus.CounterVec // Times each emergency reason became active
+	EmergencyTime      *prometheus.CounterVec // Seconds spent in each emergency reason
 	ErrorsTotal        *prometheus.CounterVec // Error counters
+	RejectedReadings   *prometheus.CounterVec // Implausible readings dropped by the plausibility filter
+	Notifications      *prometheus.CounterVec // Notifications sent and failed, by notifier
 	LoopDuration       prometheus.Histogram // Control loop timing
+	SinceLastLoop      prometheus.Gauge     // Seconds since the last successful loop
+	
+	// Label values written by the previous poll, so vanished disks and fans are deleted
+	disks      map[string]bool
+	fans       map[string]bool
+	enclosures map[string]bool     // Enclosure sensor and fan names
+	diskInfo   map[string][]string // DiskInfo label values by serial
 }
 
 // HealthResponse represents the health check response
@@ -59,9 +82,71 @@ func InitMetrics() *Metrics {
 		HDDTemperature: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "fan_controller_hdd_temperature_celsius",
-				Help: "HDD temperature in Celsius",
+				Help: "HDD temperature in Celsius, by disk serial number",
+			},
+			[]string{"serial"},
+		),
+		HDDReadSuccess: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "fan_controller_hdd_read_success",
+				Help: "Whether the last temperature read of the disk succeeded (1=ok, 0=failed)",
+			},
+			[]string{"serial"},
+		),
+		HDDLastRead: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "fan_controller_hdd_last_read_timestamp_seconds",
+				Help: "Unix time of the last successful temperature read of the disk",
+			},
+			[]string{"serial"},
+		),
+		FlashTemperature: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "fan_controller_flash_temperature_celsius",
+				Help: "SSD/NVMe temperature in Celsius, by serial number",
+			},
+			[]string{"serial"},
+		),
+		EnclosureTemp: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "fan_controller_enclosure_temperature_celsius",
+				Help: "SES enclosure temperature sensor in Celsius, by <enclosure logical id>/temp<n>",
+			},
+			[]string{"sensor"},
+		),
+		EnclosureFanSpeed: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "fan_controller_enclosure_fan_speed_rpm",
+				Help: "SES enclosure cooling element speed in RPM, by <enclosure logical id>/fan<n>",
+			},
+			[]string{"fan"},
+		),
+		DiskCount: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "fan_controller_disk_count",
+				Help: "Number of disks present in the last poll (read or failed), by class (hdd, flash)",
+			},
+			[]string{"class"},
+		),
+		DisksMissing: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "fan_controller_disks_missing",
+				Help: "Number of previously seen disks that have been removed and not come back",
+			},
+		),
+		DiskEvents: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "fan_controller_disk_events_total",
+				Help: "Total number of disks added or removed while running, by event",
 			},
-			[]string{"disk"},
+			[]string{"event"},
+		),
+		DiskInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "fan_controller_disk_info",
+				Help: "Monitored disk identity (always 1); join on serial for the current kernel name",
+			},
+			[]string{"serial", "device", "model", "wwn", "by_id"},
 		),
 		HDDTemperatureMax: prometheus.NewGauge(
 			prometheus.GaugeOpts{
@@ -72,7 +157,7 @@ func InitMetrics() *Metrics {
 		HDDTemperatureAvg: prometheus.NewGauge(
 			prometheus.GaugeOpts{
 				Name: "fan_controller_hdd_temperature_avg_celsius",
-				Help: "Average temperature of warmest disks in Celsius",
+				Help: "Control input from temperature.aggregate in Celsius (average of warmest disks by default)",
 			},
 		),
 		CPUTemperature: prometheus.NewGauge(
@@ -116,6 +201,12 @@ func InitMetrics() *Metrics {
 				Help: "PID derivative term",
 			},
 		),
+		PIDFeedForward: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "fan_controller_pid_feedforward",
+				Help: "PID feed-forward bias term from CPU load and disk I/O",
+			},
+		),
 		PIDError: prometheus.NewGauge(
 			prometheus.GaugeOpts{
 				Name: "fan_controller_pid_error_celsius",
@@ -131,6 +222,20 @@ func InitMetrics() *Metrics {
 			},
 			[]string{"reason"},
 		),
+		EmergencyEntered: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "fan_controller_emergency_transitions_total",
+				Help: "Total number of times each emergency reason became active",
+			},
+			[]string{"reason"},
+		),
+		EmergencyTime: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "fan_controller_time_in_emergency_seconds",
+				Help: "Total seconds spent in emergency mode, by reason",
+			},
+			[]string{"reason"},
+		),
 		ErrorsTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "fan_controller_errors_total",
@@ -138,6 +243,20 @@ func InitMetrics() *Metrics {
 			},
 			[]string{"type"},
 		),
+		RejectedReadings: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "fan_controller_rejected_readings_total",
+				Help: "Total number of implausible temperature readings rejected, by source and reason",
+			},
+			[]string{"source", "reason"},
+		),
+		Notifications: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "fan_controller_notifications_total",
+				Help: "Total number of notifications by notifier and result (sent, failed)",
+			},
+			[]string{"notifier", "result"},
+		),
 		LoopDuration: prometheus.NewHistogram(
 			prometheus.HistogramOpts{
 				Name: "fan_controller_loop_duration_seconds",
@@ -145,11 +264,30 @@ func InitMetrics() *Metrics {
 				Buckets: []float64{0.1, 0.5, 1.0, 2.0, 5.0, 10.0, 30.0},
 			},
 		),
+		SinceLastLoop: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "fan_controller_seconds_since_last_loop",
+				Help: "Seconds since the control loop last completed successfully",
+			},
+		),
+		disks:      make(map[string]bool),
+		fans:       make(map[string]bool),
+		enclosures: make(map[string]bool),
+		diskInfo:   make(map[string][]string),
 	}
 	
 	// Register all metrics
 	prometheus.MustRegister(
 		metrics.HDDTemperature,
+		metrics.HDDReadSuccess,
+		metrics.HDDLastRead,
+		metrics.DiskInfo,
+		metrics.FlashTemperature,
+		metrics.EnclosureTemp,
+		metrics.EnclosureFanSpeed,
+		metrics.DiskCount,
+		metrics.DisksMissing,
+		metrics.DiskEvents,
 		metrics.HDDTemperatureMax,
 		metrics.HDDTemperatureAvg,
 		metrics.CPUTemperature,
@@ -158,12 +296,25 @@ func InitMetrics() *Metrics {
 		metrics.PIDProportional,
 		metrics.PIDIntegral,
 		metrics.PIDDerivative,
+		metrics.PIDFeedForward,
 		metrics.PIDError,
 		metrics.EmergencyMode,
+		metrics.EmergencyEntered,
+		metrics.EmergencyTime,
 		metrics.ErrorsTotal,
+		metrics.RejectedReadings,
+		metrics.Notifications,
 		metrics.LoopDuration,
+		metrics.SinceLastLoop,
 	)
 	
+	// Export every emergency reason from the start, so rate() and alerts see a 0
+	for _, reason := range emergencyReasons() {
+		metrics.EmergencyMode.WithLabelValues(reason).Set(0)
+		metrics.EmergencyEntered.WithLabelValues(reason)
+		metrics.EmergencyTime.WithLabelValues(reason)
+	}
+	
 	return metrics
 }
 
@@ -178,9 +329,9 @@ func StartMetricsServer(port int) error {
 	// Start server in goroutine
 	go func() {
 		addr := fmt.Sprintf(":%d", port)
-		log.Printf("Starting metrics server on %s", addr)
+		slog.Info("Starting metrics server", "addr", addr)
 		if err := http.ListenAndServe(addr, nil); err != nil {
-			log.Printf("Metrics server error: %v", err)
+			slog.Error("Metrics server error", "error", err)
 		}
 	}()
 	
@@ -201,15 +352,14 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Failed to encode health response: %v", err)
+		slog.Error("Failed to encode health response", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
 }
 
 // UpdateAllMetrics updates all metrics with current values
 func UpdateAllMetrics(
-	diskTemps map[string]int,
-	cpuTemp float64,
+	readings TempReadings,
 	fanSpeeds map[string]int,
 	fanDuty int,
 	pidTerms PIDTerms,
@@ -219,41 +369,154 @@ func UpdateAllMetrics(
 	loopDuration time.Duration,
 ) {
 	// Update disk temperatures
-	for disk, temp := range diskTemps {
-		metrics.HDDTemperature.WithLabelValues(disk).Set(float64(temp))
-	}
+	UpdateDiskMetrics(readings)
 	
 	// Update temperature summaries
 	metrics.HDDTemperatureMax.Set(float64(maxTemp))
 	metrics.HDDTemperatureAvg.Set(avgTemp)
-	metrics.CPUTemperature.Set(cpuTemp)
+	metrics.CPUTemperature.Set(readings.CPU)
 	
-	// Update fan metrics
+	// Update fan metrics, dropping fans that were not reported this poll
 	metrics.FanDutyPercent.Set(float64(fanDuty))
+	fans := make(map[string]bool, len(fanSpeeds))
 	for fan, speed := range fanSpeeds {
 		metrics.FanSpeedRPM.WithLabelValues(fan).Set(float64(speed))
+		fans[fan] = true
 	}
+	deleteStaleLabels(metrics.fans, fans, metrics.FanSpeedRPM)
+	metrics.fans = fans
 	
 	// Update PID metrics
 	metrics.PIDProportional.Set(pidTerms.P)
 	metrics.PIDIntegral.Set(pidTerms.I)
 	metrics.PIDDerivative.Set(pidTerms.D)
+	metrics.PIDFeedForward.Set(pidTerms.FF)
 	metrics.PIDError.Set(pidTerms.Error)
 	
+	// Update SES enclosure sensors, dropping sensors that were not reported this poll
+	UpdateEnclosureMetrics(readings.Enclosure)
+	
 	// Update emergency mode
+	UpdateEmergencyMetrics(emergencyReason)
+	
+	// Update loop duration
+	metrics.LoopDuration.Observe(loopDuration.Seconds())
+}
+
+// UpdateEmergencyMetrics resets every emergency reason, then sets the active one
+func UpdateEmergencyMetrics(emergencyReason string) {
+	for _, reason := range emergencyReasons() {
+		metrics.EmergencyMode.WithLabelValues(reason).Set(0)
+	}
 	if emergencyReason != "" {
 		metrics.EmergencyMode.WithLabelValues(emergencyReason).Set(1)
-		// Reset other emergency reasons to 0
-		metrics.EmergencyMode.WithLabelValues("hdd_temp").Set(0)
-		metrics.EmergencyMode.WithLabelValues("cpu_temp").Set(0)
-	} else {
-		// No emergency - reset all
-		metrics.EmergencyMode.WithLabelValues("hdd_temp").Set(0)
-		metrics.EmergencyMode.WithLabelValues("cpu_temp").Set(0)
 	}
+}
+
+// UpdateDiskMetrics records one poll of disk and flash reads, labelled by serial
+// number so history survives kernel renames. A failed disk loses its temperature
+// series but keeps its last read timestamp; a disk that is no longer discovered is
+// dropped entirely
+func UpdateDiskMetrics(readings TempReadings) {
+	disks := make(map[string]bool, len(readings.Disks)+len(readings.Flash)+len(readings.FailedDisks))
+	read := func(device string, temp int, temperature *prometheus.GaugeVec) {
+		serial := readings.Identity(device).Serial
+		temperature.WithLabelValues(serial).Set(float64(temp))
+		metrics.HDDReadSuccess.WithLabelValues(serial).Set(1)
+		metrics.HDDLastRead.WithLabelValues(serial).SetToCurrentTime()
+		disks[serial] = true
+	}
+	for device, temp := range readings.Disks {
+		read(device, temp, metrics.HDDTemperature)
+	}
+	for device, temp := range readings.Flash {
+		read(device, temp, metrics.FlashTemperature)
+	}
+	for _, device := range readings.FailedDisks {
+		serial := readings.Identity(device).Serial
+		metrics.HDDTemperature.DeleteLabelValues(serial)
+		metrics.FlashTemperature.DeleteLabelValues(serial)
+		metrics.HDDReadSuccess.WithLabelValues(serial).Set(0)
+		disks[serial] = true
+	}
+	deleteStaleLabels(metrics.disks, disks,
+		metrics.HDDTemperature, metrics.FlashTemperature, metrics.HDDReadSuccess, metrics.HDDLastRead)
+	metrics.disks = disks
 	
-	// Update loop duration
-	metrics.LoopDuration.Observe(loopDuration.Seconds())
+	updateDiskInfo(readings)
+}
+
+// updateDiskInfo sets the info series of every disk in readings, replacing the
+// series of a serial whose device or by-id name changed
+func updateDiskInfo(readings TempReadings) {
+	info := make(map[string][]string, len(metrics.disks))
+	devices := append(diskNames(readings.Disks), diskNames(readings.Flash)...)
+	devices = append(devices, readings.FailedDisks...)
+	for _, device := range devices {
+		identity := readings.Identity(device)
+		labels := []string{identity.Serial, identity.Device, identity.Model, identity.WWN, identity.ByID}
+		if previous, ok := metrics.diskInfo[identity.Serial]; ok && !slices.Equal(previous, labels) {
+			metrics.DiskInfo.DeleteLabelValues(previous...)
+		}
+		metrics.DiskInfo.WithLabelValues(labels...).Set(1)
+		info[identity.Serial] = labels
+	}
+	for serial, labels := range metrics.diskInfo {
+		if _, ok := info[serial]; !ok {
+			metrics.DiskInfo.DeleteLabelValues(labels...)
+		}
+	}
+	metrics.diskInfo = info
+}
+
+// deleteStaleLabels deletes the series of label values in previous but not in current
+func deleteStaleLabels(previous, current map[string]bool, vecs ...*prometheus.GaugeVec) {
+	for label := range previous {
+		if current[label] {
+			continue
+		}
+		for _, vec := range vecs {
+			vec.DeleteLabelValues(label)
+		}
+	}
+}
+
+// UpdateEnclosureMetrics records the SES enclosure sensors and deletes the ones
+// missing from this poll
+func UpdateEnclosureMetrics(readings EnclosureReadings) {
+	sensors := make(map[string]bool, len(readings.Temperatures)+len(readings.Fans))
+	for sensor, temp := range readings.Temperatures {
+		metrics.EnclosureTemp.WithLabelValues(sensor).Set(float64(temp))
+		sensors[sensor] = true
+	}
+	for fan, speed := range readings.Fans {
+		metrics.EnclosureFanSpeed.WithLabelValues(fan).Set(float64(speed))
+		sensors[fan] = true
+	}
+	deleteStaleLabels(metrics.enclosures, sensors, metrics.EnclosureTemp, metrics.EnclosureFanSpeed)
+	metrics.enclosures = sensors
+}
+
+// UpdateHotplugMetrics counts disk events and records the current disk counts
+func UpdateHotplugMetrics(events []DiskEvent, hdds, flash, missing int) {
+	for _, event := range events {
+		metrics.DiskEvents.WithLabelValues(event.Type).Inc()
+	}
+	metrics.DiskCount.WithLabelValues("hdd").Set(float64(hdds))
+	metrics.DiskCount.WithLabelValues("flash").Set(float64(flash))
+	metrics.DisksMissing.Set(float64(missing))
+}
+
+// RecordEmergencyTransition counts an emergency reason becoming active
+func RecordEmergencyTransition(reason string) {
+	metrics.EmergencyEntered.WithLabelValues(reason).Inc()
+}
+
+// RecordEmergencyTime adds time spent in an emergency reason
+func RecordEmergencyTime(reason string, d time.Duration) {
+	if d > 0 {
+		metrics.EmergencyTime.WithLabelValues(reason).Add(d.Seconds())
+	}
 }
 
 // RecordError increments the error counter for the specified type
@@ -261,6 +524,20 @@ func RecordError(errorType string) {
 	metrics.ErrorsTotal.WithLabelValues(errorType).Inc()
 }
 
+// RecordRejectedReading counts a reading rejected by the plausibility filter
+func RecordRejectedReading(source, reason string) {
+	metrics.RejectedReadings.WithLabelValues(source, reason).Inc()
+}
+
+// RecordNotification counts a notification delivered or failed by notifier
+func RecordNotification(notifier string, sent bool) {
+	result := "sent"
+	if !sent {
+		result = "failed"
+	}
+	metrics.Notifications.WithLabelValues(notifier, result).Inc()
+}
+
 // GetMetrics returns the global metrics instance
 func GetMetrics() *Metrics {
 	return metrics
@@ -270,6 +547,18 @@ func GetMetrics() *Metrics {
 func ResetMetrics() {
 	// Reset gauges
 	metrics.HDDTemperature.Reset()
+	metrics.HDDReadSuccess.Reset()
+	metrics.HDDLastRead.Reset()
+	metrics.DiskInfo.Reset()
+	metrics.FlashTemperature.Reset()
+	metrics.DiskCount.Reset()
+	metrics.DisksMissing.Set(0)
+	metrics.disks = make(map[string]bool)
+	metrics.fans = make(map[string]bool)
+	metrics.enclosures = make(map[string]bool)
+	metrics.EnclosureTemp.Reset()
+	metrics.EnclosureFanSpeed.Reset()
+	metrics.diskInfo = make(map[string][]string)
 	metrics.HDDTemperatureMax.Set(0)
 	metrics.HDDTemperatureAvg.Set(0)
 	metrics.CPUTemperature.Set(0)
@@ -278,8 +567,10 @@ func ResetMetrics() {
 	metrics.PIDProportional.Set(0)
 	metrics.PIDIntegral.Set(0)
 	metrics.PIDDerivative.Set(0)
+	metrics.PIDFeedForward.Set(0)
 	metrics.PIDError.Set(0)
 	metrics.EmergencyMode.Reset()
+	metrics.SinceLastLoop.Set(0)
 	
 	// Note: Counters and histograms are not reset as they are cumulative
 }
@@ -291,6 +582,10 @@ type MetricsSummary struct {
 	AvgDiskTemp  float64
 	FanDuty      int
 	PIDError     float64
+	P            float64 // PID terms (duty %)
+	I            float64
+	D            float64
+	FF           float64
 	Emergency    string
 	LoopTime     time.Duration
 }
@@ -312,20 +607,45 @@ func GetMetricsSummary(
 		AvgDiskTemp: avgTemp,
 		FanDuty:     fanDuty,
 		PIDError:    pidTerms.Error,
+		P:           pidTerms.P,
+		I:           pidTerms.I,
+		D:           pidTerms.D,
+		FF:          pidTerms.FF,
 		Emergency:   emergencyReason,
 		LoopTime:    loopDuration,
 	}
 }
 
-// LogMetricsSummary logs a formatted summary of current metrics
-func LogMetricsSummary(summary MetricsSummary) {
+// FormatMetricsSummary returns the one-line status used in logs and the systemd status
+func FormatMetricsSummary(summary MetricsSummary) string {
 	if summary.Emergency != "" {
-		log.Printf("EMERGENCY: %s | CPU: %.1f°C | Max: %d°C | Avg: %.1f°C | Duty: %d%% | Error: %.1f°C | Time: %v",
-			summary.Emergency, summary.CPUTemp, summary.MaxDiskTemp, 
+		return fmt.Sprintf("EMERGENCY: %s | CPU: %.1f°C | Max: %d°C | Avg: %.1f°C | Duty: %d%% | Error: %.1f°C | Time: %v",
+			summary.Emergency, summary.CPUTemp, summary.MaxDiskTemp,
 			summary.AvgDiskTemp, summary.FanDuty, summary.PIDError, summary.LoopTime)
-	} else {
-		log.Printf("Status: CPU: %.1f°C | Max: %d°C | Avg: %.1f°C | Duty: %d%% | Error: %.1f°C | Time: %v",
-			summary.CPUTemp, summary.MaxDiskTemp, summary.AvgDiskTemp, 
-			summary.FanDuty, summary.PIDError, summary.LoopTime)
 	}
+	return fmt.Sprintf("Status: CPU: %.1f°C | Max: %d°C | Avg: %.1f°C | Duty: %d%% | Error: %.1f°C | Time: %v",
+		summary.CPUTemp, summary.MaxDiskTemp, summary.AvgDiskTemp,
+		summary.FanDuty, summary.PIDError, summary.LoopTime)
+}
+
+// LogMetricsSummary logs the loop summary as structured fields; normal
+// iterations log at debug level and emergencies at warn
+func LogMetricsSummary(summary MetricsSummary) {
+	level, message := slog.LevelDebug, "Control loop status"
+	if summary.Emergency != "" {
+		level, message = slog.LevelWarn, "Control loop emergency"
+	}
+	slog.Log(context.Background(), level, message,
+		"cpu_temp", summary.CPUTemp,
+		"max_temp", summary.MaxDiskTemp,
+		"avg_temp", summary.AvgDiskTemp,
+		"duty", summary.FanDuty,
+		"pid_error", summary.PIDError,
+		"p", summary.P,
+		"i", summary.I,
+		"d", summary.D,
+		"ff", summary.FF,
+		"emergency", summary.Emergency,
+		"loop_time", summary.LoopTime,
+	)
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestUpdateDiskMetrics_Staleness tests that failed disks lose their temperature and
+// removed disks lose all series
+func TestUpdateDiskMetrics_Staleness(t *testing.T) {
+	// Arrange
+	initTestMetrics()
+	ResetMetrics()
+	UpdateDiskMetrics(TempReadings{Disks: map[string]int{"sda": 38, "sdb": 40, "sdc": 41}})
+
+	// Act - sdb fails, sdc is pulled
+	UpdateDiskMetrics(TempReadings{Disks: map[string]int{"sda": 39}, FailedDisks: []string{"sdb"}})
+
+	// Assert
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.HDDTemperature))
+	assert.Equal(t, 39.0, testutil.ToFloat64(metrics.HDDTemperature.WithLabelValues("sda")))
+
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.HDDReadSuccess))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.HDDReadSuccess.WithLabelValues("sda")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.HDDReadSuccess.WithLabelValues("sdb")))
+
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.HDDLastRead), "sdb keeps its last read time")
+	assert.InDelta(t, float64(time.Now().Unix()), testutil.ToFloat64(metrics.HDDLastRead.WithLabelValues("sdb")), 5)
+}
+
+// TestUpdateDiskMetrics_DiskRecovers tests that a failed disk reports again once read
+func TestUpdateDiskMetrics_DiskRecovers(t *testing.T) {
+	// Arrange
+	initTestMetrics()
+	ResetMetrics()
+	UpdateDiskMetrics(TempReadings{FailedDisks: []string{"sda"}})
+
+	// Act
+	UpdateDiskMetrics(TempReadings{Disks: map[string]int{"sda": 37}})
+
+	// Assert
+	assert.Equal(t, 37.0, testutil.ToFloat64(metrics.HDDTemperature.WithLabelValues("sda")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.HDDReadSuccess.WithLabelValues("sda")))
+}
+
+// TestUpdateDiskMetrics_SerialLabels tests that series follow the serial across a kernel rename
+func TestUpdateDiskMetrics_SerialLabels(t *testing.T) {
+	// Arrange
+	initTestMetrics()
+	ResetMetrics()
+	identity := DiskIdentity{Device: "sda", Serial: "VAGXYZ12", Model: "WDC WD80EFAX", WWN: "0x5000cca252c0ffee", ByID: "ata-WDC_WD80EFAX_VAGXYZ12"}
+	UpdateDiskMetrics(TempReadings{Disks: map[string]int{"sda": 38}, Identities: map[string]DiskIdentity{"sda": identity}})
+
+	// Act - after a rescan the same disk is sdc
+	identity.Device = "sdc"
+	UpdateDiskMetrics(TempReadings{Disks: map[string]int{"sdc": 39}, Identities: map[string]DiskIdentity{"sdc": identity}})
+
+	// Assert
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.HDDTemperature))
+	assert.Equal(t, 39.0, testutil.ToFloat64(metrics.HDDTemperature.WithLabelValues("VAGXYZ12")))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.DiskInfo), "the sda info series is replaced")
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.DiskInfo.WithLabelValues(
+		"VAGXYZ12", "sdc", "WDC WD80EFAX", "0x5000cca252c0ffee", "ata-WDC_WD80EFAX_VAGXYZ12")))
+}
+
+// TestUpdateDiskMetrics_Flash tests that flash drives get their own temperature series
+func TestUpdateDiskMetrics_Flash(t *testing.T) {
+	// Arrange
+	initTestMetrics()
+	ResetMetrics()
+	UpdateDiskMetrics(TempReadings{Disks: map[string]int{"sda": 38}, Flash: map[string]int{"nvme0n1": 45, "nvme1n1": 47}})
+
+	// Act - nvme1n1 fails
+	UpdateDiskMetrics(TempReadings{Disks: map[string]int{"sda": 38}, Flash: map[string]int{"nvme0n1": 46}, FailedDisks: []string{"nvme1n1"}})
+
+	// Assert
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.HDDTemperature), "flash drives are not HDDs")
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.FlashTemperature))
+	assert.Equal(t, 46.0, testutil.ToFloat64(metrics.FlashTemperature.WithLabelValues("nvme0n1")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.HDDReadSuccess.WithLabelValues("nvme0n1")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.HDDReadSuccess.WithLabelValues("nvme1n1")))
+}
+
+// TestUpdateEnclosureMetrics tests that enclosure sensors missing from a poll are deleted
+func TestUpdateEnclosureMetrics(t *testing.T) {
+	// Arrange
+	initTestMetrics()
+	ResetMetrics()
+	UpdateEnclosureMetrics(EnclosureReadings{
+		Temperatures: map[string]int{"5003048001c1a27f/temp0": 34, "5003048001c1a27f/temp1": 41},
+		Fans:         map[string]int{"5003048001c1a27f/fan0": 5120},
+	})
+
+	// Act
+	UpdateEnclosureMetrics(EnclosureReadings{Temperatures: map[string]int{"5003048001c1a27f/temp0": 35}})
+
+	// Assert
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.EnclosureTemp))
+	assert.Equal(t, 35.0, testutil.ToFloat64(metrics.EnclosureTemp.WithLabelValues("5003048001c1a27f/temp0")))
+	assert.Equal(t, 0, testutil.CollectAndCount(metrics.EnclosureFanSpeed))
+}
+
+// TestUpdateAllMetrics_DropsStaleFans tests that fans missing from a poll are deleted
+func TestUpdateAllMetrics_DropsStaleFans(t *testing.T) {
+	tests := []struct {
+		name     string
+		second   map[string]int
+		expected int
+	}{
+		{"fan removed", map[string]int{"FAN1": 900}, 1},
+		{"fan speed read failed", map[string]int{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			initTestMetrics()
+			ResetMetrics()
+			readings := TempReadings{Disks: map[string]int{"sda": 38}, CPU: 50}
+			UpdateAllMetrics(readings, map[string]int{"FAN1": 900, "FAN2": 950}, 50, PIDTerms{}, 38, 38, "", time.Second)
+
+			// Act
+			UpdateAllMetrics(readings, tt.second, 50, PIDTerms{}, 38, 38, "", time.Second)
+
+			// Assert
+			assert.Equal(t, tt.expected, testutil.CollectAndCount(metrics.FanSpeedRPM))
+		})
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	// Max wait for the broker when connecting and for discovery publishes
+	mqttTimeout = 10 * time.Second
+
+	// Delay between connection attempts while the broker is unreachable
+	mqttRetryInterval = 10 * time.Second
+)
+
+// Characters Home Assistant accepts in object IDs; anything else becomes _
+var mqttObjectIDPattern = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// mqttState is the JSON published to <base>/state every iteration
+type mqttState struct {
+	CPUTemp     float64 `json:"cpu_temp"`
+	MaxDiskTemp int     `json:"max_disk_temp"`
+	AvgDiskTemp float64 `json:"avg_disk_temp"`
+	FanDuty     int     `json:"fan_duty"`
+	PIDError    float64 `json:"pid_error"`
+	Emergency   string  `json:"emergency"`
+	Mode        string  `json:"mode"`
+	ManualDuty  int     `json:"manual_duty"`
+	Setpoint    float64 `json:"setpoint"`
+	LoopTime    float64 `json:"loop_time"` // Seconds
+}
+
+// MQTTBridge publishes every loop iteration to MQTT, announces the values as
+// Home Assistant entities through discovery and applies mode, duty and setpoint
+// commands to the controller. Paho reconnects in the background, so a broker
+// outage never blocks the control loop
+type MQTTBridge struct {
+	config     MQTTConfig
+	controller *Controller
+	client     mqtt.Client
+	nodeID     string
+	base       string // <topic_prefix>/<node_id>
+
+	mu        sync.Mutex
+	announced map[string]bool // Discovery topics of disks and fans already published
+	last      mqttState       // Last published state, republished after a command
+}
+
+// NewMQTTBridge creates a bridge for controller; call Connect to start it
+func NewMQTTBridge(config MQTTConfig, controller *Controller) *MQTTBridge {
+	nodeID := config.NodeID
+	if nodeID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "fan-controller"
+		}
+		nodeID = mqttObjectID(hostname)
+	}
+	clientID := config.ClientID
+	if clientID == "" {
+		clientID = "fan-controller-" + nodeID
+	}
+
+	b := &MQTTBridge{
+		config:     config,
+		controller: controller,
+		nodeID:     nodeID,
+		base:       strings.TrimRight(config.TopicPrefix, "/") + "/" + nodeID,
+		announced:  make(map[string]bool),
+	}
+
+	options := mqtt.NewClientOptions().
+		AddBroker(config.Broker).
+		SetClientID(clientID).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(mqttRetryInterval).
+		SetConnectTimeout(mqttTimeout).
+		SetWill(b.topic("status"), "offline", 1, true).
+		SetOrderMatters(false).
+		SetOnConnectHandler(b.onConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			slog.Warn("MQTT connection lost", "broker", config.Broker, "error", err)
+			RecordError("mqtt")
+		})
+	b.client = mqtt.NewClient(options)
+	return b
+}
+
+// Connect starts the connection; if the broker is not reachable within the
+// timeout the bridge keeps retrying in the background
+func (b *MQTTBridge) Connect() {
+	token := b.client.Connect()
+	if !token.WaitTimeout(mqttTimeout) {
+		slog.Warn("MQTT broker not reachable yet, retrying in the background", "broker", b.config.Broker)
+		return
+	}
+	if err := token.Error(); err != nil {
+		slog.Warn("Failed to connect to MQTT broker", "broker", b.config.Broker, "error", err)
+		RecordError("mqtt")
+	}
+}
+
+// Close marks the device offline and disconnects
+func (b *MQTTBridge) Close() {
+	if b.client.IsConnected() {
+		b.client.Publish(b.topic("status"), 1, true, "offline").WaitTimeout(mqttTimeout)
+	}
+	b.client.Disconnect(uint(mqttTimeout / time.Millisecond))
+}
+
+// onConnect runs on every (re)connection: subscribe to commands, announce the
+// entities and mark the device online
+func (b *MQTTBridge) onConnect(client mqtt.Client) {
+	slog.Info("Connected to MQTT broker", "broker", b.config.Broker, "topic", b.base)
+
+	if !b.config.ReadOnly {
+		commands := map[string]func(string) error{
+			"mode/set":     b.commandMode,
+			"duty/set":     b.commandDuty,
+			"setpoint/set": b.commandSetpoint,
+		}
+		for suffix, handler := range commands {
+			topic, handler := b.topic(suffix), handler
+			token := client.Subscribe(topic, 1, func(_ mqtt.Client, message mqtt.Message) {
+				b.handleCommand(message, handler)
+			})
+			if token.WaitTimeout(mqttTimeout) && token.Error() != nil {
+				slog.Warn("Failed to subscribe to MQTT command topic", "topic", topic, "error", token.Error())
+				RecordError("mqtt")
+			}
+		}
+	}
+
+	// The broker may have lost retained discovery messages, so announce everything again
+	b.mu.Lock()
+	b.announced = make(map[string]bool)
+	b.mu.Unlock()
+	for topic, payload := range b.deviceEntities() {
+		b.publishJSON(topic, payload, true)
+	}
+	b.client.Publish(b.topic("status"), 1, true, "online")
+}
+
+// Publish sends the iteration summary, per-disk temperatures and fan speeds,
+// announcing disks and fans not seen before
+func (b *MQTTBridge) Publish(summary MetricsSummary, readings TempReadings, fanSpeeds map[string]int) {
+	if !b.client.IsConnectionOpen() {
+		return
+	}
+
+	disks := make(map[string]int, len(readings.Disks)+len(readings.Flash))
+	for _, temps := range []map[string]int{readings.Disks, readings.Flash} {
+		for device, temp := range temps {
+			disks[readings.Identity(device).Serial] = temp
+		}
+	}
+
+	b.mu.Lock()
+	var discovery []string
+	for serial := range disks {
+		topic := b.discoveryTopic("sensor", "disk_"+mqttObjectID(serial))
+		if !b.announced[topic] {
+			b.announced[topic] = true
+			discovery = append(discovery, serial)
+		}
+	}
+	var fans []string
+	for fan := range fanSpeeds {
+		topic := b.discoveryTopic("sensor", "fan_"+mqttObjectID(fan))
+		if !b.announced[topic] {
+			b.announced[topic] = true
+			fans = append(fans, fan)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, serial := range discovery {
+		b.publishJSON(b.discoveryTopic("sensor", "disk_"+mqttObjectID(serial)), b.diskEntity(serial), true)
+	}
+	for _, fan := range fans {
+		b.publishJSON(b.discoveryTopic("sensor", "fan_"+mqttObjectID(fan)), b.fanEntity(fan), true)
+	}
+
+	b.publishJSON(b.topic("disks"), disks, false)
+	b.publishJSON(b.topic("fans"), fanSpeeds, false)
+	b.publishState(mqttState{
+		CPUTemp:     summary.CPUTemp,
+		MaxDiskTemp: summary.MaxDiskTemp,
+		AvgDiskTemp: math.Round(summary.AvgDiskTemp*100) / 100,
+		FanDuty:     summary.FanDuty,
+		PIDError:    math.Round(summary.PIDError*100) / 100,
+		Emergency:   summary.Emergency,
+		LoopTime:    summary.LoopTime.Seconds(),
+	})
+}
+
+// publishState fills in the current controls and publishes state
+func (b *MQTTBridge) publishState(state mqttState) {
+	controls := b.controller.Controls()
+	state.Mode, state.ManualDuty, state.Setpoint = controls.Mode, controls.ManualDuty, controls.Setpoint
+
+	b.mu.Lock()
+	b.last = state
+	b.mu.Unlock()
+	b.publishJSON(b.topic("state"), state, false)
+}
+
+// publishJSON encodes payload and publishes it without waiting for delivery
+func (b *MQTTBridge) publishJSON(topic string, payload any, retained bool) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		slog.Warn("Failed to encode MQTT payload", "topic", topic, "error", err)
+		return
+	}
+	b.client.Publish(topic, 1, retained, data)
+}
+
+// handleCommand applies one command and republishes the state so Home
+// Assistant reflects it without waiting for the next poll
+func (b *MQTTBridge) handleCommand(message mqtt.Message, handler func(string) error) {
+	payload := strings.TrimSpace(string(message.Payload()))
+	if err := handler(payload); err != nil {
+		slog.Warn("Rejected MQTT command", "topic", message.Topic(), "payload", payload, "error", err)
+		return
+	}
+	b.mu.Lock()
+	last := b.last
+	b.mu.Unlock()
+	b.publishState(last)
+}
+
+// commandMode handles <base>/mode/set: auto or manual
+func (b *MQTTBridge) commandMode(payload string) error {
+	return b.controller.SetMode(strings.ToLower(payload))
+}
+
+// commandDuty handles <base>/duty/set: the manual duty in percent
+func (b *MQTTBridge) commandDuty(payload string) error {
+	duty, err := strconv.ParseFloat(payload, 64)
+	if err != nil {
+		return fmt.Errorf("invalid duty %q", payload)
+	}
+	return b.controller.SetManualDuty(int(math.Round(duty)))
+}
+
+// commandSetpoint handles <base>/setpoint/set: the PID target in °C
+func (b *MQTTBridge) commandSetpoint(payload string) error {
+	setpoint, err := strconv.ParseFloat(payload, 64)
+	if err != nil {
+		return fmt.Errorf("invalid setpoint %q", payload)
+	}
+	return b.controller.SetSetpoint(setpoint)
+}
+
+// topic returns a state or command topic under the device's base topic
+func (b *MQTTBridge) topic(suffix string) string {
+	return b.base + "/" + suffix
+}
+
+// discoveryTopic returns the Home Assistant discovery config topic of one entity
+func (b *MQTTBridge) discoveryTopic(component, objectID string) string {
+	return fmt.Sprintf("%s/%s/%s/%s/config", b.config.DiscoveryPrefix, component, b.nodeID, objectID)
+}
+
+// entity returns the discovery fields shared by every entity of the device
+func (b *MQTTBridge) entity(objectID, name string) map[string]any {
+	return map[string]any{
+		"name":               name,
+		"unique_id":          b.nodeID + "_" + objectID,
+		"object_id":          b.nodeID + "_" + objectID,
+		"availability_topic": b.topic("status"),
+		"device": map[string]any{
+			"identifiers":  []string{"fan-controller_" + b.nodeID},
+			"name":         "Fan controller " + b.nodeID,
+			"manufacturer": "fan-controller",
+			"model":        "PID fan controller",
+		},
+	}
+}
+
+// stateSensor returns a sensor reading one field of <base>/state
+func (b *MQTTBridge) stateSensor(objectID, name, field, unit, deviceClass string) map[string]any {
+	entity := b.entity(objectID, name)
+	entity["state_topic"] = b.topic("state")
+	entity["value_template"] = fmt.Sprintf("{{ value_json.%s }}", field)
+	if unit != "" {
+		entity["unit_of_measurement"] = unit
+		entity["state_class"] = "measurement"
+	}
+	if deviceClass != "" {
+		entity["device_class"] = deviceClass
+	}
+	return entity
+}
+
+// deviceEntities returns the discovery configs of the fixed entities by topic
+func (b *MQTTBridge) deviceEntities() map[string]map[string]any {
+	entities := map[string]map[string]any{
+		b.discoveryTopic("sensor", "cpu_temp"):      b.stateSensor("cpu_temp", "CPU temperature", "cpu_temp", "°C", "temperature"),
+		b.discoveryTopic("sensor", "max_disk_temp"): b.stateSensor("max_disk_temp", "Max disk temperature", "max_disk_temp", "°C", "temperature"),
+		b.discoveryTopic("sensor", "avg_disk_temp"): b.stateSensor("avg_disk_temp", "Control temperature", "avg_disk_temp", "°C", "temperature"),
+		b.discoveryTopic("sensor", "fan_duty"):      b.stateSensor("fan_duty", "Fan duty", "fan_duty", "%", ""),
+		b.discoveryTopic("sensor", "pid_error"):     b.stateSensor("pid_error", "PID error", "pid_error", "°C", ""),
+		b.discoveryTopic("sensor", "emergency"):     b.stateSensor("emergency", "Emergency reason", "emergency or 'none'", "", ""),
+	}
+
+	emergency := b.entity("emergency_active", "Emergency")
+	emergency["state_topic"] = b.topic("state")
+	emergency["value_template"] = "{{ 'ON' if value_json.emergency else 'OFF' }}"
+	emergency["device_class"] = "problem"
+	entities[b.discoveryTopic("binary_sensor", "emergency_active")] = emergency
+
+	if b.config.ReadOnly {
+		return entities
+	}
+
+	mode := b.entity("mode", "Mode")
+	mode["state_topic"] = b.topic("state")
+	mode["value_template"] = "{{ value_json.mode }}"
+	mode["command_topic"] = b.topic("mode/set")
+	mode["options"] = []string{ControlModeAuto, ControlModeManual}
+	entities[b.discoveryTopic("select", "mode")] = mode
+
+	duty := b.entity("manual_duty", "Manual duty")
+	duty["state_topic"] = b.topic("state")
+	duty["value_template"] = "{{ value_json.manual_duty }}"
+	duty["command_topic"] = b.topic("duty/set")
+	duty["min"], duty["max"], duty["step"] = 0, 100, 1
+	duty["unit_of_measurement"] = "%"
+	entities[b.discoveryTopic("number", "manual_duty")] = duty
+
+	setpoint := b.entity("setpoint", "Setpoint")
+	setpoint["state_topic"] = b.topic("state")
+	setpoint["value_template"] = "{{ value_json.setpoint }}"
+	setpoint["command_topic"] = b.topic("setpoint/set")
+	setpoint["min"], setpoint["max"], setpoint["step"] = 20, b.controller.config.Temperature.MaxHDD-0.5, 0.5
+	setpoint["unit_of_measurement"] = "°C"
+	entities[b.discoveryTopic("number", "setpoint")] = setpoint
+
+	return entities
+}
+
+// diskEntity returns the discovery config of one disk's temperature, keyed by serial
+func (b *MQTTBridge) diskEntity(serial string) map[string]any {
+	entity := b.entity("disk_"+mqttObjectID(serial), "Disk "+serial)
+	entity["state_topic"] = b.topic("disks")
+	entity["value_template"] = fmt.Sprintf("{{ value_json[%q] }}", serial)
+	entity["unit_of_measurement"] = "°C"
+	entity["device_class"] = "temperature"
+	entity["state_class"] = "measurement"
+	return entity
+}
+
+// fanEntity returns the discovery config of one fan's speed
+func (b *MQTTBridge) fanEntity(fan string) map[string]any {
+	entity := b.entity("fan_"+mqttObjectID(fan), fan)
+	entity["state_topic"] = b.topic("fans")
+	entity["value_template"] = fmt.Sprintf("{{ value_json[%q] }}", fan)
+	entity["unit_of_measurement"] = "RPM"
+	entity["state_class"] = "measurement"
+	return entity
+}
+
+// mqttObjectID replaces characters Home Assistant does not accept in IDs
+func mqttObjectID(name string) string {
+	return mqttObjectIDPattern.ReplaceAllString(name, "_")
+}
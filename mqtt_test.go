@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBrokerConn is one client connection to the fake broker
+type fakeBrokerConn struct {
+	mu      sync.Mutex
+	conn    net.Conn
+	filters []string
+}
+
+// write sends one packet with its fixed header
+func (c *fakeBrokerConn) write(header byte, body []byte) {
+	packet := []byte{header}
+	length := len(body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if length == 0 {
+			break
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.Write(append(packet, body...))
+}
+
+// fakeBroker is a minimal MQTT 3.1.1 broker: it keeps retained messages and
+// the last payload of every topic, and routes publishes to subscribers at QoS 0
+type fakeBroker struct {
+	mu       sync.Mutex
+	addr     string
+	conns    []*fakeBrokerConn
+	retained map[string][]byte
+	last     map[string][]byte
+}
+
+// startFakeBroker listens on a local port until the test ends
+func startFakeBroker(t *testing.T) *fakeBroker {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	broker := &fakeBroker{
+		addr:     "tcp://" + listener.Addr().String(),
+		retained: make(map[string][]byte),
+		last:     make(map[string][]byte),
+	}
+	t.Cleanup(func() {
+		listener.Close()
+		broker.mu.Lock()
+		defer broker.mu.Unlock()
+		for _, conn := range broker.conns {
+			conn.conn.Close()
+		}
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			client := &fakeBrokerConn{conn: conn}
+			broker.mu.Lock()
+			broker.conns = append(broker.conns, client)
+			broker.mu.Unlock()
+			go broker.serve(client)
+		}
+	}()
+	return broker
+}
+
+// serve handles the packets of one connection until it closes
+func (b *fakeBroker) serve(client *fakeBrokerConn) {
+	reader := bufio.NewReader(client.conn)
+	for {
+		header, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		length, multiplier := 0, 1
+		for {
+			digit, err := reader.ReadByte()
+			if err != nil {
+				return
+			}
+			length += int(digit&0x7f) * multiplier
+			multiplier *= 128
+			if digit&0x80 == 0 {
+				break
+			}
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case 1: // CONNECT
+			client.write(0x20, []byte{0, 0})
+		case 3: // PUBLISH
+			qos := (header >> 1) & 0x03
+			topicLength := int(binary.BigEndian.Uint16(body))
+			topic := string(body[2 : 2+topicLength])
+			payload := body[2+topicLength:]
+			if qos > 0 {
+				client.write(0x40, payload[:2])
+				payload = payload[2:]
+			}
+			b.route(topic, payload, header&0x01 == 1)
+		case 8: // SUBSCRIBE
+			granted := []byte{body[0], body[1]}
+			var filters []string
+			for rest := body[2:]; len(rest) > 2; {
+				filterLength := int(binary.BigEndian.Uint16(rest))
+				filters = append(filters, string(rest[2:2+filterLength]))
+				rest = rest[3+filterLength:]
+				granted = append(granted, 0)
+			}
+			client.mu.Lock()
+			client.filters = append(client.filters, filters...)
+			client.mu.Unlock()
+			client.write(0x90, granted)
+		case 10: // UNSUBSCRIBE
+			client.write(0xb0, body[:2])
+		case 12: // PINGREQ
+			client.write(0xd0, nil)
+		case 14: // DISCONNECT
+			client.conn.Close()
+			return
+		}
+	}
+}
+
+// route records a message and delivers it to every matching subscription
+func (b *fakeBroker) route(topic string, payload []byte, retain bool) {
+	b.mu.Lock()
+	b.last[topic] = payload
+	if retain {
+		b.retained[topic] = payload
+	}
+	conns := append([]*fakeBrokerConn(nil), b.conns...)
+	b.mu.Unlock()
+
+	body := binary.BigEndian.AppendUint16(nil, uint16(len(topic)))
+	body = append(append(body, topic...), payload...)
+	for _, conn := range conns {
+		conn.mu.Lock()
+		filters := conn.filters
+		conn.mu.Unlock()
+		for _, filter := range filters {
+			if topicMatches(filter, topic) {
+				conn.write(0x30, body)
+				break
+			}
+		}
+	}
+}
+
+// publish sends a message to the subscribers as another client would
+func (b *fakeBroker) publish(topic, payload string) {
+	b.route(topic, []byte(payload), false)
+}
+
+// waitFor waits until topic has been published and returns its last payload
+func (b *fakeBroker) waitFor(t *testing.T, topic string, match func(string) bool) string {
+	t.Helper()
+	var payload string
+	require.Eventually(t, func() bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		data, ok := b.last[topic]
+		payload = string(data)
+		return ok && (match == nil || match(payload))
+	}, 5*time.Second, 10*time.Millisecond, "no message on %s", topic)
+	return payload
+}
+
+// retainedTopics returns the topics holding a retained message
+func (b *fakeBroker) retainedTopics() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return sortedKeys(b.retained)
+}
+
+// topicMatches reports whether topic matches a filter with + and # wildcards
+func topicMatches(filter, topic string) bool {
+	filterLevels, topicLevels := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) || (level != "+" && level != topicLevels[i]) {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
+
+// newTestMQTTBridge connects a bridge for a test controller to a fake broker
+func newTestMQTTBridge(t *testing.T, readOnly bool, temps TempSource, fans FanActuator) (*MQTTBridge, *Controller, *fakeBroker) {
+	t.Helper()
+	broker := startFakeBroker(t)
+	controller := newTestController(t, temps, fans)
+	config := MQTTConfig{
+		Enabled:         true,
+		Broker:          broker.addr,
+		TopicPrefix:     "fan-controller",
+		DiscoveryPrefix: "homeassistant",
+		NodeID:          "nas",
+		ReadOnly:        readOnly,
+	}
+
+	bridge := NewMQTTBridge(config, controller)
+	bridge.Connect()
+	t.Cleanup(bridge.Close)
+	controller.SetMQTT(bridge)
+	broker.waitFor(t, "fan-controller/nas/status", func(payload string) bool { return payload == "online" })
+	return bridge, controller, broker
+}
+
+// TestMQTTBridge_Discovery tests the retained discovery configs and the published state
+func TestMQTTBridge_Discovery(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{
+		diskTemps:  map[string]int{"sda": 39},
+		identities: map[string]DiskIdentity{"sda": {Device: "sda", Serial: "ZL2A-01"}},
+		cpuTemp:    50,
+	}
+	fans := &fakeFanActuator{speeds: map[string]int{"FAN1": 1500}}
+	_, controller, broker := newTestMQTTBridge(t, false, temps, fans)
+
+	// Act
+	summary, err := controller.Step(context.Background())
+	require.NoError(t, err)
+
+	// Assert
+	var state mqttState
+	require.NoError(t, json.Unmarshal([]byte(broker.waitFor(t, "fan-controller/nas/state", nil)), &state))
+	assert.Equal(t, summary.FanDuty, state.FanDuty)
+	assert.Equal(t, 39, state.MaxDiskTemp)
+	assert.Equal(t, ControlModeAuto, state.Mode)
+	assert.Equal(t, 38.0, state.Setpoint)
+	assert.JSONEq(t, `{"ZL2A-01": 39}`, broker.waitFor(t, "fan-controller/nas/disks", nil))
+	assert.JSONEq(t, `{"FAN1": 1500}`, broker.waitFor(t, "fan-controller/nas/fans", nil))
+
+	disk := broker.waitFor(t, "homeassistant/sensor/nas/disk_ZL2A-01/config", nil)
+	assert.Contains(t, disk, `"value_template":"{{ value_json[\"ZL2A-01\"] }}"`)
+	assert.Contains(t, disk, `"availability_topic":"fan-controller/nas/status"`)
+
+	var setpoint map[string]any
+	require.NoError(t, json.Unmarshal([]byte(broker.waitFor(t, "homeassistant/number/nas/setpoint/config", nil)), &setpoint))
+	assert.Equal(t, "fan-controller/nas/setpoint/set", setpoint["command_topic"])
+	assert.Equal(t, "nas_setpoint", setpoint["unique_id"])
+	assert.Equal(t, 44.5, setpoint["max"])
+
+	assert.Subset(t, broker.retainedTopics(), []string{
+		"fan-controller/nas/status",
+		"homeassistant/binary_sensor/nas/emergency_active/config",
+		"homeassistant/select/nas/mode/config",
+		"homeassistant/sensor/nas/cpu_temp/config",
+		"homeassistant/sensor/nas/fan_FAN1/config",
+	})
+}
+
+// TestMQTTBridge_Commands tests that commands change the controls and republish the state
+func TestMQTTBridge_Commands(t *testing.T) {
+	tests := []struct {
+		name    string
+		topic   string
+		payload string
+		want    Controls
+	}{
+		{"manual mode", "mode/set", "MANUAL", Controls{Mode: ControlModeManual, ManualDuty: 50, Setpoint: 38}},
+		{"duty", "duty/set", "72.6", Controls{Mode: ControlModeAuto, ManualDuty: 73, Setpoint: 38}},
+		{"setpoint", "setpoint/set", "36.5", Controls{Mode: ControlModeAuto, ManualDuty: 50, Setpoint: 36.5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			_, controller, broker := newTestMQTTBridge(t, false, &fakeTempSource{}, &fakeFanActuator{})
+
+			// Act
+			broker.publish("fan-controller/nas/"+tt.topic, tt.payload)
+
+			// Assert
+			require.Eventually(t, func() bool { return controller.Controls() == tt.want }, 5*time.Second, 10*time.Millisecond)
+			broker.waitFor(t, "fan-controller/nas/state", func(payload string) bool {
+				var state mqttState
+				return json.Unmarshal([]byte(payload), &state) == nil &&
+					state.Mode == tt.want.Mode && state.ManualDuty == tt.want.ManualDuty && state.Setpoint == tt.want.Setpoint
+			})
+		})
+	}
+}
+
+// TestMQTTBridge_InvalidCommand tests that a rejected command leaves the controls unchanged
+func TestMQTTBridge_InvalidCommand(t *testing.T) {
+	// Arrange
+	_, controller, broker := newTestMQTTBridge(t, false, &fakeTempSource{}, &fakeFanActuator{})
+	before := controller.Controls()
+
+	// Act
+	broker.publish("fan-controller/nas/duty/set", "full")
+	broker.publish("fan-controller/nas/setpoint/set", "60")
+	broker.publish("fan-controller/nas/mode/set", "manual")
+
+	// Assert: the valid command after the rejected ones proves they were handled
+	require.Eventually(t, func() bool { return controller.Controls().Mode == ControlModeManual }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, before.ManualDuty, controller.Controls().ManualDuty)
+	assert.Equal(t, before.Setpoint, controller.Controls().Setpoint)
+}
+
+// TestMQTTBridge_ReadOnly tests that read_only announces no controls and ignores commands
+func TestMQTTBridge_ReadOnly(t *testing.T) {
+	// Arrange
+	_, controller, broker := newTestMQTTBridge(t, true, &fakeTempSource{cpuTemp: 50}, &fakeFanActuator{})
+
+	// Act
+	broker.publish("fan-controller/nas/mode/set", "manual")
+	_, err := controller.Step(context.Background())
+	require.NoError(t, err)
+	broker.waitFor(t, "fan-controller/nas/state", nil)
+
+	// Assert
+	assert.Equal(t, ControlModeAuto, controller.Controls().Mode)
+	assert.Contains(t, broker.retainedTopics(), "homeassistant/sensor/nas/fan_duty/config")
+	assert.NotContains(t, broker.retainedTopics(), "homeassistant/select/nas/mode/config")
+	assert.NotContains(t, broker.retainedTopics(), "homeassistant/number/nas/manual_duty/config")
+}
+
+// TestMQTTBridge_Close tests that closing marks the device offline
+func TestMQTTBridge_Close(t *testing.T) {
+	// Arrange
+	bridge, _, broker := newTestMQTTBridge(t, false, &fakeTempSource{}, &fakeFanActuator{})
+
+	// Act
+	bridge.Close()
+
+	// Assert
+	assert.Equal(t, "offline", broker.waitFor(t, "fan-controller/nas/status", func(payload string) bool { return payload == "offline" }))
+}
+
+// TestMQTTObjectID tests that names are reduced to characters Home Assistant accepts
+func TestMQTTObjectID(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"FAN1", "FAN1"},
+		{"ZL2A-01_x", "ZL2A-01_x"},
+		{"5000/fan 0", "5000_fan_0"},
+		{"nas.lan", "nas_lan"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, mqttObjectID(tt.name))
+		})
+	}
+}
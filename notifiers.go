@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pushover message API
+const pushoverAPIURL = "https://api.pushover.net/1/messages.json"
+
+// NewNotifiers creates a notifier for every target configured under notify
+func NewNotifiers(config NotifyConfig) []Notifier {
+	client := &http.Client{Timeout: notifyTimeout}
+	var notifiers []Notifier
+	if config.Webhook.URL != "" {
+		notifiers = append(notifiers, &webhookNotifier{config: config.Webhook, client: client})
+	}
+	if config.Ntfy.Topic != "" {
+		notifiers = append(notifiers, &ntfyNotifier{config: config.Ntfy, client: client})
+	}
+	if config.Pushover.Token != "" {
+		notifiers = append(notifiers, &pushoverNotifier{config: config.Pushover, client: client, endpoint: pushoverAPIURL})
+	}
+	if config.SMTP.Host != "" {
+		notifiers = append(notifiers, &smtpNotifier{config: config.SMTP})
+	}
+	return notifiers
+}
+
+// postRequest sends an HTTP request and fails on any non-2xx status
+func postRequest(client *http.Client, request *http.Request) error {
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", request.URL.Host, response.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// webhookNotifier posts the notification as JSON to a URL
+type webhookNotifier struct {
+	config WebhookConfig
+	client *http.Client
+}
+
+// Name returns the notifier label
+func (n *webhookNotifier) Name() string {
+	return "webhook"
+}
+
+// Send posts the notification as a JSON object
+func (n *webhookNotifier) Send(ctx context.Context, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, n.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid webhook request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range n.config.Headers {
+		request.Header.Set(name, value)
+	}
+	return postRequest(n.client, request)
+}
+
+// ntfyNotifier publishes to an ntfy topic
+type ntfyNotifier struct {
+	config NtfyConfig
+	client *http.Client
+}
+
+// Name returns the notifier label
+func (n *ntfyNotifier) Name() string {
+	return "ntfy"
+}
+
+// Send publishes the message with its title, priority and tags as headers
+func (n *ntfyNotifier) Send(ctx context.Context, notification Notification) error {
+	topicURL := strings.TrimRight(n.config.Server, "/") + "/" + url.PathEscape(n.config.Topic)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, topicURL, strings.NewReader(notification.Message))
+	if err != nil {
+		return fmt.Errorf("invalid ntfy request: %w", err)
+	}
+	request.Header.Set("Title", notification.Title)
+	if notification.Resolved {
+		request.Header.Set("Priority", "default")
+		request.Header.Set("Tags", "white_check_mark")
+	} else {
+		request.Header.Set("Priority", "urgent")
+		request.Header.Set("Tags", "rotating_light")
+	}
+	if n.config.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+n.config.Token)
+	}
+	return postRequest(n.client, request)
+}
+
+// pushoverNotifier sends a Pushover message
+type pushoverNotifier struct {
+	config   PushoverConfig
+	client   *http.Client
+	endpoint string // Message API URL
+}
+
+// Name returns the notifier label
+func (n *pushoverNotifier) Name() string {
+	return "pushover"
+}
+
+// Send posts the message form; alerts are high priority, recoveries normal
+func (n *pushoverNotifier) Send(ctx context.Context, notification Notification) error {
+	priority := 1
+	if notification.Resolved {
+		priority = 0
+	}
+	form := url.Values{
+		"token":     {n.config.Token},
+		"user":      {n.config.User},
+		"title":     {notification.Title},
+		"message":   {notification.Message},
+		"priority":  {strconv.Itoa(priority)},
+		"timestamp": {strconv.FormatInt(notification.Time.Unix(), 10)},
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, n.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("invalid pushover request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return postRequest(n.client, request)
+}
+
+// smtpNotifier sends an email; STARTTLS is used when the server offers it
+type smtpNotifier struct {
+	config SMTPConfig
+}
+
+// Name returns the notifier label
+func (n *smtpNotifier) Name() string {
+	return "smtp"
+}
+
+// Send mails the notification to every recipient
+func (n *smtpNotifier) Send(ctx context.Context, notification Notification) error {
+	var auth smtp.Auth
+	if n.config.Username != "" {
+		auth = smtp.PlainAuth("", n.config.Username, n.config.Password, n.config.Host)
+	}
+
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		n.config.From, strings.Join(n.config.To, ", "), notification.Title,
+		notification.Time.Format(time.RFC1123Z), notification.Message)
+
+	// smtp.SendMail takes no context, so run it against the deadline
+	addr := net.JoinHostPort(n.config.Host, strconv.Itoa(n.config.Port))
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, n.config.From, n.config.To, []byte(message))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("failed to send mail via %s: %w", addr, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to send mail via %s: %w", addr, ctx.Err())
+	}
+}
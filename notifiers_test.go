@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testNotification is a firing emergency notification
+var testNotification = Notification{
+	Event:   EventEmergency,
+	Key:     "emergency/hdd_temp",
+	Host:    "nas",
+	Title:   "[nas] Emergency: hdd_temp",
+	Message: "Fans forced up, emergency reason hdd_temp",
+	Time:    time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+}
+
+// capturedRequest is the part of an HTTP request the notifier tests check
+type capturedRequest struct {
+	method string
+	path   string
+	header http.Header
+	body   string
+}
+
+// newCaptureServer starts an httptest server that records one request and replies with status
+func newCaptureServer(t *testing.T, status int) (*httptest.Server, chan capturedRequest) {
+	t.Helper()
+	requests := make(chan capturedRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- capturedRequest{method: r.Method, path: r.URL.Path, header: r.Header, body: string(body)}
+		w.WriteHeader(status)
+		fmt.Fprint(w, "ok")
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+// TestWebhookNotifier_Send tests the JSON body and custom headers
+func TestWebhookNotifier_Send(t *testing.T) {
+	// Arrange
+	server, requests := newCaptureServer(t, http.StatusOK)
+	notifier := &webhookNotifier{
+		config: WebhookConfig{URL: server.URL + "/hook", Headers: map[string]string{"Authorization": "Bearer secret"}},
+		client: server.Client(),
+	}
+
+	// Act
+	err := notifier.Send(context.Background(), testNotification)
+
+	// Assert
+	require.NoError(t, err)
+	request := <-requests
+	assert.Equal(t, http.MethodPost, request.method)
+	assert.Equal(t, "/hook", request.path)
+	assert.Equal(t, "application/json", request.header.Get("Content-Type"))
+	assert.Equal(t, "Bearer secret", request.header.Get("Authorization"))
+	var body Notification
+	require.NoError(t, json.Unmarshal([]byte(request.body), &body))
+	assert.Equal(t, testNotification, body)
+}
+
+// TestWebhookNotifier_ErrorStatus tests that a non-2xx reply fails the send
+func TestWebhookNotifier_ErrorStatus(t *testing.T) {
+	// Arrange
+	server, _ := newCaptureServer(t, http.StatusBadGateway)
+	notifier := &webhookNotifier{config: WebhookConfig{URL: server.URL}, client: server.Client()}
+
+	// Act
+	err := notifier.Send(context.Background(), testNotification)
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "502 Bad Gateway")
+}
+
+// TestNtfyNotifier_Send tests the topic path and the priority of alerts and recoveries
+func TestNtfyNotifier_Send(t *testing.T) {
+	tests := []struct {
+		name     string
+		resolved bool
+		priority string
+		tags     string
+	}{
+		{"alert", false, "urgent", "rotating_light"},
+		{"recovery", true, "default", "white_check_mark"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			server, requests := newCaptureServer(t, http.StatusOK)
+			notifier := &ntfyNotifier{config: NtfyConfig{Server: server.URL + "/", Topic: "nas-fans", Token: "tk_1"}, client: server.Client()}
+			notification := testNotification
+			notification.Resolved = tt.resolved
+
+			// Act
+			err := notifier.Send(context.Background(), notification)
+
+			// Assert
+			require.NoError(t, err)
+			request := <-requests
+			assert.Equal(t, "/nas-fans", request.path)
+			assert.Equal(t, testNotification.Message, request.body)
+			assert.Equal(t, testNotification.Title, request.header.Get("Title"))
+			assert.Equal(t, tt.priority, request.header.Get("Priority"))
+			assert.Equal(t, tt.tags, request.header.Get("Tags"))
+			assert.Equal(t, "Bearer tk_1", request.header.Get("Authorization"))
+		})
+	}
+}
+
+// TestPushoverNotifier_Send tests the message form
+func TestPushoverNotifier_Send(t *testing.T) {
+	// Arrange
+	server, requests := newCaptureServer(t, http.StatusOK)
+	notifier := &pushoverNotifier{
+		config:   PushoverConfig{Token: "app", User: "user"},
+		client:   server.Client(),
+		endpoint: server.URL + "/1/messages.json",
+	}
+
+	// Act
+	err := notifier.Send(context.Background(), testNotification)
+
+	// Assert
+	require.NoError(t, err)
+	request := <-requests
+	assert.Equal(t, "/1/messages.json", request.path)
+	assert.Equal(t, "application/x-www-form-urlencoded", request.header.Get("Content-Type"))
+	for _, field := range []string{"token=app", "user=user", "priority=1", "timestamp=1704110400", "title=%5Bnas%5D+Emergency%3A+hdd_temp"} {
+		assert.Contains(t, request.body, field)
+	}
+}
+
+// startFakeSMTPServer accepts one SMTP session without extensions and returns the message data
+func startFakeSMTPServer(t *testing.T) (string, chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+
+		reply("220 localhost ESMTP")
+		var envelope, data []string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "MAIL"), strings.HasPrefix(command, "RCPT"):
+				envelope = append(envelope, strings.TrimSpace(line))
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data = append(data, line)
+				}
+				reply("250 OK")
+			case command == "QUIT":
+				reply("221 Bye")
+				messages <- strings.Join(envelope, "\n") + "\n" + strings.Join(data, "")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return listener.Addr().String(), messages
+}
+
+// TestSMTPNotifier_Send tests the envelope and headers against a local SMTP stand-in
+func TestSMTPNotifier_Send(t *testing.T) {
+	// Arrange
+	addr, messages := startFakeSMTPServer(t)
+	host, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	notifier := &smtpNotifier{config: SMTPConfig{
+		Host: host,
+		Port: portNumber,
+		From: "fans@nas.lan",
+		To:   []string{"admin@example.com", "oncall@example.com"},
+	}}
+
+	// Act
+	err = notifier.Send(context.Background(), testNotification)
+
+	// Assert
+	require.NoError(t, err)
+	message := <-messages
+	assert.Contains(t, message, "MAIL FROM:<fans@nas.lan>")
+	assert.Contains(t, message, "RCPT TO:<oncall@example.com>")
+	assert.Contains(t, message, "Subject: [nas] Emergency: hdd_temp\r\n")
+	assert.Contains(t, message, "To: admin@example.com, oncall@example.com\r\n")
+	assert.Contains(t, message, testNotification.Message)
+}
+
+// TestNewNotifiers tests that only configured targets are created
+func TestNewNotifiers(t *testing.T) {
+	// Arrange
+	config := NotifyConfig{
+		Ntfy:     NtfyConfig{Server: "https://ntfy.sh", Topic: "nas-fans"},
+		Pushover: PushoverConfig{Token: "app", User: "user"},
+	}
+
+	// Act
+	notifiers := NewNotifiers(config)
+
+	// Assert
+	names := make([]string, 0, len(notifiers))
+	for _, notifier := range notifiers {
+		names = append(names, notifier.Name())
+	}
+	assert.Equal(t, []string{"ntfy", "pushover"}, names)
+	assert.Empty(t, NewNotifiers(NotifyConfig{}))
+}
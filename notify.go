@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Notification events, selectable with notify.events
+const (
+	EventEmergency   = "emergency"     // Emergency mode entered for a temperature reason
+	EventIPMIFailure = "ipmi_failure"  // Fan commands keep failing, emergency forced
+	EventFanStall    = "fan_stall"     // A fan reads below notify.stall_rpm
+	EventSensorLoss  = "sensor_loss"   // A disk or the whole temperature read failed
+	EventDiskOverMax = "disk_over_max" // A disk is above its max_hdd
+)
+
+// notificationEvents lists every event in the order they are documented
+var notificationEvents = []string{EventEmergency, EventIPMIFailure, EventFanStall, EventSensorLoss, EventDiskOverMax}
+
+// Max time to deliver one notification; sends never block the control loop
+const notifyTimeout = 10 * time.Second
+
+// Groups of alerts the controller reports each poll; an alert only clears when
+// its own group is observed again without it
+const (
+	AlertGroupEmergency = "emergency"
+	AlertGroupReads     = "reads"   // Whole temperature read failed; kept apart so per-disk alerts survive it
+	AlertGroupSensors   = "sensors" // Per-disk read failures
+	AlertGroupDisks     = "disks"
+	AlertGroupFans      = "fans"
+)
+
+// Alert is a condition that is active in the current poll
+type Alert struct {
+	Key     string // Identifies the condition across polls, e.g. fan_stall/FAN1
+	Event   string
+	Title   string
+	Message string
+}
+
+// Notification is one message sent to the notifiers, either when an alert
+// becomes active or when it clears
+type Notification struct {
+	Event    string    `json:"event"`
+	Key      string    `json:"key"`
+	Host     string    `json:"host"`
+	Title    string    `json:"title"`
+	Message  string    `json:"message"`
+	Resolved bool      `json:"resolved"` // The alert cleared
+	Time     time.Time `json:"time"`
+}
+
+// Notifier delivers notifications to one target
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, notification Notification) error
+}
+
+// activeAlert is an alert seen in the last observation of its group
+type activeAlert struct {
+	Alert
+	group    string
+	since    time.Time
+	notified bool // The firing notification was sent, so the recovery will be
+}
+
+// Alerter turns the alerts of each poll into notifications on state changes.
+// A key notifies at most once per rate_limit; an alert suppressed by the limit
+// is sent once the limit expires if still active, and its recovery only follows
+// a sent alert. Observe is called from the control loop only
+type Alerter struct {
+	notifiers []Notifier
+	events    map[string]bool // Enabled events; nil for all
+	rateLimit time.Duration
+	host      string
+
+	active   map[string]*activeAlert // By key
+	lastSent map[string]time.Time    // By key
+	sending  sync.WaitGroup
+}
+
+// NewAlerter creates an alerter sending to notifiers
+func NewAlerter(config NotifyConfig, notifiers []Notifier) *Alerter {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "fan-controller"
+	}
+
+	var events map[string]bool
+	if len(config.Events) > 0 {
+		events = make(map[string]bool, len(config.Events))
+		for _, event := range config.Events {
+			events[event] = true
+		}
+	}
+
+	return &Alerter{
+		notifiers: notifiers,
+		events:    events,
+		rateLimit: config.RateLimit,
+		host:      host,
+		active:    make(map[string]*activeAlert),
+		lastSent:  make(map[string]time.Time),
+	}
+}
+
+// Observe replaces the active alerts of group with alerts, notifying for new
+// alerts and for alerts of the group that are gone
+func (a *Alerter) Observe(group string, alerts []Alert, now time.Time) {
+	current := make(map[string]bool, len(alerts))
+	for _, alert := range alerts {
+		if a.events != nil && !a.events[alert.Event] {
+			continue
+		}
+		current[alert.Key] = true
+		active, ok := a.active[alert.Key]
+		if !ok {
+			active = &activeAlert{Alert: alert, group: group, since: now}
+			a.active[alert.Key] = active
+		}
+		active.Alert = alert // Keep the message current for a delayed send
+		if !active.notified && a.allowed(alert.Key, now) {
+			active.notified = true
+			a.send(Notification{Event: alert.Event, Key: alert.Key, Title: alert.Title, Message: alert.Message, Time: now})
+		}
+	}
+
+	for key, active := range a.active {
+		if active.group != group || current[key] {
+			continue
+		}
+		delete(a.active, key)
+		if !active.notified {
+			slog.Debug("Alert cleared before it was sent", "alert", key)
+			continue
+		}
+		a.lastSent[key] = now
+		a.send(Notification{
+			Event:    active.Event,
+			Key:      key,
+			Title:    "Resolved: " + active.Title,
+			Message:  fmt.Sprintf("Cleared after %s", now.Sub(active.since).Round(time.Second)),
+			Resolved: true,
+			Time:     now,
+		})
+	}
+}
+
+// allowed reports whether key may notify at now, and records the send if so
+func (a *Alerter) allowed(key string, now time.Time) bool {
+	if last, ok := a.lastSent[key]; ok && now.Sub(last) < a.rateLimit {
+		slog.Debug("Alert rate limited", "alert", key, "last_sent", last)
+		return false
+	}
+	a.lastSent[key] = now
+	return true
+}
+
+// send delivers notification to every notifier in the background
+func (a *Alerter) send(notification Notification) {
+	notification.Host = a.host
+	notification.Title = fmt.Sprintf("[%s] %s", a.host, notification.Title)
+	slog.Info("Sending notification", "alert", notification.Key, "resolved", notification.Resolved)
+
+	for _, notifier := range a.notifiers {
+		a.sending.Add(1)
+		go func(notifier Notifier) {
+			defer a.sending.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+			defer cancel()
+			if err := notifier.Send(ctx, notification); err != nil {
+				slog.Warn("Failed to send notification", "notifier", notifier.Name(), "alert", notification.Key, "error", err)
+				RecordError("notify")
+				RecordNotification(notifier.Name(), false)
+				return
+			}
+			RecordNotification(notifier.Name(), true)
+		}(notifier)
+	}
+}
+
+// Wait blocks until notifications in flight are delivered or ctx is done
+func (a *Alerter) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		a.sending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// emergencyAlerts returns the alert for an emergency reason, if any
+func emergencyAlerts(reason string) []Alert {
+	if reason == "" {
+		return nil
+	}
+	alert := Alert{Key: "emergency/" + reason, Event: EventEmergency, Title: "Emergency: " + reason,
+		Message: fmt.Sprintf("Fans forced up, emergency reason %s", reason)}
+	switch reason {
+	case ReasonIPMIFailure:
+		alert.Event = EventIPMIFailure
+		alert.Title = "IPMI failure"
+		alert.Message = fmt.Sprintf("%d fan commands in a row failed, forcing 100%%", maxIPMIFailures)
+	case ReasonSensorFailure, ReasonSensorPartial:
+		alert.Event = EventSensorLoss
+	}
+	return []Alert{alert}
+}
+
+// diskAlerts returns an alert for every disk above its max_hdd
+func diskAlerts(config *Config, readings TempReadings) []Alert {
+	var alerts []Alert
+	for _, device := range sortedKeys(readings.Disks) {
+		identity := readings.Identity(device)
+		policy := ResolveDiskPolicy(config, identity)
+		if temp := readings.Disks[device]; float64(temp) > policy.Max {
+			alerts = append(alerts, Alert{
+				Key:     "disk_over_max/" + identity.Serial,
+				Event:   EventDiskOverMax,
+				Title:   fmt.Sprintf("Disk %s over max temperature", device),
+				Message: fmt.Sprintf("%s (serial %s) at %d°C, max %.0f°C", device, identity.Serial, temp, policy.Max),
+			})
+		}
+	}
+	return alerts
+}
+
+// sensorAlerts returns an alert for every disk whose temperature could not be read
+func sensorAlerts(readings TempReadings) []Alert {
+	alerts := make([]Alert, 0, len(readings.FailedDisks))
+	for _, device := range readings.FailedDisks {
+		identity := readings.Identity(device)
+		alerts = append(alerts, Alert{
+			Key:     "sensor_loss/" + identity.Serial,
+			Event:   EventSensorLoss,
+			Title:   fmt.Sprintf("Disk %s unreadable", device),
+			Message: fmt.Sprintf("Temperature of %s (serial %s) could not be read", device, identity.Serial),
+		})
+	}
+	return alerts
+}
+
+// readFailureAlerts returns the alert for a poll without usable readings
+func readFailureAlerts(err error) []Alert {
+	return []Alert{{
+		Key:     "sensor_loss/all",
+		Event:   EventSensorLoss,
+		Title:   "Temperature read failed",
+		Message: err.Error(),
+	}}
+}
+
+// fanAlerts returns an alert for every IPMI or enclosure fan below stallRPM
+func fanAlerts(fanSpeeds, enclosureFans map[string]int, stallRPM int) []Alert {
+	var alerts []Alert
+	for _, fans := range []map[string]int{fanSpeeds, enclosureFans} {
+		for _, fan := range sortedKeys(fans) {
+			if rpm := fans[fan]; rpm < stallRPM {
+				alerts = append(alerts, Alert{
+					Key:     "fan_stall/" + fan,
+					Event:   EventFanStall,
+					Title:   fmt.Sprintf("Fan %s stalled", fan),
+					Message: fmt.Sprintf("%s at %d RPM, below %d RPM", fan, rpm, stallRPM),
+				})
+			}
+		}
+	}
+	return alerts
+}
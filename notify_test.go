@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNotifier records delivered notifications and returns err
+type fakeNotifier struct {
+	mu   sync.Mutex
+	sent []Notification
+	err  error
+}
+
+// Name returns the notifier label
+func (f *fakeNotifier) Name() string {
+	return "fake"
+}
+
+// Send records the notification
+func (f *fakeNotifier) Send(ctx context.Context, notification Notification) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, notification)
+	return f.err
+}
+
+// keys returns the key of every notification, prefixed with "resolved " for recoveries
+func (f *fakeNotifier) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.sent))
+	for _, notification := range f.sent {
+		if notification.Resolved {
+			keys = append(keys, "resolved "+notification.Key)
+		} else {
+			keys = append(keys, notification.Key)
+		}
+	}
+	return keys
+}
+
+// newTestAlerter creates an alerter sending to a fake notifier
+func newTestAlerter(t *testing.T, config NotifyConfig) (*Alerter, *fakeNotifier) {
+	t.Helper()
+
+	initTestMetrics()
+	silenceLogs(t)
+
+	notifier := &fakeNotifier{}
+	return NewAlerter(config, []Notifier{notifier}), notifier
+}
+
+// waitSent waits for the alerter's notifications in flight
+func waitSent(t *testing.T, alerts *Alerter) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, alerts.Wait(ctx))
+}
+
+// TestAlerter_Observe tests that notifications are sent on transitions only, with a recovery message
+func TestAlerter_Observe(t *testing.T) {
+	// Arrange
+	alerts, notifier := newTestAlerter(t, NotifyConfig{})
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	hdd := emergencyAlerts(ReasonHDDTemp)
+	cpu := emergencyAlerts(ReasonCPUTemp)
+
+	// Act
+	alerts.Observe(AlertGroupEmergency, nil, start)
+	alerts.Observe(AlertGroupEmergency, hdd, start.Add(time.Minute))
+	alerts.Observe(AlertGroupEmergency, hdd, start.Add(2*time.Minute))
+	alerts.Observe(AlertGroupEmergency, cpu, start.Add(3*time.Minute))
+	alerts.Observe(AlertGroupEmergency, nil, start.Add(4*time.Minute))
+	waitSent(t, alerts)
+
+	// Assert
+	assert.ElementsMatch(t, []string{
+		"emergency/hdd_temp", "resolved emergency/hdd_temp", "emergency/cpu_temp", "resolved emergency/cpu_temp",
+	}, notifier.keys())
+	for _, notification := range notifier.sent {
+		if notification.Resolved && notification.Key == "emergency/hdd_temp" {
+			assert.Equal(t, "Cleared after 2m0s", notification.Message)
+			assert.Contains(t, notification.Title, "Resolved: Emergency: hdd_temp")
+			assert.Equal(t, EventEmergency, notification.Event)
+		}
+	}
+}
+
+// TestAlerter_RateLimit tests that a flapping alert is held back and sent once the limit expires
+func TestAlerter_RateLimit(t *testing.T) {
+	// Arrange
+	alerts, notifier := newTestAlerter(t, NotifyConfig{RateLimit: 15 * time.Minute})
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	stall := fanAlerts(map[string]int{"FAN1": 0}, nil, 100)
+
+	// Act
+	alerts.Observe(AlertGroupFans, stall, start)
+	alerts.Observe(AlertGroupFans, nil, start.Add(time.Minute))
+	alerts.Observe(AlertGroupFans, stall, start.Add(2*time.Minute)) // Rate limited
+	alerts.Observe(AlertGroupFans, nil, start.Add(3*time.Minute))   // Its recovery is not sent
+	alerts.Observe(AlertGroupFans, stall, start.Add(4*time.Minute))
+	alerts.Observe(AlertGroupFans, stall, start.Add(20*time.Minute)) // Limit expired, still stalled
+	waitSent(t, alerts)
+
+	// Assert
+	assert.ElementsMatch(t, []string{"fan_stall/FAN1", "resolved fan_stall/FAN1", "fan_stall/FAN1"}, notifier.keys())
+}
+
+// TestAlerter_Groups tests that observing one group never clears another group's alerts
+func TestAlerter_Groups(t *testing.T) {
+	// Arrange
+	alerts, notifier := newTestAlerter(t, NotifyConfig{})
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Act
+	alerts.Observe(AlertGroupFans, fanAlerts(map[string]int{"FAN1": 0}, nil, 100), start)
+	alerts.Observe(AlertGroupReads, readFailureAlerts(errors.New("smartctl hung")), start)
+	alerts.Observe(AlertGroupReads, nil, start.Add(time.Minute))
+	waitSent(t, alerts)
+
+	// Assert
+	assert.ElementsMatch(t, []string{"fan_stall/FAN1", "sensor_loss/all", "resolved sensor_loss/all"}, notifier.keys())
+}
+
+// TestAlerter_Events tests that notify.events limits the events sent
+func TestAlerter_Events(t *testing.T) {
+	// Arrange
+	alerts, notifier := newTestAlerter(t, NotifyConfig{Events: []string{EventIPMIFailure}})
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Act
+	alerts.Observe(AlertGroupEmergency, emergencyAlerts(ReasonHDDTemp), start)
+	alerts.Observe(AlertGroupEmergency, emergencyAlerts(ReasonIPMIFailure), start.Add(time.Minute))
+	waitSent(t, alerts)
+
+	// Assert
+	assert.Equal(t, []string{"emergency/ipmi_failure"}, notifier.keys())
+}
+
+// TestAlerter_SendFailure tests that a failing notifier is counted and does not stop the alerter
+func TestAlerter_SendFailure(t *testing.T) {
+	// Arrange
+	alerts, notifier := newTestAlerter(t, NotifyConfig{})
+	notifier.err = errors.New("connection refused")
+	failed := testutil.ToFloat64(metrics.Notifications.WithLabelValues("fake", "failed"))
+
+	// Act
+	alerts.Observe(AlertGroupEmergency, emergencyAlerts(ReasonCPUTemp), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	waitSent(t, alerts)
+
+	// Assert
+	assert.Equal(t, []string{"emergency/cpu_temp"}, notifier.keys())
+	assert.Equal(t, failed+1, testutil.ToFloat64(metrics.Notifications.WithLabelValues("fake", "failed")))
+}
+
+// TestEmergencyAlerts tests the event of each emergency reason
+func TestEmergencyAlerts(t *testing.T) {
+	tests := []struct {
+		reason string
+		event  string
+	}{
+		{ReasonHDDTemp, EventEmergency},
+		{ReasonEnclosureTemp, EventEmergency},
+		{ReasonIPMIFailure, EventIPMIFailure},
+		{ReasonSensorFailure, EventSensorLoss},
+		{ReasonSensorPartial, EventSensorLoss},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.reason, func(t *testing.T) {
+			// Act
+			alerts := emergencyAlerts(tt.reason)
+
+			// Assert
+			require.Len(t, alerts, 1)
+			assert.Equal(t, tt.event, alerts[0].Event)
+			assert.Equal(t, "emergency/"+tt.reason, alerts[0].Key)
+		})
+	}
+	assert.Empty(t, emergencyAlerts(""))
+}
+
+// TestDiskAlerts tests that each disk is compared with its own group's max_hdd
+func TestDiskAlerts(t *testing.T) {
+	// Arrange
+	config := &Config{
+		Temperature: TemperatureConfig{TargetHDD: 38, MaxHDD: 45},
+		Disks:       DiskConfig{Groups: []DiskGroup{{Name: "enterprise", Match: []string{"^ST16000NM"}, MaxHDD: 60}}},
+	}
+	readings := TempReadings{
+		Disks: map[string]int{"sda": 50, "sdb": 50, "sdc": 40},
+		Identities: map[string]DiskIdentity{
+			"sda": {Device: "sda", Serial: "ZL2A", Model: "ST16000NM001G"},
+			"sdb": {Device: "sdb", Serial: "WD1"},
+		},
+	}
+
+	// Act
+	alerts := diskAlerts(config, readings)
+
+	// Assert
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "disk_over_max/WD1", alerts[0].Key)
+	assert.Equal(t, "sdb (serial WD1) at 50°C, max 45°C", alerts[0].Message)
+}
+
+// TestFanAlerts tests that IPMI and enclosure fans below stall_rpm alert
+func TestFanAlerts(t *testing.T) {
+	// Act
+	alerts := fanAlerts(map[string]int{"FAN1": 1200, "FAN2": 0}, map[string]int{"5000/fan0": 50}, 100)
+
+	// Assert
+	require.Len(t, alerts, 2)
+	assert.Equal(t, "fan_stall/FAN2", alerts[0].Key)
+	assert.Equal(t, "fan_stall/5000/fan0", alerts[1].Key)
+}
+
+// TestSensorAlerts tests an alert per unreadable disk, keyed by serial
+func TestSensorAlerts(t *testing.T) {
+	// Arrange
+	readings := TempReadings{
+		FailedDisks: []string{"sda", "sdb"},
+		Identities:  map[string]DiskIdentity{"sda": {Device: "sda", Serial: "VAG1"}},
+	}
+
+	// Act
+	alerts := sensorAlerts(readings)
+
+	// Assert
+	require.Len(t, alerts, 2)
+	assert.Equal(t, "sensor_loss/VAG1", alerts[0].Key)
+	assert.Equal(t, "sensor_loss/sdb", alerts[1].Key)
+}
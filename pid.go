@@ -4,6 +4,19 @@ import (
 	"time"
 )
 
+// PID forms
+const (
+	PIDFormLegacy   = "legacy"   // Integral is the raw error*dt accumulator; Ki is ignored
+	PIDFormStandard = "standard" // Integral is Ki times the accumulated error, in output units
+)
+
+// Anti-windup strategies
+const (
+	AntiWindupClamp           = "clamp"            // Clamp the integral to ±IntegralMax
+	AntiWindupConditional     = "conditional"      // Freeze the integral while saturated in the error direction
+	AntiWindupBackCalculation = "back_calculation" // Bleed off the integral by the saturation excess
+)
+
 // PIDController implements a PID controller with anti-windup protection
 type PIDController struct {
 	// PID gains
@@ -14,8 +27,11 @@ type PIDController struct {
 	// Target setpoint
 	Target float64
 	
+	// Integral form (legacy or standard)
+	Form string
+	
 	// Internal state
-	Integral    float64   // Accumulated integral term
+	Integral    float64   // Accumulated integral term (output units)
 	PrevError   float64  // Previous error for derivative calculation
 	PrevTime    time.Time // Previous calculation time
 	FirstRun    bool     // True on first run (skip derivative)
@@ -25,7 +41,21 @@ type PIDController struct {
 	MaxOutput   float64 // Maximum output value
 	
 	// Anti-windup protection
-	IntegralMax float64 // Maximum allowed integral term
+	IntegralMax  float64 // Maximum allowed integral term
+	AntiWindup   string  // Anti-windup strategy (clamp, conditional, back_calculation)
+	TrackingGain float64 // Back-calculation tracking gain (1/s)
+	
+	// Feed-forward bias added on top of the PID output
+	FeedForward float64
+	
+	// Derivative options
+	DerivativeOnMeasurement bool    // Differentiate the measurement instead of the error (no setpoint kick)
+	DerivativeFilterTau     float64 // Low-pass filter time constant for D in seconds (0 = disabled)
+	PrevMeasurement         float64 // Previous measurement for derivative-on-measurement
+	FilteredDerivative      float64 // Low-pass filtered derivative term
+	
+	// Time source (overridable for testing)
+	now func() time.Time
 }
 
 // PIDTerms contains the individual PID components for monitoring
@@ -33,10 +63,12 @@ type PIDTerms struct {
 	P     float64 // Proportional term
 	I     float64 // Integral term  
 	D     float64 // Derivative term
+	FF    float64 // Feed-forward bias term
 	Error float64 // Current error
 }
 
 // NewPIDController creates a new PID controller with the specified parameters
+// The form is legacy, the pid.form default, so it matches a controller built from config
 func NewPIDController(kp, ki, kd, target, minOutput, maxOutput, integralMax float64) *PIDController {
 	return &PIDController{
 		Kp:          kp,
@@ -46,14 +78,17 @@ func NewPIDController(kp, ki, kd, target, minOutput, maxOutput, integralMax floa
 		MinOutput:   minOutput,
 		MaxOutput:   maxOutput,
 		IntegralMax: integralMax,
+		Form:        PIDFormLegacy,
+		AntiWindup:  AntiWindupClamp,
 		FirstRun:    true,
+		now:         time.Now,
 	}
 }
 
 // Calculate computes the PID output for the given current value
 // Returns the output value and individual PID terms for monitoring
 func (p *PIDController) Calculate(current float64) (float64, PIDTerms) {
-	now := time.Now()
+	now := p.now()
 	
 	// Calculate error
 	error := current - p.Target
@@ -69,25 +104,58 @@ func (p *PIDController) Calculate(current float64) (float64, PIDTerms) {
 	// Proportional term
 	proportional := p.Kp * error
 	
-	// Integral term with anti-windup
-	integral := p.Integral + error*dt
-	integralClamped := clamp(integral, -p.IntegralMax, p.IntegralMax)
+	// Integral term in output units; the ±IntegralMax clamp applies to every strategy
+	var integral float64
+	if p.Form == PIDFormLegacy {
+		integral = p.Integral + error*dt
+	} else {
+		integral = p.Integral + p.Ki*error*dt
+	}
 	
 	// Derivative term (skip on first run)
 	var derivative float64
 	if !p.FirstRun && dt > 0 {
-		derivative = p.Kd * (error - p.PrevError) / dt
+		// Derivative-on-measurement ignores setpoint changes; since
+		// error = current - target both forms have the same sign
+		var rate float64
+		if p.DerivativeOnMeasurement {
+			rate = (current - p.PrevMeasurement) / dt
+		} else {
+			rate = (error - p.PrevError) / dt
+		}
+		derivative = p.Kd * rate
+		
+		// First-order low-pass filter smooths quantized (integer) readings
+		if p.DerivativeFilterTau > 0 {
+			alpha := dt / (p.DerivativeFilterTau + dt)
+			p.FilteredDerivative += alpha * (derivative - p.FilteredDerivative)
+			derivative = p.FilteredDerivative
+		}
 	}
 	
+	// Conditional integration: don't integrate further into saturation
+	if p.AntiWindup == AntiWindupConditional {
+		unsaturated := proportional + p.Integral + derivative + p.FeedForward
+		if (unsaturated >= p.MaxOutput && error > 0) || (unsaturated <= p.MinOutput && error < 0) {
+			integral = p.Integral
+		}
+	}
+	integralClamped := clamp(integral, -p.IntegralMax, p.IntegralMax)
+	
 	// Calculate output
-	output := proportional + integralClamped + derivative
+	unclamped := proportional + integralClamped + derivative + p.FeedForward
 	
 	// Clamp output to limits
-	output = clamp(output, p.MinOutput, p.MaxOutput)
+	output := clamp(unclamped, p.MinOutput, p.MaxOutput)
 	
 	// Update internal state
 	p.Integral = integralClamped
+	if p.AntiWindup == AntiWindupBackCalculation {
+		// Feed the saturation excess back so the integral tracks the achievable output
+		p.Integral = clamp(p.Integral+p.TrackingGain*(output-unclamped)*dt, -p.IntegralMax, p.IntegralMax)
+	}
 	p.PrevError = error
+	p.PrevMeasurement = current
 	p.PrevTime = now
 	p.FirstRun = false
 	
@@ -96,6 +164,7 @@ func (p *PIDController) Calculate(current float64) (float64, PIDTerms) {
 		P:     proportional,
 		I:     integralClamped,
 		D:     derivative,
+		FF:    p.FeedForward,
 		Error: error,
 	}
 	
@@ -106,6 +175,8 @@ func (p *PIDController) Calculate(current float64) (float64, PIDTerms) {
 func (p *PIDController) Reset() {
 	p.Integral = 0
 	p.PrevError = 0
+	p.PrevMeasurement = 0
+	p.FilteredDerivative = 0
 	p.PrevTime = time.Time{}
 	p.FirstRun = true
 }
@@ -115,6 +186,11 @@ func (p *PIDController) SetTarget(target float64) {
 	p.Target = target
 }
 
+// SetFeedForward updates the feed-forward bias applied to subsequent outputs
+func (p *PIDController) SetFeedForward(bias float64) {
+	p.FeedForward = bias
+}
+
 // SetGains updates the PID gains
 func (p *PIDController) SetGains(kp, ki, kd float64) {
 	p.Kp = kp
@@ -128,6 +204,31 @@ func (p *PIDController) SetLimits(minOutput, maxOutput float64) {
 	p.MaxOutput = maxOutput
 }
 
+// SetDerivativeMode configures derivative-on-measurement and the D low-pass filter
+// filterTau is the filter time constant in seconds (0 disables filtering)
+func (p *PIDController) SetDerivativeMode(onMeasurement bool, filterTau float64) {
+	p.DerivativeOnMeasurement = onMeasurement
+	p.DerivativeFilterTau = filterTau
+	p.FilteredDerivative = 0
+}
+
+// SetTimeSource replaces the clock used to measure time between calculations
+func (p *PIDController) SetTimeSource(now func() time.Time) {
+	p.now = now
+}
+
+// SetForm selects the legacy or standard integral form
+func (p *PIDController) SetForm(form string) {
+	p.Form = form
+}
+
+// SetAntiWindup selects the anti-windup strategy
+// trackingGain is only used by back-calculation
+func (p *PIDController) SetAntiWindup(strategy string, trackingGain float64) {
+	p.AntiWindup = strategy
+	p.TrackingGain = trackingGain
+}
+
 // SetIntegralMax updates the integral anti-windup limit
 func (p *PIDController) SetIntegralMax(integralMax float64) {
 	p.IntegralMax = integralMax
@@ -145,6 +246,8 @@ func (p *PIDController) GetState() map[string]float64 {
 		"min_output":  p.MinOutput,
 		"max_output":  p.MaxOutput,
 		"integral_max": p.IntegralMax,
+		"tracking_gain": p.TrackingGain,
+		"feed_forward": p.FeedForward,
 	}
 }
 
@@ -43,6 +43,19 @@ func TestPIDController_Calculate_Integral(t *testing.T) {
 	assert.Greater(t, output, 0.0)
 }
 
+// TestNewPIDController_DefaultForm tests that a directly built controller uses the config default form
+func TestNewPIDController_DefaultForm(t *testing.T) {
+	// Arrange
+	config := &Config{}
+	setDefaults(config)
+
+	// Act
+	pid := NewPIDController(1.0, 0.1, 0.0, 38.0, 0, 100, 50)
+
+	// Assert
+	assert.Equal(t, config.PID.Form, pid.Form)
+}
+
 // TestPIDController_Calculate_Derivative tests the derivative term
 func TestPIDController_Calculate_Derivative(t *testing.T) {
 	// Arrange
@@ -59,7 +72,7 @@ func TestPIDController_Calculate_Derivative(t *testing.T) {
 	// Assert - derivative should be negative (error decreasing)
 	assert.NotEqual(t, 0.0, terms2.D, "Derivative should not be 0 on second run")
 	assert.InDelta(t, 0.0, terms2.P, 0.01)   // Kp is 0
-	// Integral accumulates even when Ki=0
+	// Legacy form: the integral still accumulates with Ki=0, only standard applies Ki
 }
 
 // TestPIDController_Calculate_AntiWindup tests integral anti-windup
@@ -446,3 +459,229 @@ func TestClamp_EqualToMax(t *testing.T) {
 	// Assert
 	assert.Equal(t, 100.0, result)
 }
+
+// TestPIDController_FeedForward tests that the feed-forward bias is added to output
+func TestPIDController_FeedForward(t *testing.T) {
+	// Arrange
+	pid := NewPIDController(2.0, 0.0, 0.0, 38.0, 0, 100, 50)
+	pid.SetForm(PIDFormStandard)
+	pid.SetFeedForward(10.0)
+
+	// Act
+	output, terms := pid.Calculate(40.0) // P = 4
+
+	// Assert
+	assert.Equal(t, 10.0, terms.FF)
+	assert.InDelta(t, 4.0+10.0, output, 0.01) // P + FF (Ki = 0)
+	assert.Equal(t, 10.0, pid.GetState()["feed_forward"])
+}
+
+// TestPIDController_SetpointStep_DerivativeKick tests D response to a setpoint change
+func TestPIDController_SetpointStep_DerivativeKick(t *testing.T) {
+	tests := []struct {
+		name          string
+		onMeasurement bool
+		expectKick    bool
+	}{
+		{"derivative on error kicks", false, true},
+		{"derivative on measurement does not kick", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			pid := NewPIDController(0.0, 0.0, 20.0, 38.0, -100, 100, 50)
+			pid.now = steppingClock(30 * time.Second)
+			pid.SetDerivativeMode(tt.onMeasurement, 0)
+			pid.Calculate(38.0)
+			pid.Calculate(38.0)
+
+			// Act - step the setpoint with a constant measurement
+			pid.SetTarget(35.0)
+			_, terms := pid.Calculate(38.0)
+
+			// Assert
+			if tt.expectKick {
+				assert.InDelta(t, 20.0*3.0/30.0, terms.D, 0.001)
+			} else {
+				assert.Equal(t, 0.0, terms.D)
+			}
+		})
+	}
+}
+
+// TestPIDController_DerivativeFilter_QuantizedInput tests D smoothing of integer steps
+func TestPIDController_DerivativeFilter_QuantizedInput(t *testing.T) {
+	// Arrange - smartctl reports whole degrees, so temperature moves in 1°C steps
+	temps := []float64{38, 38, 39, 39, 39, 39}
+
+	run := func(filterTau float64) []float64 {
+		pid := NewPIDController(0.0, 0.0, 20.0, 38.0, -100, 100, 50)
+		pid.now = steppingClock(30 * time.Second)
+		pid.SetDerivativeMode(true, filterTau)
+		var d []float64
+		for _, temp := range temps {
+			_, terms := pid.Calculate(temp)
+			d = append(d, terms.D)
+		}
+		return d
+	}
+
+	// Act
+	raw := run(0)
+	filtered := run(120)
+
+	// Assert - unfiltered D is a single spike at the step
+	assert.InDelta(t, 20.0/30.0, raw[2], 0.001)
+	assert.Equal(t, 0.0, raw[3])
+
+	// Filtered D has a lower peak that decays over following samples
+	assert.Less(t, filtered[2], raw[2])
+	assert.InDelta(t, 0.2*20.0/30.0, filtered[2], 0.001) // alpha = 30/(120+30)
+	assert.Greater(t, filtered[3], 0.0)
+	assert.Less(t, filtered[3], filtered[2])
+	assert.Less(t, filtered[5], filtered[4])
+}
+
+// TestPIDController_Reset_ClearsDerivativeFilter tests Reset clears filter state
+func TestPIDController_Reset_ClearsDerivativeFilter(t *testing.T) {
+	// Arrange
+	pid := NewPIDController(0.0, 0.0, 20.0, 38.0, -100, 100, 50)
+	pid.now = steppingClock(30 * time.Second)
+	pid.SetDerivativeMode(true, 60)
+	pid.Calculate(38.0)
+	pid.Calculate(40.0)
+	require.NotEqual(t, 0.0, pid.FilteredDerivative)
+
+	// Act
+	pid.Reset()
+
+	// Assert
+	assert.Equal(t, 0.0, pid.FilteredDerivative)
+	assert.Equal(t, 0.0, pid.PrevMeasurement)
+}
+
+// steppingClock returns a time source that advances by step on every call
+func steppingClock(step time.Duration) func() time.Time {
+	current := time.Unix(0, 0)
+	return func() time.Time {
+		current = current.Add(step)
+		return current
+	}
+}
+
+// simulateSaturationRecovery drives a first-order thermal plant through a heat
+// load the fans can't keep up with, then drops the load and returns how far the
+// temperature undershoots the target while the integral unwinds
+func simulateSaturationRecovery(t *testing.T, strategy string) float64 {
+	t.Helper()
+
+	const (
+		target  = 38.0
+		ambient = 25.0
+		cooling = 0.2  // °C removed per % duty at equilibrium
+		tau     = 20.0 // Plant time constant in samples
+	)
+
+	pid := NewPIDController(5.0, 1.0, 0.0, target, 0, 100, 100)
+	pid.now = steppingClock(time.Second)
+	pid.SetAntiWindup(strategy, 0.5)
+
+	temp := target
+	minTemp := target
+	for step := 0; step < 200; step++ {
+		load := 40.0 // Full duty only holds 45°C: output saturates
+		if step >= 60 {
+			load = 15.0 // Load drops: ~10% duty holds the target
+		}
+
+		duty, _ := pid.Calculate(temp)
+		equilibrium := ambient + load - cooling*duty
+		temp += (equilibrium - temp) / tau
+
+		if step >= 60 && temp < minTemp {
+			minTemp = temp
+		}
+	}
+
+	return target - minTemp
+}
+
+// TestPIDController_AntiWindup_ReducesOvershoot tests strategies after saturation
+func TestPIDController_AntiWindup_ReducesOvershoot(t *testing.T) {
+	// Act
+	clampUndershoot := simulateSaturationRecovery(t, AntiWindupClamp)
+	conditionalUndershoot := simulateSaturationRecovery(t, AntiWindupConditional)
+	backCalcUndershoot := simulateSaturationRecovery(t, AntiWindupBackCalculation)
+	t.Logf("undershoot: clamp=%.2f conditional=%.2f back_calculation=%.2f",
+		clampUndershoot, conditionalUndershoot, backCalcUndershoot)
+
+	// Assert - the clamp lets the integral wind up to IntegralMax while saturated
+	assert.Greater(t, clampUndershoot, 0.0)
+	assert.Less(t, conditionalUndershoot, clampUndershoot)
+	assert.Less(t, backCalcUndershoot, clampUndershoot)
+}
+
+// TestPIDController_ConditionalIntegration_FreezesWhenSaturated tests integral freezing
+func TestPIDController_ConditionalIntegration_FreezesWhenSaturated(t *testing.T) {
+	// Arrange
+	pid := NewPIDController(20.0, 1.0, 0.0, 38.0, 0, 100, 100)
+	pid.now = steppingClock(time.Second)
+	pid.SetAntiWindup(AntiWindupConditional, 0)
+	pid.Integral = 90.0
+
+	// Act - P = 10, so P + I sits at MaxOutput with positive error
+	output, _ := pid.Calculate(38.5)
+
+	// Assert - integral not accumulated while saturated high
+	assert.Equal(t, 100.0, output)
+	assert.Equal(t, 90.0, pid.Integral)
+
+	// Negative error brings the output back into range and integration resumes
+	pid.Calculate(37.5)
+	assert.InDelta(t, 89.5, pid.Integral, 0.001)
+}
+
+// TestPIDController_StandardForm_KiScalesIntegral tests that Ki affects the integral
+func TestPIDController_StandardForm_KiScalesIntegral(t *testing.T) {
+	run := func(form string, ki float64) float64 {
+		pid := NewPIDController(0.0, ki, 0.0, 38.0, 0, 100, 50)
+		pid.now = steppingClock(30 * time.Second)
+		pid.SetForm(form)
+		var output float64
+		for i := 0; i < 3; i++ {
+			output, _ = pid.Calculate(40.0) // error = 2
+		}
+		return output
+	}
+
+	// Act
+	standardLow := run(PIDFormStandard, 0.1)
+	standardHigh := run(PIDFormStandard, 0.2)
+	legacyLow := run(PIDFormLegacy, 0.1)
+	legacyHigh := run(PIDFormLegacy, 0.2)
+
+	// Assert - standard: I = Ki * error * dt summed (first run uses dt = 1s)
+	assert.InDelta(t, 0.1*2.0*(1+30+30), standardLow, 0.001)
+	assert.InDelta(t, 2*standardLow, standardHigh, 0.001)
+
+	// Legacy ignores Ki and is only bounded by IntegralMax
+	assert.Equal(t, legacyLow, legacyHigh)
+	assert.Equal(t, 50.0, legacyLow)
+}
+
+// TestPIDController_StandardForm_IntegralMaxInDutyPercent tests the integral limit units
+func TestPIDController_StandardForm_IntegralMaxInDutyPercent(t *testing.T) {
+	// Arrange
+	pid := NewPIDController(0.0, 0.5, 0.0, 38.0, 0, 100, 20)
+	pid.now = steppingClock(30 * time.Second)
+
+	// Act
+	var terms PIDTerms
+	for i := 0; i < 10; i++ {
+		_, terms = pid.Calculate(45.0)
+	}
+
+	// Assert - integral contribution is capped at 20% duty
+	assert.Equal(t, 20.0, terms.I)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sensor sources checked by the plausibility filter, used as the source label of rejected readings
+const (
+	SourceHDD       = "hdd"
+	SourceFlash     = "flash"
+	SourceEnclosure = "enclosure"
+	SourceCPU       = "cpu"
+)
+
+// Reasons a reading is rejected, used as the reason label of rejected readings
+const (
+	RejectOutOfRange = "out_of_range" // Outside the source's min/max bounds
+	RejectRate       = "rate"         // Changed faster than max_rate since the last accepted reading
+)
+
+const (
+	// Rejected readings in a row that the last accepted value stands in for;
+	// after that the sensor counts as unreadable so sensor_failure applies
+	maxHeldReadings = 3
+
+	// Accepted readings kept per sensor for median smoothing
+	medianWindow = 3
+)
+
+// sensorHistory is the filter state of one sensor
+type sensorHistory struct {
+	accepted   []float64 // Last accepted readings, newest last
+	acceptedAt time.Time // When the newest accepted reading was taken
+	pending    float64   // Raw value rejected by the rate limit in the previous poll
+	pendingAt  time.Time // When pending was read, zero without one
+	rejected   int       // Rejected readings in a row
+}
+
+// PlausibilityFilter drops readings that cannot be real (a SMART read returning
+// 0°C or 255°C) before they reach the aggregate or the emergency checks. It holds
+// per-sensor history, so use one per control loop
+type PlausibilityFilter struct {
+	config  PlausibilityConfig
+	sensors map[string]*sensorHistory // By source and serial or sensor name
+}
+
+// NewPlausibilityFilter creates a filter with no history
+func NewPlausibilityFilter(config PlausibilityConfig) *PlausibilityFilter {
+	return &PlausibilityFilter{config: config, sensors: make(map[string]*sensorHistory)}
+}
+
+// Filter returns readings with implausible values replaced by the sensor's last
+// accepted value. A disk without one is moved to FailedDisks and an enclosure
+// sensor is dropped; an implausible CPU reading without one, or no usable HDD
+// reading at all, is an error so sensor_failure applies.
+// The maps of readings are copied, never modified
+func (f *PlausibilityFilter) Filter(readings TempReadings, now time.Time) (TempReadings, error) {
+	seen := make(map[string]bool)
+	diskKey := func(source string) func(string) string {
+		return func(device string) string { return source + "/" + readings.Identity(device).Serial }
+	}
+	enclosureKey := func(name string) string { return SourceEnclosure + "/" + name }
+
+	disks, failedHDDs := f.filterTemps(SourceHDD, f.config.HDD, readings.Disks, diskKey(SourceHDD), now, seen)
+	flash, failedFlash := f.filterTemps(SourceFlash, f.config.Flash, readings.Flash, diskKey(SourceFlash), now, seen)
+	enclosure, _ := f.filterTemps(SourceEnclosure, f.config.Enclosure, readings.Enclosure.Temperatures, enclosureKey, now, seen)
+
+	readings.Disks, readings.Flash, readings.Enclosure.Temperatures = disks, flash, enclosure
+	if len(failedHDDs)+len(failedFlash) > 0 {
+		failed := append([]string(nil), readings.FailedDisks...)
+		readings.FailedDisks = append(append(failed, failedHDDs...), failedFlash...)
+	}
+
+	seen[SourceCPU] = true
+	cpu, ok := f.check(SourceCPU, SourceCPU, f.config.CPU, readings.CPU, now)
+
+	// Forget sensors that are gone, so a returning disk starts without history
+	for key := range f.sensors {
+		if !seen[key] {
+			delete(f.sensors, key)
+		}
+	}
+
+	if !ok {
+		return readings, fmt.Errorf("implausible CPU temperature %.1f°C", readings.CPU)
+	}
+	if len(disks) == 0 && len(failedHDDs) > 0 {
+		return readings, fmt.Errorf("no plausible disk temperature, rejected: %s", strings.Join(failedHDDs, ", "))
+	}
+	readings.CPU = cpu
+	return readings, nil
+}
+
+// filterTemps checks every sensor in temps and returns the usable values and the
+// sensors left without one
+func (f *PlausibilityFilter) filterTemps(source string, bounds SensorBounds, temps map[string]int,
+	key func(string) string, now time.Time, seen map[string]bool) (map[string]int, []string) {
+	if temps == nil {
+		return nil, nil
+	}
+
+	filtered := make(map[string]int, len(temps))
+	var dropped []string
+	for _, name := range sortedKeys(temps) {
+		sensorKey := key(name)
+		seen[sensorKey] = true
+		value, ok := f.check(source, sensorKey, bounds, float64(temps[name]), now)
+		if !ok {
+			dropped = append(dropped, name)
+			continue
+		}
+		filtered[name] = int(math.Round(value))
+	}
+	return filtered, dropped
+}
+
+// check returns the value to use for one raw reading, or false when it was
+// rejected and there is no recent accepted value to stand in for it
+func (f *PlausibilityFilter) check(source, key string, bounds SensorBounds, value float64, now time.Time) (float64, bool) {
+	history, ok := f.sensors[key]
+	if !ok {
+		history = &sensorHistory{}
+		f.sensors[key] = history
+	}
+
+	reason := ""
+	switch {
+	case value < bounds.Min || value > bounds.Max:
+		reason = RejectOutOfRange
+	case f.config.MaxRate > 0 && len(history.accepted) > 0 &&
+		!f.withinRate(history.accepted[len(history.accepted)-1], history.acceptedAt, value, now) &&
+		// A step repeated by the next poll is real, so the rate limit delays it by one poll at most
+		(history.pendingAt.IsZero() || !f.withinRate(history.pending, history.pendingAt, value, now)):
+		reason = RejectRate
+	}
+
+	history.pendingAt = time.Time{}
+	if reason == RejectRate {
+		history.pending, history.pendingAt = value, now
+	}
+
+	if reason == "" {
+		history.accepted = append(history.accepted, value)
+		if len(history.accepted) > medianWindow {
+			history.accepted = history.accepted[1:]
+		}
+		history.acceptedAt = now
+		history.rejected = 0
+		return history.value(f.config.Median), true
+	}
+
+	history.rejected++
+	RecordRejectedReading(source, reason)
+	if len(history.accepted) == 0 || history.rejected > maxHeldReadings {
+		slog.Warn("Rejected implausible reading", "source", source, "sensor", key, "value", value,
+			"reason", reason, "rejected", history.rejected)
+		return 0, false
+	}
+	held := history.value(f.config.Median)
+	slog.Warn("Rejected implausible reading", "source", source, "sensor", key, "value", value,
+		"reason", reason, "rejected", history.rejected, "held", held)
+	return held, true
+}
+
+// withinRate reports whether going from one reading to another stays within max_rate
+func (f *PlausibilityFilter) withinRate(from float64, fromAt time.Time, to float64, now time.Time) bool {
+	return math.Abs(to-from) <= f.config.MaxRate*now.Sub(fromAt).Minutes()
+}
+
+// value returns the newest accepted reading, or the median of the last accepted
+// readings. With two readings the higher one is used, erring towards cooling
+func (h *sensorHistory) value(median bool) float64 {
+	if !median {
+		return h.accepted[len(h.accepted)-1]
+	}
+	sorted := append([]float64(nil), h.accepted...)
+	sort.Float64s(sorted)
+	return sorted[len(sorted)/2]
+}
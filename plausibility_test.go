@@ -0,0 +1,223 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPlausibilityFilter creates a filter with default bounds and the given rate limit and smoothing
+func newTestPlausibilityFilter(t *testing.T, maxRate float64, median bool) *PlausibilityFilter {
+	t.Helper()
+
+	initTestMetrics()
+	silenceLogs(t)
+
+	config := &Config{Plausibility: PlausibilityConfig{MaxRate: maxRate, Median: median}}
+	setDefaults(config)
+	return NewPlausibilityFilter(config.Plausibility)
+}
+
+// TestPlausibilityFilter_Bounds tests that out-of-range disk readings hold the last accepted value
+func TestPlausibilityFilter_Bounds(t *testing.T) {
+	// Arrange
+	filter := newTestPlausibilityFilter(t, 0, false)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rejected := testutil.ToFloat64(metrics.RejectedReadings.WithLabelValues(SourceHDD, RejectOutOfRange))
+
+	tests := []struct {
+		name     string
+		sda      int
+		expected int
+	}{
+		{"plausible reading", 38, 38},
+		{"SMART returns 255", 255, 38},
+		{"SMART returns 0", 0, 38},
+		{"plausible again", 39, 39},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			readings, err := filter.Filter(TempReadings{Disks: map[string]int{"sda": tt.sda}, CPU: 50}, start.Add(time.Duration(i)*time.Minute))
+
+			// Assert
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, readings.Disks["sda"])
+			assert.Empty(t, readings.FailedDisks)
+		})
+	}
+	assert.Equal(t, rejected+2, testutil.ToFloat64(metrics.RejectedReadings.WithLabelValues(SourceHDD, RejectOutOfRange)))
+}
+
+// TestPlausibilityFilter_NoHistory tests that a rejected disk without an accepted value is reported as failed
+func TestPlausibilityFilter_NoHistory(t *testing.T) {
+	// Arrange
+	filter := newTestPlausibilityFilter(t, 0, false)
+	raw := map[string]int{"sda": 255, "sdb": 38}
+	readings := TempReadings{Disks: raw, FailedDisks: []string{"sdc"}, CPU: 50}
+
+	// Act
+	filtered, err := filter.Filter(readings, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"sdb": 38}, filtered.Disks)
+	assert.Equal(t, []string{"sdc", "sda"}, filtered.FailedDisks)
+	assert.Equal(t, map[string]int{"sda": 255, "sdb": 38}, raw, "the source map is not modified")
+	assert.Equal(t, []string{"sdc"}, readings.FailedDisks)
+}
+
+// TestPlausibilityFilter_HoldLimit tests that a persistently implausible disk is reported as failed
+func TestPlausibilityFilter_HoldLimit(t *testing.T) {
+	// Arrange
+	filter := newTestPlausibilityFilter(t, 0, false)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := filter.Filter(TempReadings{Disks: map[string]int{"sda": 38, "sdb": 40}, CPU: 50}, start)
+	require.NoError(t, err)
+
+	// Act
+	var readings TempReadings
+	for i := 1; i <= maxHeldReadings+1; i++ {
+		readings, err = filter.Filter(TempReadings{Disks: map[string]int{"sda": 255, "sdb": 40}, CPU: 50}, start.Add(time.Duration(i)*time.Minute))
+		require.NoError(t, err)
+		if i <= maxHeldReadings {
+			assert.Equal(t, 38, readings.Disks["sda"], "poll %d holds the last accepted value", i)
+		}
+	}
+
+	// Assert
+	assert.Equal(t, map[string]int{"sdb": 40}, readings.Disks)
+	assert.Equal(t, []string{"sda"}, readings.FailedDisks)
+}
+
+// TestPlausibilityFilter_NoPlausibleDisk tests that rejecting every HDD reading is an error
+func TestPlausibilityFilter_NoPlausibleDisk(t *testing.T) {
+	// Arrange
+	filter := newTestPlausibilityFilter(t, 0, false)
+
+	// Act
+	readings, err := filter.Filter(TempReadings{Disks: map[string]int{"sda": 0, "sdb": 255}, CPU: 50}, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no plausible disk temperature")
+	assert.Empty(t, readings.Disks)
+	assert.Equal(t, []string{"sda", "sdb"}, readings.FailedDisks)
+}
+
+// TestPlausibilityFilter_Rate tests that a one-poll spike is rejected while a sustained step is accepted
+func TestPlausibilityFilter_Rate(t *testing.T) {
+	// Arrange - 5°C per minute with a poll every minute
+	filter := newTestPlausibilityFilter(t, 5, false)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rejected := testutil.ToFloat64(metrics.RejectedReadings.WithLabelValues(SourceHDD, RejectRate))
+	polls := []struct {
+		sda      int
+		expected int
+	}{
+		{38, 38},
+		{41, 41}, // Within the limit
+		{70, 41}, // Spike
+		{42, 42}, // Spike gone
+		{60, 42}, // Step up
+		{61, 61}, // Confirmed by the next poll
+	}
+
+	for i, poll := range polls {
+		// Act
+		readings, err := filter.Filter(TempReadings{Disks: map[string]int{"sda": poll.sda}, CPU: 50}, start.Add(time.Duration(i)*time.Minute))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, poll.expected, readings.Disks["sda"], "poll %d", i)
+	}
+	assert.Equal(t, rejected+2, testutil.ToFloat64(metrics.RejectedReadings.WithLabelValues(SourceHDD, RejectRate)))
+}
+
+// TestPlausibilityFilter_Median tests median-of-3 smoothing of accepted readings
+func TestPlausibilityFilter_Median(t *testing.T) {
+	// Arrange
+	filter := newTestPlausibilityFilter(t, 0, true)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	polls := []struct {
+		sda      int
+		expected int
+	}{
+		{38, 38},
+		{36, 38}, // Two readings: the higher one
+		{40, 38},
+		{39, 39},
+		{41, 40},
+	}
+
+	for i, poll := range polls {
+		// Act
+		readings, err := filter.Filter(TempReadings{Disks: map[string]int{"sda": poll.sda}, CPU: 50}, start.Add(time.Duration(i)*time.Minute))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, poll.expected, readings.Disks["sda"], "poll %d", i)
+	}
+}
+
+// TestPlausibilityFilter_Sources tests flash, enclosure and CPU readings against their own bounds
+func TestPlausibilityFilter_Sources(t *testing.T) {
+	// Arrange
+	filter := newTestPlausibilityFilter(t, 0, false)
+	readings := TempReadings{
+		Flash:     map[string]int{"nvme0n1": 110, "nvme1n1": 140},
+		Enclosure: EnclosureReadings{Temperatures: map[string]int{"5000/temp0": 35, "5000/temp1": 0}},
+		CPU:       50,
+	}
+
+	// Act
+	filtered, err := filter.Filter(readings, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"nvme0n1": 110}, filtered.Flash)
+	assert.Equal(t, []string{"nvme1n1"}, filtered.FailedDisks)
+	assert.Equal(t, map[string]int{"5000/temp0": 35}, filtered.Enclosure.Temperatures)
+	assert.Equal(t, 50.0, filtered.CPU)
+}
+
+// TestPlausibilityFilter_CPU tests that an implausible CPU reading holds or fails the poll
+func TestPlausibilityFilter_CPU(t *testing.T) {
+	// Arrange
+	filter := newTestPlausibilityFilter(t, 0, false)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Act
+	_, firstErr := filter.Filter(TempReadings{CPU: 0}, start)
+	_, err := filter.Filter(TempReadings{CPU: 52.5}, start.Add(time.Minute))
+	require.NoError(t, err)
+	held, heldErr := filter.Filter(TempReadings{CPU: 255}, start.Add(2*time.Minute))
+
+	// Assert
+	require.Error(t, firstErr)
+	assert.Contains(t, firstErr.Error(), "implausible CPU temperature")
+	require.NoError(t, heldErr)
+	assert.Equal(t, 52.5, held.CPU)
+}
+
+// TestPlausibilityFilter_Forget tests that a disk that disappears loses its history
+func TestPlausibilityFilter_Forget(t *testing.T) {
+	// Arrange
+	filter := newTestPlausibilityFilter(t, 0, false)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := filter.Filter(TempReadings{Disks: map[string]int{"sda": 38, "sdb": 40}, CPU: 50}, start)
+	require.NoError(t, err)
+	_, err = filter.Filter(TempReadings{Disks: map[string]int{"sdb": 40}, CPU: 50}, start.Add(time.Minute))
+	require.NoError(t, err)
+
+	// Act
+	readings, err := filter.Filter(TempReadings{Disks: map[string]int{"sda": 255, "sdb": 40}, CPU: 50}, start.Add(2*time.Minute))
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sda"}, readings.FailedDisks, "no stale value stands in after the disk returns")
+}
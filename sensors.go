@@ -2,14 +2,18 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var (
@@ -17,6 +21,28 @@ var (
 	cachedK10TempPath string
 )
 
+// Control input aggregation strategies (temperature.aggregate)
+const (
+	AggregateTopNMean   = "top_n_mean" // Weighted mean of the warmest_disks warmest disks
+	AggregateMax        = "max"        // Hottest disk, ignoring disk group targets
+	AggregatePercentile = "percentile" // temperature.percentile of all disks
+	AggregateEWMA       = "ewma"       // top_n_mean smoothed over temperature.ewma_time_constant
+	AggregateMargin     = "margin"     // target_hdd plus the worst margin of a disk over its own target
+)
+
+// Block device root used for disk discovery
+const sysBlockPath = "/sys/block"
+
+// Disk discovery outcomes
+const (
+	DiskMonitored   = "monitored"    // Spinning, fixed disk read by smartctl
+	DiskExcluded    = "excluded"     // Matches disks.exclude_patterns
+	DiskNotSpinning = "not_spinning" // rotational=0 (SSD, NVMe, virtual)
+	DiskRemovable   = "removable"    // removable=1 (USB, card readers)
+	DiskNotIncluded = "not_included" // disks.include_patterns is set and none matches
+	DiskFlash       = "flash"        // Non-rotational physical disk monitored under disks.flash
+)
+
 // GetCPUTemperature reads CPU temperature from k10temp sensor
 // Auto-detects the hwmon path and caches it for subsequent calls
 func GetCPUTemperature() (float64, error) {
@@ -80,8 +106,8 @@ func readCPUTempFromPath(hwmonPath string) (float64, error) {
 
 // GetDiskTemperature reads temperature from a single disk using smartctl
 // Handles both SATA and NVMe disks with different parsing logic
-func GetDiskTemperature(device string) (int, error) {
-	cmd := exec.Command("smartctl", "-A", fmt.Sprintf("/dev/%s", device))
+func GetDiskTemperature(ctx context.Context, device string) (int, error) {
+	cmd := exec.CommandContext(ctx, "smartctl", "-A", fmt.Sprintf("/dev/%s", device))
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return 0, fmt.Errorf("smartctl failed for %s: %w", device, err)
@@ -130,102 +156,171 @@ func GetDiskTemperature(device string) (int, error) {
 
 // GetAllDiskTemperatures auto-discovers spinning disks and reads their temperatures
 // Uses ROTA=1 filtering and exclude patterns to identify relevant disks
-func GetAllDiskTemperatures(excludePatterns []string) (map[string]int, error) {
+// The returned readings have Disks, FailedDisks and Identities set, even when err is set
+func GetAllDiskTemperatures(ctx context.Context, diskConfig DiskConfig) (TempReadings, error) {
 	// Discover spinning disks
-	disks, err := discoverSpinningDisks(excludePatterns)
+	discovered, err := discoverMonitoredDisks(ctx, diskConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to discover spinning disks: %w", err)
+		return TempReadings{}, fmt.Errorf("failed to discover spinning disks: %w", err)
 	}
 	
-	if len(disks) == 0 {
-		return nil, fmt.Errorf("no spinning disks found")
+	if len(discovered.HDDs) == 0 {
+		return TempReadings{}, fmt.Errorf("no spinning disks found")
+	}
+	
+	readings := TempReadings{Disks: make(map[string]int), Flash: make(map[string]int), Identities: discovered.Identities}
+	
+	// Flash drives only feed their own policy, so their failures never fail the poll
+	for _, disk := range discovered.Flash {
+		temp, err := GetDiskTemperature(ctx, disk)
+		if err != nil {
+			if ctx.Err() != nil {
+				return TempReadings{}, fmt.Errorf("disk temperature read cancelled: %w", ctx.Err())
+			}
+			slog.Warn("Failed to read flash temperature", "disk", disk, "error", err)
+			readings.FailedDisks = append(readings.FailedDisks, disk)
+			continue
+		}
+		readings.Flash[disk] = temp
 	}
 	
 	// Read temperatures for each disk
-	temps := make(map[string]int)
 	var errors []string
 	
-	for _, disk := range disks {
-		temp, err := GetDiskTemperature(disk)
+	for _, disk := range discovered.HDDs {
+		if ctx.Err() != nil {
+			return TempReadings{}, fmt.Errorf("disk temperature read cancelled: %w", ctx.Err())
+		}
+		
+		temp, err := GetDiskTemperature(ctx, disk)
 		if err != nil {
-			log.Printf("Warning: failed to read temperature for %s: %v", disk, err)
+			slog.Warn("Failed to read disk temperature", "disk", disk, "error", err)
+			readings.FailedDisks = append(readings.FailedDisks, disk)
 			errors = append(errors, fmt.Sprintf("%s: %v", disk, err))
 			continue
 		}
 		
-		temps[disk] = temp
+		readings.Disks[disk] = temp
 	}
 	
 	// If we couldn't read any temperatures, return an error
-	if len(temps) == 0 {
-		return nil, fmt.Errorf("failed to read temperatures from any disk: %s", strings.Join(errors, "; "))
+	if len(readings.Disks) == 0 {
+		return readings, fmt.Errorf("failed to read temperatures from any disk: %s", strings.Join(errors, "; "))
 	}
 	
 	// Log any partial failures
 	if len(errors) > 0 {
-		log.Printf("Partial disk temperature reading failures: %s", strings.Join(errors, "; "))
+		slog.Warn("Partial disk temperature reading failures", "failures", strings.Join(errors, "; "))
 	}
 	
-	return temps, nil
+	return readings, nil
 }
 
-// discoverSpinningDisks finds all spinning disks by checking /sys/block/
-func discoverSpinningDisks(excludePatterns []string) ([]string, error) {
-	entries, err := os.ReadDir("/sys/block")
+// discoveredDisks is the result of one discovery pass
+type discoveredDisks struct {
+	HDDs       []string                // Spinning disks, the control input
+	Flash      []string                // SSD/NVMe drives when disks.flash is enabled
+	Identities map[string]DiskIdentity // Resolved identities of both, by device
+}
+
+// discoverMonitoredDisks finds all spinning disks, and flash drives if enabled, by
+// checking /sys/block/ and resolves their identities, so include and exclude
+// patterns can also match serial numbers and models
+func discoverMonitoredDisks(ctx context.Context, diskConfig DiskConfig) (discoveredDisks, error) {
+	discovered := discoveredDisks{Identities: make(map[string]DiskIdentity)}
+	
+	entries, err := os.ReadDir(sysBlockPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read /sys/block: %w", err)
+		return discovered, fmt.Errorf("failed to read %s: %w", sysBlockPath, err)
 	}
 	
-	var disks []string
+	byID, err := findByIDNames(diskByIDPath)
+	if err != nil {
+		slog.Debug("Disk by-id names unavailable", "error", err)
+	}
 	
 	for _, entry := range entries {
 		device := entry.Name()
 		
-		// Skip if matches exclude patterns
-		if matchesExcludePattern(device, excludePatterns) {
+		status, err := classifyDisk(sysBlockPath, device, diskConfig.ExcludePatterns)
+		if err != nil {
+			slog.Warn("Failed to check if disk is spinning", "disk", device, "error", err)
 			continue
 		}
+		status = classifyFlash(sysBlockPath, device, status, diskConfig.Flash)
 		
-		// Check if it's a spinning disk (ROTA=1) and not removable
-		isSpinning, err := isSpinningDisk(device)
-		if err != nil {
-			log.Printf("Warning: failed to check if %s is spinning: %v", device, err)
+		if status != DiskMonitored && status != DiskFlash {
 			continue
 		}
 		
-		if isSpinning {
-			disks = append(disks, device)
+		// A disk that cannot be identified is still monitored, labelled by kernel name
+		identity, err := ResolveDiskIdentity(ctx, device, byID)
+		if err != nil {
+			slog.Warn("Failed to resolve disk identity", "disk", device, "error", err)
+			identity = DiskIdentity{Device: device}
+		}
+		
+		if selectDisk(identity, diskConfig) != DiskMonitored {
+			continue
+		}
+		if identity.Serial != "" {
+			discovered.Identities[device] = identity
+		}
+		if status == DiskFlash {
+			discovered.Flash = append(discovered.Flash, device)
+		} else {
+			discovered.HDDs = append(discovered.HDDs, device)
 		}
 	}
 	
-	return disks, nil
+	return discovered, nil
 }
 
-// isSpinningDisk checks if a device is a spinning disk
-func isSpinningDisk(device string) (bool, error) {
+// classifyFlash turns a non-spinning disk into DiskFlash when disks.flash is enabled
+// and it is a fixed physical device; virtual devices (zram, md, nbd) have no device link
+func classifyFlash(sysBlock, device, status string, flash FlashConfig) string {
+	if status != DiskNotSpinning || !flash.Enabled {
+		return status
+	}
+	if _, err := os.Stat(filepath.Join(sysBlock, device, "device")); err != nil {
+		return status
+	}
+	removable, err := os.ReadFile(filepath.Join(sysBlock, device, "removable"))
+	if err != nil || strings.TrimSpace(string(removable)) != "0" {
+		return status
+	}
+	return DiskFlash
+}
+
+// classifyDisk decides whether a block device is monitored: it must not match an
+// exclude pattern, must be spinning (ROTA=1) and must not be removable
+func classifyDisk(sysBlock, device string, excludePatterns []string) (string, error) {
+	// Skip if matches exclude patterns
+	if matchesExcludePattern(device, excludePatterns) {
+		return DiskExcluded, nil
+	}
+	
 	// Check rotational flag (must be 1 for spinning disks)
-	rotaPath := fmt.Sprintf("/sys/block/%s/queue/rotational", device)
-	rotaData, err := os.ReadFile(rotaPath)
+	rotaData, err := os.ReadFile(filepath.Join(sysBlock, device, "queue", "rotational"))
 	if err != nil {
-		return false, err
+		return "", err
 	}
 	
 	if strings.TrimSpace(string(rotaData)) != "1" {
-		return false, nil
+		return DiskNotSpinning, nil
 	}
 	
 	// Check removable flag (must be 0 for internal disks)
-	removablePath := fmt.Sprintf("/sys/block/%s/removable", device)
-	removableData, err := os.ReadFile(removablePath)
+	removableData, err := os.ReadFile(filepath.Join(sysBlock, device, "removable"))
 	if err != nil {
-		return false, err
+		return "", err
 	}
 	
 	if strings.TrimSpace(string(removableData)) != "0" {
-		return false, nil
+		return DiskRemovable, nil
 	}
 	
-	return true, nil
+	return DiskMonitored, nil
 }
 
 // matchesExcludePattern checks if a device name matches any exclude pattern
@@ -233,7 +328,7 @@ func matchesExcludePattern(device string, patterns []string) bool {
 	for _, pattern := range patterns {
 		matched, err := regexp.MatchString(pattern, device)
 		if err != nil {
-			log.Printf("Warning: invalid exclude pattern %s: %v", pattern, err)
+			slog.Warn("Invalid exclude pattern", "pattern", pattern, "error", err)
 			continue
 		}
 		if matched {
@@ -245,38 +340,139 @@ func matchesExcludePattern(device string, patterns []string) bool {
 
 // GetAverageOfWarmest calculates the average temperature of the N warmest disks
 func GetAverageOfWarmest(temps map[string]int, n int) float64 {
+	values := make(map[string]float64, len(temps))
+	for disk, temp := range temps {
+		values[disk] = float64(temp)
+	}
+	return GetWeightedAverageOfWarmest(values, nil, n)
+}
+
+// GetWeightedAverageOfWarmest calculates the weighted average of the n warmest
+// disks; disks without a weight count as 1
+func GetWeightedAverageOfWarmest(temps map[string]float64, weights map[string]float64, n int) float64 {
+	if len(temps) == 0 || n <= 0 {
+		return 0.0
+	}
+
+	devices := make([]string, 0, len(temps))
+	for device := range temps {
+		devices = append(devices, device)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		if temps[devices[i]] != temps[devices[j]] {
+			return temps[devices[i]] > temps[devices[j]]
+		}
+		return devices[i] < devices[j]
+	})
+	if len(devices) > n {
+		devices = devices[:n]
+	}
+
+	var sum, totalWeight float64
+	for _, device := range devices {
+		weight, ok := weights[device]
+		if !ok {
+			weight = 1
+		}
+		sum += weight * temps[device]
+		totalWeight += weight
+	}
+	if totalWeight == 0 {
+		return 0.0
+	}
+	return sum / totalWeight
+}
+
+// GetPercentile returns the p-th percentile (0-100) of the temperatures,
+// interpolating linearly between the closest ranks
+func GetPercentile(temps map[string]float64, p float64) float64 {
 	if len(temps) == 0 {
 		return 0.0
 	}
 	
-	// Convert to slice and sort by temperature (descending)
-	var tempSlice []int
+	values := make([]float64, 0, len(temps))
 	for _, temp := range temps {
-		tempSlice = append(tempSlice, temp)
+		values = append(values, temp)
 	}
+	sort.Float64s(values)
 	
-	// Simple bubble sort (descending) - fine for small number of disks
-	for i := 0; i < len(tempSlice)-1; i++ {
-		for j := 0; j < len(tempSlice)-i-1; j++ {
-			if tempSlice[j] < tempSlice[j+1] {
-				tempSlice[j], tempSlice[j+1] = tempSlice[j+1], tempSlice[j]
-			}
+	rank := p / 100 * float64(len(values)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return values[lower] + (values[upper]-values[lower])*(rank-float64(lower))
+}
+
+// GetWorstMargin returns the largest amount by which a disk exceeds its own
+// target (negative when every disk is below target); disks without a target use defaultTarget
+func GetWorstMargin(temps map[string]int, targets map[string]float64, defaultTarget float64) float64 {
+	worst := math.Inf(-1)
+	for disk, temp := range temps {
+		target, ok := targets[disk]
+		if !ok {
+			target = defaultTarget
 		}
+		worst = math.Max(worst, float64(temp)-target)
 	}
-	
-	// Take the top N (or all if fewer than N)
-	take := n
-	if len(tempSlice) < take {
-		take = len(tempSlice)
+	if math.IsInf(worst, -1) {
+		return 0.0
 	}
-	
-	// Calculate average
-	sum := 0
-	for i := 0; i < take; i++ {
-		sum += tempSlice[i]
+	return worst
+}
+
+// EWMA is an exponentially weighted moving average over time, so irregular
+// sample intervals are weighted by how long each value was current
+type EWMA struct {
+	TimeConstant time.Duration
+	value        float64
+	last         time.Time // Time of the last update, zero before the first
+}
+
+// Update adds a sample taken at now and returns the smoothed value; the first sample is returned as is
+func (e *EWMA) Update(value float64, now time.Time) float64 {
+	if e.last.IsZero() || e.TimeConstant <= 0 {
+		e.value, e.last = value, now
+		return e.value
 	}
+	alpha := 1 - math.Exp(-now.Sub(e.last).Seconds()/e.TimeConstant.Seconds())
+	e.value += alpha * (value - e.value)
+	e.last = now
+	return e.value
+}
+
+// Aggregator reduces one poll of disk temperatures to the PID input using
+// temperature.aggregate. It holds the EWMA state, so use one per control loop
+type Aggregator struct {
+	config *Config
+	ewma   EWMA
+}
+
+// NewAggregator creates an aggregator for the configured strategy
+func NewAggregator(config *Config) *Aggregator {
+	return &Aggregator{config: config, ewma: EWMA{TimeConstant: config.Temperature.EWMATimeConstant}}
+}
+
+// Aggregate returns the control input for the PID, in the frame of target_hdd.
+// Except for max, disk group targets are applied so each disk counts relative to its own target
+func (a *Aggregator) Aggregate(temps map[string]int, policies DiskPolicies, now time.Time) float64 {
+	if len(temps) == 0 {
+		return 0.0
+	}
+	
+	temperature := a.config.Temperature
+	controlTemps := policies.ControlTemps(temps, temperature.TargetHDD)
 	
-	return float64(sum) / float64(take)
+	switch temperature.Aggregate {
+	case AggregateMax:
+		return float64(GetMaxTemperature(temps))
+	case AggregatePercentile:
+		return GetPercentile(controlTemps, temperature.Percentile)
+	case AggregateEWMA:
+		return a.ewma.Update(GetWeightedAverageOfWarmest(controlTemps, policies.Weights(), temperature.WarmestDisks), now)
+	case AggregateMargin:
+		return temperature.TargetHDD + GetWorstMargin(temps, policies.Targets(), temperature.TargetHDD)
+	default:
+		return GetWeightedAverageOfWarmest(controlTemps, policies.Weights(), temperature.WarmestDisks)
+	}
 }
 
 // GetMaxTemperature returns the highest temperature from the map
@@ -310,3 +506,12 @@ func GetMinTemperature(temps map[string]int) int {
 	}
 	return min
 }
+
+// diskNames returns the device names present in the temperature map
+func diskNames(temps map[string]int) []string {
+	names := make([]string, 0, len(temps))
+	for disk := range temps {
+		names = append(names, disk)
+	}
+	return names
+}
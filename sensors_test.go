@@ -1,9 +1,13 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestGetAverageOfWarmest_NormalCase tests normal averaging behavior
@@ -295,3 +299,219 @@ func TestMatchesExcludePattern_MultiplePatterns(t *testing.T) {
 		})
 	}
 }
+
+// TestGetWeightedAverageOfWarmest tests weighting and the warmest-N cut
+func TestGetWeightedAverageOfWarmest(t *testing.T) {
+	tests := []struct {
+		name     string
+		temps    map[string]float64
+		weights  map[string]float64
+		n        int
+		expected float64
+	}{
+		{"unweighted", map[string]float64{"sda": 40, "sdb": 42, "sdc": 30}, nil, 2, 41},
+		{"weighted", map[string]float64{"sda": 40, "sdb": 42}, map[string]float64{"sda": 3}, 2, (3*40 + 42) / 4.0},
+		{"weight does not change which disks are warmest", map[string]float64{"sda": 40, "sdb": 42, "sdc": 30}, map[string]float64{"sdc": 10}, 2, 41},
+		{"fewer disks than n", map[string]float64{"sda": 40}, nil, 4, 40},
+		{"empty", map[string]float64{}, nil, 4, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			avg := GetWeightedAverageOfWarmest(tt.temps, tt.weights, tt.n)
+
+			// Assert
+			assert.InDelta(t, tt.expected, avg, 0.001)
+		})
+	}
+}
+
+// TestGetPercentile tests rank interpolation
+func TestGetPercentile(t *testing.T) {
+	temps := map[string]float64{"sda": 30, "sdb": 35, "sdc": 40, "sdd": 45, "sde": 50}
+
+	tests := []struct {
+		name       string
+		temps      map[string]float64
+		percentile float64
+		expected   float64
+	}{
+		{"median", temps, 50, 40},
+		{"90th interpolated", temps, 90, 48},
+		{"100th is max", temps, 100, 50},
+		{"0th is min", temps, 0, 30},
+		{"single disk", map[string]float64{"sda": 38}, 90, 38},
+		{"empty", map[string]float64{}, 90, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			value := GetPercentile(tt.temps, tt.percentile)
+
+			// Assert
+			assert.InDelta(t, tt.expected, value, 0.001)
+		})
+	}
+}
+
+// TestGetWorstMargin tests the largest excess over each disk's own target
+func TestGetWorstMargin(t *testing.T) {
+	tests := []struct {
+		name     string
+		temps    map[string]int
+		targets  map[string]float64
+		expected float64
+	}{
+		{"default targets", map[string]int{"sda": 40, "sdb": 36}, nil, 2},
+		{"own target hides a hot disk", map[string]int{"sda": 50, "sdb": 39}, map[string]float64{"sda": 50}, 1},
+		{"all below target", map[string]int{"sda": 35, "sdb": 36}, nil, -2},
+		{"empty", map[string]int{}, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			margin := GetWorstMargin(tt.temps, tt.targets, 38)
+
+			// Assert
+			assert.InDelta(t, tt.expected, margin, 0.001)
+		})
+	}
+}
+
+// TestEWMA_Update tests time-based smoothing
+func TestEWMA_Update(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		constant time.Duration
+		elapsed  time.Duration
+		expected float64
+	}{
+		// alpha = 1 - exp(-elapsed/constant), stepping from 30 to 40
+		{"one time constant", time.Minute, time.Minute, 30 + 10*(1-0.36788)},
+		{"short interval moves little", 5 * time.Minute, time.Minute, 30 + 10*(1-0.81873)},
+		{"long interval catches up", time.Minute, time.Hour, 40},
+		{"disabled", 0, time.Minute, 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			ewma := EWMA{TimeConstant: tt.constant}
+			first := ewma.Update(30, start)
+
+			// Act
+			value := ewma.Update(40, start.Add(tt.elapsed))
+
+			// Assert
+			assert.Equal(t, 30.0, first, "first sample is returned as is")
+			assert.InDelta(t, tt.expected, value, 0.001)
+		})
+	}
+}
+
+// TestAggregator_Aggregate tests every aggregation strategy on the same poll
+func TestAggregator_Aggregate(t *testing.T) {
+	// sda is an enterprise disk with its own 48°C target
+	temps := map[string]int{"sda": 50, "sdb": 40, "sdc": 39, "sdd": 37, "sde": 35}
+	policies := DiskPolicies{"sda": {Group: "enterprise", Target: 48, Max: 60, Weight: 1}}
+
+	tests := []struct {
+		name      string
+		aggregate string
+		expected  float64
+	}{
+		{"top_n_mean uses own targets", AggregateTopNMean, (40 + 40 + 39 + 37) / 4.0},
+		{"default is top_n_mean", "", (40 + 40 + 39 + 37) / 4.0},
+		{"max is the raw hottest disk", AggregateMax, 50},
+		{"percentile", AggregatePercentile, 40},
+		{"ewma first sample", AggregateEWMA, (40 + 40 + 39 + 37) / 4.0},
+		{"margin", AggregateMargin, 38 + 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := &Config{Temperature: TemperatureConfig{TargetHDD: 38, MaxHDD: 45}}
+			setDefaults(config)
+			config.Temperature.Aggregate = tt.aggregate
+			config.Temperature.Percentile = 75
+			aggregator := NewAggregator(config)
+
+			// Act
+			value := aggregator.Aggregate(temps, policies, time.Now())
+
+			// Assert
+			assert.InDelta(t, tt.expected, value, 0.001)
+		})
+	}
+}
+
+// TestAggregator_EWMA_SmoothsAcrossPolls tests that the ewma strategy keeps state between polls
+func TestAggregator_EWMA_SmoothsAcrossPolls(t *testing.T) {
+	// Arrange
+	config := &Config{Temperature: TemperatureConfig{Aggregate: AggregateEWMA, EWMATimeConstant: time.Minute, WarmestDisks: 1}}
+	setDefaults(config)
+	aggregator := NewAggregator(config)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	aggregator.Aggregate(map[string]int{"sda": 30}, nil, start)
+
+	// Act
+	value := aggregator.Aggregate(map[string]int{"sda": 40}, nil, start.Add(time.Minute))
+
+	// Assert
+	assert.InDelta(t, 30+10*(1-0.36788), value, 0.001)
+}
+
+// TestAggregator_Aggregate_Empty tests that no disks give a zero input
+func TestAggregator_Aggregate_Empty(t *testing.T) {
+	// Arrange
+	config := &Config{}
+	setDefaults(config)
+
+	// Act
+	value := NewAggregator(config).Aggregate(map[string]int{}, nil, time.Now())
+
+	// Assert
+	assert.Equal(t, 0.0, value)
+}
+
+// TestClassifyFlash tests which non-spinning devices are monitored as flash drives
+func TestClassifyFlash(t *testing.T) {
+	// Arrange
+	sysBlock := t.TempDir()
+	writeSysBlockDevice(t, sysBlock, "nvme0n1", "0", "0")
+	require.NoError(t, os.MkdirAll(filepath.Join(sysBlock, "nvme0n1", "device"), 0o755))
+	writeSysBlockDevice(t, sysBlock, "sdx", "0", "1")
+	require.NoError(t, os.MkdirAll(filepath.Join(sysBlock, "sdx", "device"), 0o755))
+	writeSysBlockDevice(t, sysBlock, "zram0", "0", "0")
+	enabled := FlashConfig{Enabled: true}
+
+	tests := []struct {
+		name     string
+		device   string
+		status   string
+		flash    FlashConfig
+		expected string
+	}{
+		{"fixed nvme", "nvme0n1", DiskNotSpinning, enabled, DiskFlash},
+		{"disabled", "nvme0n1", DiskNotSpinning, FlashConfig{}, DiskNotSpinning},
+		{"removable ssd", "sdx", DiskNotSpinning, enabled, DiskNotSpinning},
+		{"virtual device", "zram0", DiskNotSpinning, enabled, DiskNotSpinning},
+		{"excluded", "nvme0n1", DiskExcluded, enabled, DiskExcluded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			status := classifyFlash(sysBlock, tt.device, tt.status, tt.flash)
+
+			// Assert
+			assert.Equal(t, tt.expected, status)
+		})
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Enclosure devices registered by the kernel ses driver
+const enclosureClassPath = "/sys/class/enclosure"
+
+// SES diagnostic pages read with sg_ses
+const (
+	sesConfigurationPage   = 0x01
+	sesEnclosureStatusPage = 0x02
+)
+
+// SES element types used by the controller
+const (
+	sesElementCooling     = 0x03
+	sesElementTemperature = 0x04
+)
+
+// SES element status codes (SES-3 table 74)
+var sesStatusNames = map[byte]string{
+	0x0: "unsupported",
+	0x1: "ok",
+	0x2: "critical",
+	0x3: "noncritical",
+	0x4: "unrecoverable",
+	0x5: "not_installed",
+	0x6: "unknown",
+	0x7: "not_available",
+	0x8: "no_access",
+}
+
+// Enclosure is a SES device found under /sys/class/enclosure
+type Enclosure struct {
+	Name   string // Kernel enclosure name (H:C:T:L)
+	Device string // SCSI generic device used for SES pages (/dev/sg3)
+}
+
+// EnclosureReadings is one poll of all enclosure sensors, keyed by
+// <enclosure logical id>/temp<n> and <enclosure logical id>/fan<n>
+type EnclosureReadings struct {
+	Temperatures map[string]int // Temperature sensor elements (°C)
+	Fans         map[string]int // Cooling element actual speeds (RPM)
+}
+
+// sesTypeDescriptor is one element type from the configuration page
+type sesTypeDescriptor struct {
+	ElementType  byte
+	Elements     int
+	Subenclosure byte
+	Text         string
+}
+
+// sesConfiguration is the parsed configuration diagnostic page
+type sesConfiguration struct {
+	Generation uint32
+	LogicalID  string // Primary subenclosure logical identifier (hex)
+	Vendor     string
+	Product    string
+	Types      []sesTypeDescriptor
+}
+
+// sesTemperature is one temperature sensor element from the status page
+type sesTemperature struct {
+	Index   int // Position among the enclosure's temperature elements
+	Celsius int
+	Status  string
+}
+
+// sesCooling is one cooling element from the status page
+type sesCooling struct {
+	Index  int // Position among the enclosure's cooling elements
+	RPM    int
+	Failed bool
+	Status string
+}
+
+// sesStatus is the parsed enclosure status diagnostic page
+type sesStatus struct {
+	Temperatures []sesTemperature
+	Fans         []sesCooling
+}
+
+// GetEnclosureTemperatures reads the temperature and cooling elements of every
+// SES enclosure. Sensors matching exclude patterns are dropped; one unreadable
+// enclosure only fails the poll when no enclosure could be read
+func GetEnclosureTemperatures(ctx context.Context, config EnclosureConfig) (EnclosureReadings, error) {
+	readings := EnclosureReadings{Temperatures: make(map[string]int), Fans: make(map[string]int)}
+
+	enclosures, err := findEnclosures(enclosureClassPath)
+	if err != nil {
+		return readings, err
+	}
+	if len(enclosures) == 0 {
+		return readings, fmt.Errorf("no SES enclosures found in %s", enclosureClassPath)
+	}
+
+	var errs []string
+	for _, enclosure := range enclosures {
+		configuration, status, err := readEnclosure(ctx, enclosure.Device)
+		if err != nil {
+			if ctx.Err() != nil {
+				return readings, fmt.Errorf("enclosure read cancelled: %w", ctx.Err())
+			}
+			slog.Warn("Failed to read enclosure", "enclosure", enclosure.Name, "device", enclosure.Device, "error", err)
+			errs = append(errs, fmt.Sprintf("%s: %v", enclosure.Name, err))
+			continue
+		}
+
+		for _, sensor := range status.Temperatures {
+			name := fmt.Sprintf("%s/temp%d", configuration.LogicalID, sensor.Index)
+			if !matchesExcludePattern(name, config.ExcludePatterns) {
+				readings.Temperatures[name] = sensor.Celsius
+			}
+		}
+		for _, fan := range status.Fans {
+			name := fmt.Sprintf("%s/fan%d", configuration.LogicalID, fan.Index)
+			if fan.Failed {
+				slog.Warn("Enclosure fan failed", "fan", name, "status", fan.Status)
+			}
+			readings.Fans[name] = fan.RPM
+		}
+	}
+
+	if len(readings.Temperatures) == 0 && len(errs) > 0 {
+		return readings, fmt.Errorf("failed to read any enclosure: %s", strings.Join(errs, "; "))
+	}
+	return readings, nil
+}
+
+// enclosureControlInput returns the hottest SES enclosure sensor shifted onto
+// target_hdd, as flashControlInput does for flash drives
+func enclosureControlInput(config *Config, temps map[string]int) (float64, bool) {
+	if !config.Enclosures.Enabled {
+		return 0, false
+	}
+	return sensorControlInput(config.Enclosures.Mode, config.Enclosures.TargetTemp, config.Temperature.TargetHDD, temps)
+}
+
+// findEnclosures lists the enclosures under classDir with their SCSI generic device
+func findEnclosures(classDir string) ([]Enclosure, error) {
+	entries, err := os.ReadDir(classDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil // ses driver not loaded
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", classDir, err)
+	}
+
+	var enclosures []Enclosure
+	for _, entry := range entries {
+		generic, err := os.ReadDir(filepath.Join(classDir, entry.Name(), "device", "scsi_generic"))
+		if err != nil || len(generic) == 0 {
+			slog.Debug("Enclosure has no SCSI generic device", "enclosure", entry.Name(), "error", err)
+			continue
+		}
+		enclosures = append(enclosures, Enclosure{Name: entry.Name(), Device: "/dev/" + generic[0].Name()})
+	}
+	return enclosures, nil
+}
+
+// readEnclosure fetches and parses the configuration and enclosure status pages
+func readEnclosure(ctx context.Context, device string) (sesConfiguration, sesStatus, error) {
+	page, err := readSESPage(ctx, device, sesConfigurationPage)
+	if err != nil {
+		return sesConfiguration{}, sesStatus{}, err
+	}
+	configuration, err := parseSESConfigPage(page)
+	if err != nil {
+		return sesConfiguration{}, sesStatus{}, err
+	}
+
+	page, err = readSESPage(ctx, device, sesEnclosureStatusPage)
+	if err != nil {
+		return sesConfiguration{}, sesStatus{}, err
+	}
+	status, err := parseSESStatusPage(page, configuration)
+	return configuration, status, err
+}
+
+// readSESPage returns the raw bytes of one diagnostic page (`sg_ses -rr` writes binary to stdout)
+func readSESPage(ctx context.Context, device string, page byte) ([]byte, error) {
+	output, err := exec.CommandContext(ctx, "sg_ses", fmt.Sprintf("--page=%d", page), "-rr", device).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("sg_ses page %d failed for %s: %w, output: %s",
+				page, device, err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("sg_ses page %d failed for %s: %w", page, device, err)
+	}
+	return output, nil
+}
+
+// sesPageBody checks the page code and length header and trims trailing bytes
+func sesPageBody(page []byte, code byte) ([]byte, error) {
+	if len(page) < 8 {
+		return nil, fmt.Errorf("SES page %d too short: %d bytes", code, len(page))
+	}
+	if page[0] != code {
+		return nil, fmt.Errorf("expected SES page %d, got page %d", code, page[0])
+	}
+	length := 4 + int(binary.BigEndian.Uint16(page[2:4]))
+	if len(page) < length {
+		return nil, fmt.Errorf("SES page %d truncated: %d of %d bytes", code, len(page), length)
+	}
+	return page[:length], nil
+}
+
+// parseSESConfigPage parses the configuration diagnostic page: the enclosure
+// descriptors, then one type descriptor header per element type, then their texts
+func parseSESConfigPage(page []byte) (sesConfiguration, error) {
+	page, err := sesPageBody(page, sesConfigurationPage)
+	if err != nil {
+		return sesConfiguration{}, err
+	}
+	configuration := sesConfiguration{Generation: binary.BigEndian.Uint32(page[4:8])}
+
+	// Enclosure descriptors: the primary subenclosure, then page[1] secondary ones
+	offset, types := 8, 0
+	for i := 0; i <= int(page[1]); i++ {
+		if offset+4 > len(page) {
+			return sesConfiguration{}, fmt.Errorf("SES configuration page truncated in enclosure descriptor %d", i)
+		}
+		length := 4 + int(page[offset+3])
+		if offset+length > len(page) {
+			return sesConfiguration{}, fmt.Errorf("SES configuration page truncated in enclosure descriptor %d", i)
+		}
+		if i == 0 && length >= 36 {
+			configuration.LogicalID = hex.EncodeToString(page[offset+4 : offset+12])
+			configuration.Vendor = strings.TrimSpace(string(page[offset+12 : offset+20]))
+			configuration.Product = strings.TrimSpace(string(page[offset+20 : offset+36]))
+		}
+		types += int(page[offset+2])
+		offset += length
+	}
+
+	// Type descriptor headers
+	textLengths := make([]int, types)
+	for i := 0; i < types; i++ {
+		if offset+4 > len(page) {
+			return sesConfiguration{}, fmt.Errorf("SES configuration page truncated in type descriptor %d", i)
+		}
+		configuration.Types = append(configuration.Types, sesTypeDescriptor{
+			ElementType:  page[offset],
+			Elements:     int(page[offset+1]),
+			Subenclosure: page[offset+2],
+		})
+		textLengths[i] = int(page[offset+3])
+		offset += 4
+	}
+
+	// Type descriptor texts
+	for i, length := range textLengths {
+		if offset+length > len(page) {
+			return sesConfiguration{}, fmt.Errorf("SES configuration page truncated in type descriptor text %d", i)
+		}
+		configuration.Types[i].Text = strings.TrimSpace(string(page[offset : offset+length]))
+		offset += length
+	}
+
+	return configuration, nil
+}
+
+// parseSESStatusPage parses the enclosure status diagnostic page, which holds an
+// overall element followed by the individual elements for each configured type
+func parseSESStatusPage(page []byte, configuration sesConfiguration) (sesStatus, error) {
+	page, err := sesPageBody(page, sesEnclosureStatusPage)
+	if err != nil {
+		return sesStatus{}, err
+	}
+	if generation := binary.BigEndian.Uint32(page[4:8]); generation != configuration.Generation {
+		return sesStatus{}, fmt.Errorf("SES generation code changed from %d to %d", configuration.Generation, generation)
+	}
+
+	var status sesStatus
+	offset, temps, fans := 8, 0, 0
+	for _, descriptor := range configuration.Types {
+		offset += 4 // Overall status element
+		for i := 0; i < descriptor.Elements; i++ {
+			if offset+4 > len(page) {
+				return sesStatus{}, fmt.Errorf("SES status page truncated at element type %#x", descriptor.ElementType)
+			}
+			element := page[offset : offset+4]
+			offset += 4
+
+			code := element[0] & 0x0f
+			switch descriptor.ElementType {
+			case sesElementTemperature:
+				index := temps
+				temps++
+				// Uninstalled sensors and a zero (reserved) reading carry no temperature
+				if code == 0x0 || code == 0x5 || element[2] == 0 {
+					continue
+				}
+				status.Temperatures = append(status.Temperatures, sesTemperature{
+					Index:   index,
+					Celsius: int(element[2]) - 20, // Offset by 20 to cover -19°C..235°C
+					Status:  sesStatusNames[code],
+				})
+			case sesElementCooling:
+				index := fans
+				fans++
+				if code == 0x0 || code == 0x5 {
+					continue
+				}
+				status.Fans = append(status.Fans, sesCooling{
+					Index:  index,
+					RPM:    (int(element[1]&0x07)<<8 | int(element[2])) * 10,
+					Failed: element[3]&0x40 != 0,
+					Status: sesStatusNames[code],
+				})
+			}
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readSESTestPage loads a binary page as written by `sg_ses -rr` for an LSI SAS3x40
+// expander backplane: 24 slots, 2 PSUs, 3 fans (one failed), 3 temperature sensors (one not installed)
+func readSESTestPage(t *testing.T, name string) []byte {
+	t.Helper()
+	page, err := os.ReadFile(filepath.Join("testdata", "ses", name))
+	require.NoError(t, err)
+	return page
+}
+
+// TestParseSESConfigPage tests parsing the configuration page
+func TestParseSESConfigPage(t *testing.T) {
+	// Arrange
+	page := readSESTestPage(t, "sas3x40_config.bin")
+
+	// Act
+	configuration, err := parseSESConfigPage(page)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, uint32(10), configuration.Generation)
+	assert.Equal(t, "5003048001c1a27f", configuration.LogicalID)
+	assert.Equal(t, "LSI", configuration.Vendor)
+	assert.Equal(t, "SAS3x40", configuration.Product)
+	require.Len(t, configuration.Types, 5)
+	assert.Equal(t, sesTypeDescriptor{ElementType: 0x17, Elements: 24, Text: "Drive Slots"}, configuration.Types[0])
+	assert.Equal(t, sesTypeDescriptor{ElementType: sesElementCooling, Elements: 3, Text: "Cooling"}, configuration.Types[2])
+	assert.Equal(t, sesTypeDescriptor{ElementType: sesElementTemperature, Elements: 3, Text: "Temperature Sensors"}, configuration.Types[3])
+}
+
+// TestParseSESStatusPage tests decoding temperature and cooling elements
+func TestParseSESStatusPage(t *testing.T) {
+	// Arrange
+	configuration, err := parseSESConfigPage(readSESTestPage(t, "sas3x40_config.bin"))
+	require.NoError(t, err)
+	page := readSESTestPage(t, "sas3x40_status.bin")
+
+	// Act
+	status, err := parseSESStatusPage(page, configuration)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, []sesTemperature{
+		{Index: 0, Celsius: 34, Status: "ok"},
+		{Index: 1, Celsius: 41, Status: "ok"},
+	}, status.Temperatures, "the uninstalled sensor is skipped")
+	assert.Equal(t, []sesCooling{
+		{Index: 0, RPM: 5120, Status: "ok"},
+		{Index: 1, RPM: 4970, Status: "ok"},
+		{Index: 2, RPM: 0, Failed: true, Status: "critical"},
+	}, status.Fans)
+}
+
+// TestParseSESPages_Errors tests malformed and mismatched pages
+func TestParseSESPages_Errors(t *testing.T) {
+	configPage := readSESTestPage(t, "sas3x40_config.bin")
+	statusPage := readSESTestPage(t, "sas3x40_status.bin")
+	configuration, err := parseSESConfigPage(configPage)
+	require.NoError(t, err)
+
+	changedGeneration := append([]byte{}, statusPage...)
+	changedGeneration[7] = 11
+
+	tests := []struct {
+		name     string
+		parse    func() error
+		errorMsg string
+	}{
+		{"short page", func() error { _, err := parseSESConfigPage(configPage[:4]); return err }, "too short"},
+		{"wrong page code", func() error { _, err := parseSESConfigPage(statusPage); return err }, "expected SES page 1"},
+		{"truncated config", func() error { _, err := parseSESConfigPage(configPage[:60]); return err }, "truncated"},
+		{"truncated status", func() error { _, err := parseSESStatusPage(statusPage[:100], configuration); return err }, "truncated"},
+		{"generation changed", func() error { _, err := parseSESStatusPage(changedGeneration, configuration); return err }, "generation code changed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			err := tt.parse()
+
+			// Assert
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+// TestFindEnclosures tests discovery of enclosures and their SCSI generic device
+func TestFindEnclosures(t *testing.T) {
+	// Arrange
+	classDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(classDir, "0:0:24:0", "device", "scsi_generic", "sg24"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(classDir, "1:0:8:0", "device"), 0o755))
+	silenceLogs(t)
+
+	// Act
+	enclosures, err := findEnclosures(classDir)
+	missing, missingErr := findEnclosures(filepath.Join(classDir, "missing"))
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, []Enclosure{{Name: "0:0:24:0", Device: "/dev/sg24"}}, enclosures)
+	assert.NoError(t, missingErr, "no ses driver is not an error")
+	assert.Empty(t, missing)
+}
+
+// TestEnclosureControlInput tests shifting the hottest enclosure sensor onto target_hdd
+func TestEnclosureControlInput(t *testing.T) {
+	tests := []struct {
+		name       string
+		enclosures EnclosureConfig
+		expected   float64
+		expectedOK bool
+	}{
+		{"control", EnclosureConfig{Enabled: true, Mode: SensorModeControl, TargetTemp: 40}, 39, true},
+		{"emergency", EnclosureConfig{Enabled: true, Mode: SensorModeEmergency, TargetTemp: 40}, 0, false},
+		{"disabled", EnclosureConfig{Mode: SensorModeControl, TargetTemp: 40}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := &Config{Temperature: TemperatureConfig{TargetHDD: 38}, Enclosures: tt.enclosures}
+			temps := map[string]int{"5003048001c1a27f/temp0": 34, "5003048001c1a27f/temp1": 41}
+
+			// Act
+			input, ok := enclosureControlInput(config, temps)
+
+			// Assert
+			assert.Equal(t, tt.expectedOK, ok)
+			assert.InDelta(t, tt.expected, input, 0.001)
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// simDisk is a single drive in the simulated chassis
+type simDisk struct {
+	name  string
+	temp  float64 // Current temperature (°C)
+	power float64 // Idle heat output (W)
+}
+
+// ThermalPlant is a lumped-capacitance chassis model. Each disk heats up from
+// its own power plus the workload heat and is cooled towards ambient through a
+// conductance that grows with fan duty:
+//
+//	C·dT/dt = P + W − (G0 + G1·duty/100)·(T − Tambient)
+//
+// It implements TempSource and FanActuator so it can stand in for the hardware
+type ThermalPlant struct {
+	Ambient         float64 // Intake air temperature (°C)
+	HeatCapacity    float64 // Per-disk heat capacity (J/K)
+	BaseConductance float64 // Per-disk conductance to ambient with fans stopped (W/K)
+	FanConductance  float64 // Additional per-disk conductance at 100% duty (W/K)
+	WorkloadHeat    float64 // Extra heat per disk from workload (W)
+	CPUTemp         float64 // Reported CPU temperature (°C)
+	MaxRPM          int     // Fan speed at 100% duty
+
+	Disks []*simDisk
+	duty  int
+}
+
+// newThermalPlant creates a chassis with n disks starting at startTemp
+// Conductances are chosen so ~70% duty holds the idle disks at 38°C
+func newThermalPlant(n int, startTemp float64) *ThermalPlant {
+	plant := &ThermalPlant{
+		Ambient:         25.0,
+		HeatCapacity:    600.0,
+		BaseConductance: 0.1,
+		FanConductance:  0.6,
+		CPUTemp:         50.0,
+		MaxRPM:          3000,
+		duty:            100,
+	}
+	for i := 0; i < n; i++ {
+		plant.Disks = append(plant.Disks, &simDisk{
+			name:  fmt.Sprintf("sd%c", 'a'+i),
+			temp:  startTemp,
+			power: 6.5 + 0.2*float64(i%3), // Slight spread between drives
+		})
+	}
+	return plant
+}
+
+// Step advances every disk temperature by dt
+func (p *ThermalPlant) Step(dt time.Duration) {
+	conductance := p.BaseConductance + p.FanConductance*float64(p.duty)/100
+	for _, disk := range p.Disks {
+		heatFlow := disk.power + p.WorkloadHeat - conductance*(disk.temp-p.Ambient)
+		disk.temp += heatFlow / p.HeatCapacity * dt.Seconds()
+	}
+}
+
+// MaxDiskTemp returns the hottest true (unquantized) disk temperature
+func (p *ThermalPlant) MaxDiskTemp() float64 {
+	max := math.Inf(-1)
+	for _, disk := range p.Disks {
+		max = math.Max(max, disk.temp)
+	}
+	return max
+}
+
+// ReadTemperatures reports whole-degree disk temperatures like smartctl
+func (p *ThermalPlant) ReadTemperatures(ctx context.Context) (TempReadings, error) {
+	temps := make(map[string]int, len(p.Disks))
+	for _, disk := range p.Disks {
+		temps[disk.name] = int(math.Round(disk.temp))
+	}
+	return TempReadings{Disks: temps, CPU: p.CPUTemp}, nil
+}
+
+// SetAllFans sets the simulated fan duty
+func (p *ThermalPlant) SetAllFans(ctx context.Context, dutyPercent int) error {
+	if dutyPercent < 0 || dutyPercent > 100 {
+		return fmt.Errorf("duty cycle must be between 0-100, got %d", dutyPercent)
+	}
+	p.duty = dutyPercent
+	return nil
+}
+
+// SetAutoMode is a no-op; the plant has no BMC curve
+func (p *ThermalPlant) SetAutoMode(ctx context.Context) error {
+	return nil
+}
+
+// GetFanSpeeds reports RPM proportional to duty on six headers
+func (p *ThermalPlant) GetFanSpeeds(ctx context.Context) (map[string]int, error) {
+	speeds := make(map[string]int)
+	for i := 1; i <= 6; i++ {
+		speeds[fmt.Sprintf("FAN%d", i)] = p.MaxRPM * p.duty / 100
+	}
+	return speeds, nil
+}
+
+// simSample is one point of the closed-loop trajectory
+type simSample struct {
+	elapsed time.Duration
+	avgTemp float64 // Average of the warmest disks as the controller sees it
+	maxTemp float64 // Hottest true disk temperature
+	duty    int
+}
+
+// simClock advances the plant whenever the control loop sleeps
+type simClock struct {
+	plant        *ThermalPlant
+	warmestDisks int
+	start        time.Time
+	now          time.Time
+	samples      []simSample
+	onTick       func(elapsed time.Duration)
+}
+
+// Now returns the simulated time
+func (c *simClock) Now() time.Time {
+	return c.now
+}
+
+// Sleep integrates the plant over d in one-second steps
+func (c *simClock) Sleep(ctx context.Context, d time.Duration) error {
+	for elapsed := time.Duration(0); elapsed < d; elapsed += time.Second {
+		c.plant.Step(time.Second)
+	}
+	c.now = c.now.Add(d)
+
+	elapsed := c.now.Sub(c.start)
+	readings, _ := c.plant.ReadTemperatures(ctx)
+	c.samples = append(c.samples, simSample{
+		elapsed: elapsed,
+		avgTemp: GetAverageOfWarmest(readings.Disks, c.warmestDisks),
+		maxTemp: c.plant.MaxDiskTemp(),
+		duty:    c.plant.duty,
+	})
+
+	if c.onTick != nil {
+		c.onTick(elapsed)
+	}
+	return nil
+}
+
+// simConfig returns the shipped config.yaml gains in standard form with defaults applied
+func simConfig() *Config {
+	config := &Config{
+		Temperature: TemperatureConfig{
+			TargetHDD:    38.0,
+			MaxHDD:       45.0,
+			PollInterval: 60 * time.Second,
+		},
+		Fans: FanConfig{MinDuty: 30, MaxDuty: 100, StartupDuty: 50},
+		PID: PIDConfig{
+			Kp:          1.5,
+			Ki:          0.05,
+			Kd:          2.0,
+			IntegralMax: 100.0, // Output is absolute duty, so I must be able to hold it alone
+			Form:        PIDFormStandard,
+		},
+	}
+	setDefaults(config)
+	return config
+}
+
+// runClosedLoop steps a Controller against the plant for the given simulated duration
+func runClosedLoop(t *testing.T, config *Config, plant *ThermalPlant, duration time.Duration, onTick func(time.Duration)) []simSample {
+	t.Helper()
+
+	initTestMetrics()
+	silenceLogs(t)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &simClock{
+		plant:        plant,
+		warmestDisks: config.Temperature.WarmestDisks,
+		start:        start,
+		now:          start,
+		onTick:       onTick,
+	}
+
+	controller := NewController(config, newPIDFromConfig(config), plant, plant, clock)
+	ctx := context.Background()
+	controller.SetStartupDuty(ctx)
+	for clock.now.Before(start.Add(duration)) {
+		controller.Step(ctx)
+		clock.Sleep(ctx, config.Temperature.PollInterval)
+	}
+
+	require.NotEmpty(t, clock.samples)
+	return clock.samples
+}
+
+// settlingTime returns when the average entered ±band of target for good
+func settlingTime(samples []simSample, target, band float64) (time.Duration, bool) {
+	settled := time.Duration(-1)
+	for _, sample := range samples {
+		if math.Abs(sample.avgTemp-target) > band {
+			settled = -1
+		} else if settled < 0 {
+			settled = sample.elapsed
+		}
+	}
+	return settled, settled >= 0
+}
+
+// peakAbove returns the largest true disk temperature after the given time
+func peakAbove(samples []simSample, after time.Duration) float64 {
+	peak := math.Inf(-1)
+	for _, sample := range samples {
+		if sample.elapsed >= after {
+			peak = math.Max(peak, sample.maxTemp)
+		}
+	}
+	return peak
+}
+
+// overshoot returns how far the controlled average rose above target
+func overshoot(samples []simSample, target float64) float64 {
+	var worst float64
+	for _, sample := range samples {
+		worst = math.Max(worst, sample.avgTemp-target)
+	}
+	return worst
+}
+
+// TestClosedLoop_ColdStart_Settles tests settling time and overshoot from a cold start
+func TestClosedLoop_ColdStart_Settles(t *testing.T) {
+	for _, strategy := range []string{AntiWindupClamp, AntiWindupConditional, AntiWindupBackCalculation} {
+		t.Run(strategy, func(t *testing.T) {
+			// Arrange
+			config := simConfig()
+			config.PID.AntiWindup = strategy
+			plant := newThermalPlant(6, 30.0)
+
+			// Act - 8 hours of control loop time
+			samples := runClosedLoop(t, config, plant, 8*time.Hour, nil)
+
+			// Assert
+			settled, ok := settlingTime(samples, config.Temperature.TargetHDD, 1.0)
+			require.True(t, ok, "loop never settled within ±1°C")
+			t.Logf("settled after %v, overshoot %.2f°C, peak disk %.2f°C",
+				settled, overshoot(samples, config.Temperature.TargetHDD), peakAbove(samples, 0))
+			assert.Less(t, settled, 2*time.Hour)
+			assert.Less(t, overshoot(samples, config.Temperature.TargetHDD), 5.0)
+			assert.Less(t, peakAbove(samples, 0), config.Temperature.MaxHDD)
+		})
+	}
+}
+
+// TestClosedLoop_ColdStart_AntiWindupReducesOvershoot tests the strategies on the plant
+func TestClosedLoop_ColdStart_AntiWindupReducesOvershoot(t *testing.T) {
+	run := func(strategy string) float64 {
+		config := simConfig()
+		config.PID.AntiWindup = strategy
+		samples := runClosedLoop(t, config, newThermalPlant(6, 30.0), 4*time.Hour, nil)
+		return overshoot(samples, config.Temperature.TargetHDD)
+	}
+
+	// Act - the integral winds down to -integral_max while the disks warm up at min duty
+	clampOvershoot := run(AntiWindupClamp)
+	conditionalOvershoot := run(AntiWindupConditional)
+
+	// Assert
+	assert.Less(t, conditionalOvershoot, clampOvershoot)
+}
+
+// TestClosedLoop_WorkloadStep_Recovers tests rejection of a workload heat step
+func TestClosedLoop_WorkloadStep_Recovers(t *testing.T) {
+	// Arrange - start at equilibrium, add scrub heat after 2 hours
+	config := simConfig()
+	plant := newThermalPlant(6, 38.0)
+	onTick := func(elapsed time.Duration) {
+		if elapsed >= 2*time.Hour {
+			plant.WorkloadHeat = 1.5
+		}
+	}
+
+	// Act
+	samples := runClosedLoop(t, config, plant, 10*time.Hour, onTick)
+
+	// Assert - fans ramp up, temperature never reaches emergency, then returns to target
+	peak := peakAbove(samples, 2*time.Hour)
+	t.Logf("peak after workload step %.2f°C, final duty %d%%", peak, samples[len(samples)-1].duty)
+	assert.Less(t, peak, config.Temperature.MaxHDD)
+	assert.Greater(t, samples[len(samples)-1].duty, samples[0].duty)
+	assert.InDelta(t, config.Temperature.TargetHDD, samples[len(samples)-1].avgTemp, 1.0)
+}
+
+// TestClosedLoop_HeatBeyondCapacity_Emergency tests emergency behaviour on the plant
+func TestClosedLoop_HeatBeyondCapacity_Emergency(t *testing.T) {
+	// Arrange - at full duty the disks still settle above max_hdd
+	config := simConfig()
+	plant := newThermalPlant(4, 38.0)
+	plant.WorkloadHeat = 8.0
+
+	// Act
+	samples := runClosedLoop(t, config, plant, 6*time.Hour, nil)
+
+	// Assert - any reading above max_hdd must have forced 100% duty
+	sawEmergency := false
+	for i := 1; i < len(samples); i++ {
+		if math.Round(samples[i-1].maxTemp) > config.Temperature.MaxHDD {
+			sawEmergency = true
+			assert.Equal(t, 100, samples[i].duty, "duty after %v should be 100%%", samples[i].elapsed)
+		}
+	}
+	assert.True(t, sawEmergency, "plant should have exceeded max_hdd")
+}
+
+// TestClosedLoop_CPUEmergency tests that a hot CPU forces full duty regardless of disks
+func TestClosedLoop_CPUEmergency(t *testing.T) {
+	// Arrange
+	config := simConfig()
+	plant := newThermalPlant(4, 30.0)
+	plant.CPUTemp = config.Temperature.MaxCPU + 5
+
+	// Act
+	samples := runClosedLoop(t, config, plant, time.Hour, nil)
+
+	// Assert
+	for _, sample := range samples {
+		assert.Equal(t, 100, sample.duty)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SystemdNotifier sends sd_notify state updates over the NOTIFY_SOCKET datagram socket
+// A nil notifier (not running under systemd) ignores every call
+type SystemdNotifier struct {
+	socket   string
+	watchdog time.Duration
+}
+
+// NewSystemdNotifier returns a notifier for NOTIFY_SOCKET, or nil if it is unset
+func NewSystemdNotifier() *SystemdNotifier {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	return &SystemdNotifier{socket: socket, watchdog: systemdWatchdogInterval()}
+}
+
+// systemdWatchdogInterval returns WatchdogSec from WATCHDOG_USEC if it is meant for this process
+func systemdWatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Notify sends one datagram with the given KEY=value assignments
+func (n *SystemdNotifier) Notify(assignments ...string) error {
+	if n == nil {
+		return nil
+	}
+
+	// Go maps a leading '@' to the Linux abstract socket namespace
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: n.socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to NOTIFY_SOCKET: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(strings.Join(assignments, "\n"))); err != nil {
+		return fmt.Errorf("failed to send sd_notify: %w", err)
+	}
+	return nil
+}
+
+// Ready tells systemd that startup has finished
+func (n *SystemdNotifier) Ready(status string) error {
+	return n.Notify("READY=1", "STATUS="+status)
+}
+
+// Alive pets the systemd watchdog and updates the status line
+func (n *SystemdNotifier) Alive(status string) error {
+	return n.Notify("WATCHDOG=1", "STATUS="+status)
+}
+
+// Stopping tells systemd that shutdown has begun
+func (n *SystemdNotifier) Stopping() error {
+	return n.Notify("STOPPING=1", "STATUS=Shutting down")
+}
+
+// WatchdogInterval returns the unit's WatchdogSec, or 0 if the watchdog is disabled
+func (n *SystemdNotifier) WatchdogInterval() time.Duration {
+	if n == nil {
+		return 0
+	}
+	return n.watchdog
+}
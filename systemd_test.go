@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listenUnixgram creates a datagram socket in a temp dir and returns its path
+func listenUnixgram(t *testing.T, name string) (*net.UnixConn, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn, path
+}
+
+// readDatagrams reads every datagram already queued on conn
+func readDatagrams(t *testing.T, conn *net.UnixConn) []string {
+	t.Helper()
+
+	var messages []string
+	buf := make([]byte, 65536)
+	for {
+		conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		n, err := conn.Read(buf)
+		if err != nil {
+			return messages
+		}
+		messages = append(messages, string(buf[:n]))
+	}
+}
+
+// TestNewSystemdNotifier tests the NOTIFY_SOCKET and WATCHDOG_USEC environment
+func TestNewSystemdNotifier(t *testing.T) {
+	tests := []struct {
+		name        string
+		socket      string
+		usec        string
+		pid         string
+		expectNil   bool
+		expectWatch time.Duration
+	}{
+		{name: "not under systemd", expectNil: true},
+		{name: "no watchdog", socket: "/run/systemd/notify"},
+		{name: "watchdog", socket: "/run/systemd/notify", usec: "180000000", expectWatch: 3 * time.Minute},
+		{name: "watchdog for another process", socket: "/run/systemd/notify", usec: "180000000", pid: "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			t.Setenv("NOTIFY_SOCKET", tt.socket)
+			t.Setenv("WATCHDOG_USEC", tt.usec)
+			t.Setenv("WATCHDOG_PID", tt.pid)
+
+			// Act
+			notifier := NewSystemdNotifier()
+
+			// Assert
+			if tt.expectNil {
+				assert.Nil(t, notifier)
+				assert.Zero(t, notifier.WatchdogInterval())
+				assert.NoError(t, notifier.Ready("ok"))
+				return
+			}
+			require.NotNil(t, notifier)
+			assert.Equal(t, tt.expectWatch, notifier.WatchdogInterval())
+		})
+	}
+}
+
+// TestSystemdNotifier_Notify tests the datagrams sent to NOTIFY_SOCKET
+func TestSystemdNotifier_Notify(t *testing.T) {
+	// Arrange
+	conn, path := listenUnixgram(t, "notify")
+	t.Setenv("NOTIFY_SOCKET", path)
+	notifier := NewSystemdNotifier()
+
+	// Act
+	require.NoError(t, notifier.Ready("starting"))
+	require.NoError(t, notifier.Alive("Status: Duty: 40%"))
+	require.NoError(t, notifier.Stopping())
+
+	// Assert
+	assert.Equal(t, []string{
+		"READY=1\nSTATUS=starting",
+		"WATCHDOG=1\nSTATUS=Status: Duty: 40%",
+		"STOPPING=1\nSTATUS=Shutting down",
+	}, readDatagrams(t, conn))
+}
+
+// TestController_Run_NotifiesSystemd tests WATCHDOG and STATUS on every successful iteration
+func TestController_Run_NotifiesSystemd(t *testing.T) {
+	// Arrange
+	conn, path := listenUnixgram(t, "notify")
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	temps := &fakeTempSource{diskTemps: map[string]int{"sda": 38}, cpuTemp: 50}
+	controller := newTestController(t, temps, &fakeFanActuator{})
+	controller.SetSystemd(NewSystemdNotifier())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	controller.clock = &cancellingClock{fakeClock: controller.clock.(*fakeClock), after: 2, cancel: cancel}
+
+	// Act
+	controller.Run(ctx)
+
+	// Assert
+	messages := readDatagrams(t, conn)
+	require.Len(t, messages, 2)
+	for _, message := range messages {
+		assert.True(t, strings.HasPrefix(message, "WATCHDOG=1\nSTATUS=Status: CPU: 50.0°C"), message)
+	}
+}
+
+// TestController_Run_NoSystemdOnError tests that failed iterations don't pet the systemd watchdog
+func TestController_Run_NoSystemdOnError(t *testing.T) {
+	// Arrange
+	conn, path := listenUnixgram(t, "notify")
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	temps := &fakeTempSource{err: context.DeadlineExceeded}
+	controller := newTestController(t, temps, &fakeFanActuator{})
+	controller.SetSystemd(NewSystemdNotifier())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	controller.clock = &cancellingClock{fakeClock: controller.clock.(*fakeClock), after: 2, cancel: cancel}
+
+	// Act
+	controller.Run(ctx)
+
+	// Assert
+	assert.Empty(t, readDatagrams(t, conn))
+}
+
+// TestController_Run_DegradedNotifiesSystemd tests that a degraded startup pets the systemd watchdog on failed iterations
+func TestController_Run_DegradedNotifiesSystemd(t *testing.T) {
+	// Arrange
+	conn, path := listenUnixgram(t, "notify")
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	temps := &fakeTempSource{err: context.DeadlineExceeded}
+	controller := newTestController(t, temps, &fakeFanActuator{})
+	controller.SetSystemd(NewSystemdNotifier())
+	controller.SetDegraded("no CPU temperature sensor")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	controller.clock = &cancellingClock{fakeClock: controller.clock.(*fakeClock), after: 2, cancel: cancel}
+
+	// Act
+	controller.Run(ctx)
+
+	// Assert
+	messages := readDatagrams(t, conn)
+	require.Len(t, messages, 2)
+	for _, message := range messages {
+		assert.True(t, strings.HasPrefix(message, "WATCHDOG=1\nSTATUS=Degraded: no CPU temperature sensor; last error:"), message)
+	}
+}
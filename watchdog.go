@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Hardware watchdog types
+const (
+	WatchdogNone   = "none"   // No hardware watchdog
+	WatchdogDevice = "device" // Linux watchdog device such as /dev/watchdog
+	WatchdogIPMI   = "ipmi"   // IPMI BMC watchdog timer
+)
+
+// Largest IPMI watchdog countdown: 16 bits of 100ms units
+const maxIPMIWatchdogTimeout = 65535 * 100 * time.Millisecond
+
+// HardwareWatchdog is a timer outside the process that must be petted regularly
+type HardwareWatchdog interface {
+	// Pet restarts the countdown
+	Pet(ctx context.Context) error
+	// Close disarms the watchdog on a clean exit
+	Close() error
+}
+
+// NewHardwareWatchdog arms the configured hardware watchdog; returns nil for none
+// The countdown must outlast pollInterval, since the watchdog is petted once per loop
+func NewHardwareWatchdog(ctx context.Context, config WatchdogConfig, pollInterval time.Duration) (HardwareWatchdog, error) {
+	switch config.Hardware {
+	case WatchdogNone, "":
+		return nil, nil
+	case WatchdogDevice:
+		return openDeviceWatchdog(config.Device, config.Timeout, pollInterval)
+	case WatchdogIPMI:
+		return armIPMIWatchdog(ctx, config.Timeout)
+	default:
+		return nil, fmt.Errorf("unknown hardware watchdog %q", config.Hardware)
+	}
+}
+
+// deviceWatchdog pets a Linux watchdog device
+type deviceWatchdog struct {
+	file *os.File
+}
+
+// deviceWatchdogTimeout asks the driver for a countdown and returns the one in
+// effect; replaced in tests, where the device is a plain file
+var deviceWatchdogTimeout = ioctlWatchdogTimeout
+
+// ioctlWatchdogTimeout sets the countdown with WDIOC_SETTIMEOUT and reads it back with
+// WDIOC_GETTIMEOUT. A driver that rejects the value keeps its own, which is still checked
+func ioctlWatchdogTimeout(file *os.File, timeout time.Duration) (time.Duration, error) {
+	fd := int(file.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.WDIOC_SETTIMEOUT, int(timeout/time.Second)); err != nil {
+		slog.Warn("Watchdog device rejected timeout, keeping the driver's", "timeout", timeout, "error", err)
+	}
+	seconds, err := unix.IoctlGetInt(fd, unix.WDIOC_GETTIMEOUT)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read watchdog timeout: %w", err)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// openDeviceWatchdog opens the device, which starts the countdown, and sets its timeout
+// A countdown that could expire between two polls is disarmed and rejected
+func openDeviceWatchdog(path string, timeout, pollInterval time.Duration) (*deviceWatchdog, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open watchdog device %s: %w", path, err)
+	}
+	w := &deviceWatchdog{file: file}
+
+	actual, err := deviceWatchdogTimeout(file, timeout)
+	if err == nil && actual <= pollInterval {
+		err = fmt.Errorf("timeout %v must be greater than poll_interval (%v)", actual, pollInterval)
+	}
+	if err != nil {
+		if closeErr := w.Close(); closeErr != nil {
+			slog.Error("Failed to disarm watchdog device", "error", closeErr)
+		}
+		return nil, fmt.Errorf("watchdog device %s: %w", path, err)
+	}
+	slog.Info("Hardware watchdog armed", "device", path, "timeout", actual)
+	return w, nil
+}
+
+// Pet writes to the device to restart the countdown
+func (w *deviceWatchdog) Pet(ctx context.Context) error {
+	if _, err := w.file.Write([]byte{'1'}); err != nil {
+		return fmt.Errorf("failed to pet watchdog device: %w", err)
+	}
+	return nil
+}
+
+// Close writes the magic 'V' so the driver disarms instead of resetting the host
+func (w *deviceWatchdog) Close() error {
+	if _, err := w.file.Write([]byte{'V'}); err != nil {
+		w.file.Close()
+		return fmt.Errorf("failed to disarm watchdog device: %w", err)
+	}
+	return w.file.Close()
+}
+
+// ipmiWatchdog pets the BMC watchdog timer through ipmitool
+type ipmiWatchdog struct{}
+
+// ipmiWatchdogSetArgs returns the Set Watchdog Timer command: SMS/OS timer use,
+// hard reset on expiry, no pre-timeout, clear the SMS/OS expiry flag, countdown in 100ms units
+func ipmiWatchdogSetArgs(timeout time.Duration) ([]string, error) {
+	if timeout <= 0 || timeout > maxIPMIWatchdogTimeout {
+		return nil, fmt.Errorf("IPMI watchdog timeout must be between 100ms and %v, got %v", maxIPMIWatchdogTimeout, timeout)
+	}
+	count := int(timeout / (100 * time.Millisecond))
+	return []string{
+		"raw", "0x06", "0x24", "0x04", "0x01", "0x00", "0x10",
+		fmt.Sprintf("0x%02x", count&0xff), fmt.Sprintf("0x%02x", count>>8),
+	}, nil
+}
+
+// armIPMIWatchdog sets the BMC watchdog countdown and starts it
+func armIPMIWatchdog(ctx context.Context, timeout time.Duration) (*ipmiWatchdog, error) {
+	args, err := ipmiWatchdogSetArgs(timeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := runIPMIRaw(ctx, args); err != nil {
+		return nil, fmt.Errorf("failed to set IPMI watchdog timer: %w", err)
+	}
+
+	w := &ipmiWatchdog{}
+	if err := w.Pet(ctx); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Pet sends Reset Watchdog Timer, which restarts the countdown
+func (w *ipmiWatchdog) Pet(ctx context.Context) error {
+	output, err := exec.CommandContext(ctx, "ipmitool", "raw", "0x06", "0x22").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to reset IPMI watchdog timer: %v, output: %s", err, string(output))
+	}
+	return nil
+}
+
+// Close stops the BMC watchdog timer
+func (w *ipmiWatchdog) Close() error {
+	output, err := exec.Command("ipmitool", "mc", "watchdog", "off").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to stop IPMI watchdog timer: %v, output: %s", err, string(output))
+	}
+	return nil
+}
+
+// Watchdog is the dead-man switch for the control loop. The loop calls Beat after
+// every iteration; Run forces fans to 100% if no iteration has succeeded within
+// stall_intervals × poll_interval, e.g. because smartctl hung
+type Watchdog struct {
+	hardware     HardwareWatchdog
+	fans         FanActuator
+	clock        Clock
+	pollInterval time.Duration
+	stallLimit   time.Duration
+
+	mu          sync.Mutex
+	lastSuccess time.Time
+	tripped     bool
+}
+
+// NewWatchdog creates a watchdog; hardware may be nil
+func NewWatchdog(config *Config, hardware HardwareWatchdog, fans FanActuator, clock Clock) *Watchdog {
+	return &Watchdog{
+		hardware:     hardware,
+		fans:         fans,
+		clock:        clock,
+		pollInterval: config.Temperature.PollInterval,
+		stallLimit:   time.Duration(config.Watchdog.StallIntervals) * config.Temperature.PollInterval,
+		lastSuccess:  clock.Now(),
+	}
+}
+
+// Beat records a completed loop iteration and pets the hardware watchdog
+// The stall timer only restarts when the iteration succeeded
+func (w *Watchdog) Beat(ctx context.Context, success bool) {
+	if w.hardware != nil {
+		if err := w.hardware.Pet(ctx); err != nil {
+			slog.Warn("Failed to pet hardware watchdog", "error", err)
+			RecordError("watchdog")
+		}
+	}
+
+	if !success {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastSuccess = w.clock.Now()
+	if w.tripped {
+		slog.Info("Control loop recovered, watchdog cleared")
+		w.tripped = false
+	}
+	metrics.SinceLastLoop.Set(0)
+}
+
+// Check updates the staleness metric and forces 100% once per stall
+// Returns true if the loop is currently stalled
+func (w *Watchdog) Check(ctx context.Context) bool {
+	w.mu.Lock()
+	since := w.clock.Now().Sub(w.lastSuccess)
+	metrics.SinceLastLoop.Set(since.Seconds())
+	if since <= w.stallLimit || w.tripped {
+		stalled := w.tripped
+		w.mu.Unlock()
+		return stalled
+	}
+	w.tripped = true
+	w.mu.Unlock()
+
+	slog.Error("WATCHDOG: no successful control loop, setting fans to 100%",
+		"since", since.Round(time.Second), "limit", w.stallLimit)
+	RecordError("watchdog_stall")
+	if err := w.fans.SetAllFans(ctx, 100); err != nil && ctx.Err() == nil {
+		slog.Error("Watchdog failed to set fans to 100%", "error", err)
+	}
+	return true
+}
+
+// Run checks the loop every poll interval until ctx is cancelled
+func (w *Watchdog) Run(ctx context.Context) error {
+	for {
+		if err := w.clock.Sleep(ctx, w.pollInterval); err != nil {
+			return err
+		}
+		w.Check(ctx)
+	}
+}
+
+// Close disarms the hardware watchdog
+func (w *Watchdog) Close() error {
+	if w.hardware == nil {
+		return nil
+	}
+	return w.hardware.Close()
+}
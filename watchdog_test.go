@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHardwareWatchdog counts pets and closes
+type fakeHardwareWatchdog struct {
+	pets   int
+	closed bool
+	err    error
+}
+
+// Pet counts the call and returns err
+func (w *fakeHardwareWatchdog) Pet(ctx context.Context) error {
+	w.pets++
+	return w.err
+}
+
+// Close records the disarm
+func (w *fakeHardwareWatchdog) Close() error {
+	w.closed = true
+	return nil
+}
+
+// newTestWatchdog creates a Watchdog with a 60s poll interval and 3-interval stall limit
+func newTestWatchdog(t *testing.T, hardware HardwareWatchdog, fans FanActuator) (*Watchdog, *fakeClock) {
+	t.Helper()
+
+	initTestMetrics()
+	silenceLogs(t)
+
+	config := &Config{}
+	setDefaults(config)
+	config.Temperature.PollInterval = 60 * time.Second
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	return NewWatchdog(config, hardware, fans, clock), clock
+}
+
+// TestWatchdog_Check_Stall tests that a stalled loop forces 100% exactly once
+func TestWatchdog_Check_Stall(t *testing.T) {
+	// Arrange
+	fans := &fakeFanActuator{}
+	watchdog, clock := newTestWatchdog(t, nil, fans)
+	ctx := context.Background()
+
+	// Act - within the limit, then past it twice
+	clock.now = clock.now.Add(3 * time.Minute)
+	withinLimit := watchdog.Check(ctx)
+	clock.now = clock.now.Add(time.Second)
+	stalled := watchdog.Check(ctx)
+	clock.now = clock.now.Add(time.Minute)
+	stillStalled := watchdog.Check(ctx)
+
+	// Assert
+	assert.False(t, withinLimit)
+	assert.True(t, stalled)
+	assert.True(t, stillStalled)
+	assert.Equal(t, []int{100}, fans.duties)
+	assert.InDelta(t, 241.0, testutil.ToFloat64(metrics.SinceLastLoop), 0.01)
+}
+
+// TestWatchdog_Beat_Success tests that a successful loop clears a stall
+func TestWatchdog_Beat_Success(t *testing.T) {
+	// Arrange
+	fans := &fakeFanActuator{}
+	watchdog, clock := newTestWatchdog(t, nil, fans)
+	ctx := context.Background()
+	clock.now = clock.now.Add(5 * time.Minute)
+	require.True(t, watchdog.Check(ctx))
+
+	// Act
+	watchdog.Beat(ctx, true)
+	clock.now = clock.now.Add(time.Minute)
+	stalled := watchdog.Check(ctx)
+
+	// Assert
+	assert.False(t, stalled)
+	assert.InDelta(t, 60.0, testutil.ToFloat64(metrics.SinceLastLoop), 0.01)
+}
+
+// TestWatchdog_Beat_Failure tests that failed iterations pet the hardware but don't reset the stall timer
+func TestWatchdog_Beat_Failure(t *testing.T) {
+	// Arrange
+	hardware := &fakeHardwareWatchdog{}
+	fans := &fakeFanActuator{}
+	watchdog, clock := newTestWatchdog(t, hardware, fans)
+	ctx := context.Background()
+
+	// Act - temperature reads keep failing for 4 intervals
+	for i := 0; i < 4; i++ {
+		clock.now = clock.now.Add(time.Minute)
+		watchdog.Beat(ctx, false)
+	}
+	clock.now = clock.now.Add(time.Second)
+	stalled := watchdog.Check(ctx)
+
+	// Assert
+	assert.Equal(t, 4, hardware.pets)
+	assert.True(t, stalled)
+	assert.Equal(t, []int{100}, fans.duties)
+}
+
+// TestWatchdog_Beat_HardwareError tests that a failed pet is recorded, not fatal
+func TestWatchdog_Beat_HardwareError(t *testing.T) {
+	// Arrange
+	hardware := &fakeHardwareWatchdog{err: errors.New("BMC busy")}
+	watchdog, _ := newTestWatchdog(t, hardware, &fakeFanActuator{})
+	before := testutil.ToFloat64(metrics.ErrorsTotal.WithLabelValues("watchdog"))
+
+	// Act
+	watchdog.Beat(context.Background(), true)
+
+	// Assert
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.ErrorsTotal.WithLabelValues("watchdog")))
+	require.NoError(t, watchdog.Close())
+	assert.True(t, hardware.closed)
+}
+
+// TestController_Run_BeatsWatchdog tests the control loop pets the watchdog every iteration
+func TestController_Run_BeatsWatchdog(t *testing.T) {
+	// Arrange
+	temps := &fakeTempSource{diskTemps: map[string]int{"sda": 38}, cpuTemp: 50}
+	fans := &fakeFanActuator{}
+	controller := newTestController(t, temps, fans)
+	hardware := &fakeHardwareWatchdog{}
+	watchdog, _ := newTestWatchdog(t, hardware, fans)
+	controller.SetWatchdog(watchdog)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	controller.clock = &cancellingClock{fakeClock: controller.clock.(*fakeClock), after: 3, cancel: cancel}
+
+	// Act
+	controller.Run(ctx)
+
+	// Assert
+	assert.Equal(t, 3, hardware.pets)
+}
+
+// stubDeviceWatchdogTimeout makes the driver report timeout and records the requested one
+func stubDeviceWatchdogTimeout(t *testing.T, timeout time.Duration) *time.Duration {
+	t.Helper()
+	var requested time.Duration
+	original := deviceWatchdogTimeout
+	deviceWatchdogTimeout = func(file *os.File, want time.Duration) (time.Duration, error) {
+		requested = want
+		return timeout, nil
+	}
+	t.Cleanup(func() { deviceWatchdogTimeout = original })
+	return &requested
+}
+
+// TestDeviceWatchdog tests petting and the magic close on a watchdog device
+func TestDeviceWatchdog(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "watchdog")
+	require.NoError(t, os.WriteFile(path, nil, 0600))
+	requested := stubDeviceWatchdogTimeout(t, 5*time.Minute)
+	config := WatchdogConfig{Hardware: WatchdogDevice, Device: path, Timeout: 5 * time.Minute}
+	watchdog, err := NewHardwareWatchdog(context.Background(), config, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, *requested)
+
+	// Act
+	require.NoError(t, watchdog.Pet(context.Background()))
+	require.NoError(t, watchdog.Pet(context.Background()))
+	require.NoError(t, watchdog.Close())
+
+	// Assert
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "11V", string(data))
+}
+
+// TestDeviceWatchdog_TimeoutTooShort tests that a driver countdown within poll_interval is disarmed
+func TestDeviceWatchdog_TimeoutTooShort(t *testing.T) {
+	// Arrange - the driver keeps its 30s default
+	path := filepath.Join(t.TempDir(), "watchdog")
+	require.NoError(t, os.WriteFile(path, nil, 0600))
+	stubDeviceWatchdogTimeout(t, 30*time.Second)
+	config := WatchdogConfig{Hardware: WatchdogDevice, Device: path, Timeout: 10 * time.Minute}
+
+	// Act
+	watchdog, err := NewHardwareWatchdog(context.Background(), config, time.Minute)
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must be greater than poll_interval")
+	assert.Nil(t, watchdog)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "V", string(data), "disarmed before returning")
+}
+
+// TestNewHardwareWatchdog_None tests that no watchdog is armed by default
+func TestNewHardwareWatchdog_None(t *testing.T) {
+	// Act
+	watchdog, err := NewHardwareWatchdog(context.Background(), WatchdogConfig{Hardware: WatchdogNone}, time.Minute)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Nil(t, watchdog)
+}
+
+// TestIPMIWatchdogSetArgs tests the countdown encoding in 100ms units
+func TestIPMIWatchdogSetArgs(t *testing.T) {
+	tests := []struct {
+		name           string
+		timeout        time.Duration
+		lsb, msb       string
+		expectErrorMsg string
+	}{
+		{name: "10 minutes", timeout: 10 * time.Minute, lsb: "0x70", msb: "0x17"},
+		{name: "maximum", timeout: maxIPMIWatchdogTimeout, lsb: "0xff", msb: "0xff"},
+		{name: "too long", timeout: 2 * time.Hour, expectErrorMsg: "IPMI watchdog timeout must be between"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			args, err := ipmiWatchdogSetArgs(tt.timeout)
+
+			// Assert
+			if tt.expectErrorMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectErrorMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []string{"raw", "0x06", "0x24"}, args[:3])
+			assert.Equal(t, []string{tt.lsb, tt.msb}, args[len(args)-2:])
+		})
+	}
+}